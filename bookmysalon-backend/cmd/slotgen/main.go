@@ -0,0 +1,34 @@
+// Command slotgen fills the salons' weekly slot templates with availability
+// slots for a number of weeks ahead. Running it again only adds the slots
+// that are missing, so it can run daily, e.g. from cron, to keep the rolling
+// horizon filled.
+package main
+
+import (
+	"bookmysalon/services/availability"
+	"flag"
+	"log"
+)
+
+func main() {
+	weeks := flag.Int("weeks", availability.DefaultSlotWeeks, "how many weeks ahead to generate slots for")
+	salonID := flag.Int("salon", 0, "only generate slots for this salon")
+	flag.Parse()
+
+	service, err := availability.NewAvailabilityService()
+	if err != nil {
+		log.Fatalf("Failed to initialize availability service: %v", err)
+	}
+
+	var created int
+	if *salonID != 0 {
+		created, err = service.GenerateSlots(*salonID, *weeks)
+	} else {
+		created, err = service.GenerateAllSlots(*weeks)
+	}
+	if err != nil {
+		log.Fatalf("Failed to generate slots: %v", err)
+	}
+
+	log.Printf("Created %d slots", created)
+}
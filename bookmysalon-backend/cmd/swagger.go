@@ -1,5 +1,7 @@
 package swaggerdocs
 
+import "bookmysalon/models"
+
 // Generic error model
 // swagger:response errorResponse
 type errorResponse struct {
@@ -11,9 +13,97 @@ type errorResponse struct {
 // A token model
 // swagger:response tokenResponse
 type tokenResponse struct {
-	// The JWT token
+	// The JWT access token
 	// required: true
 	Token string `json:"token"`
+
+	// The refresh token used to obtain a new token pair
+	// required: true
+	RefreshToken string `json:"refresh_token"`
+
+	// The token type, always "Bearer"
+	// required: true
+	TokenType string `json:"token_type"`
+
+	// Lifetime of the access token in seconds
+	// required: true
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Returned by login instead of tokens when a second factor is needed
+// swagger:response mfaChallengeResponse
+type mfaChallengeResponse struct {
+	// Always true
+	// required: true
+	MFARequired bool `json:"mfa_required"`
+
+	// Whether the user has to set up an authenticator app first, at /login/mfa/enroll
+	// required: true
+	EnrollmentRequired bool `json:"enrollment_required"`
+
+	// The token to send to /login/mfa together with the code
+	// required: true
+	MFAToken string `json:"mfa_token"`
+
+	// Lifetime of the MFA token in seconds
+	// required: true
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// A token model returned by the second login step
+// swagger:response mfaLoginResponse
+type mfaLoginResponse struct {
+	tokenResponse
+
+	// New recovery codes, only present when the login completed the enrollment
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// The secret to add to an authenticator app
+// swagger:response totpEnrollmentResponse
+type totpEnrollmentResponse struct {
+	// The base32 encoded secret, for manual entry
+	// required: true
+	Secret string `json:"secret"`
+
+	// The otpauth:// URI, usually shown as a QR code
+	// required: true
+	URI string `json:"otpauth_uri"`
+}
+
+// One-time recovery codes; they are shown only once
+// swagger:response recoveryCodesResponse
+type recoveryCodesResponse struct {
+	// required: true
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// The roles that must use two-factor authentication
+// swagger:response mfaRequiredRolesResponse
+type mfaRequiredRolesResponse struct {
+	// required: true
+	Roles []string `json:"roles"`
+}
+
+// The provider URL to send the user to
+// swagger:response authorizationURLResponse
+type authorizationURLResponse struct {
+	// required: true
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// Everything stored about a user
+// swagger:response dataExportResponse
+type dataExportResponse struct {
+	// in: body
+	Body struct {
+		ExportedAt   string               `json:"exported_at"`
+		Profile      models.User          `json:"profile"`
+		Appointments []models.Appointment `json:"appointments"`
+		Reviews      []models.Review      `json:"reviews"`
+		Transactions []models.Transaction `json:"transactions"`
+		Invoices     []models.Invoice     `json:"invoices"`
+	}
 }
 
 // A user representation without password
@@ -26,4 +116,27 @@ type userResponse struct {
 	// Username for the user
 	// required: true
 	Username string `json:"username"`
+
+	// Email address and whether it has been verified
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+
+	// Profile details
+	DisplayName       string `json:"display_name"`
+	PhoneNumber       string `json:"phone_number"`
+	ProfileImage      string `json:"profile_image"`
+	PreferredLanguage string `json:"preferred_language"`
+	Timezone          string `json:"timezone"`
+
+	// Marketing consent and when it last changed
+	MarketingConsent          bool   `json:"marketing_consent"`
+	MarketingConsentUpdatedAt string `json:"marketing_consent_updated_at"`
+
+	// Platform role and whether two-factor authentication is enabled
+	Role             string `json:"role"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+
+	// Account dates
+	DateJoined string `json:"date_joined"`
+	LastLogin  string `json:"last_login"`
 }
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/blobstore"
 	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/mailer"
 	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/oidc"
+	"bookmysalon/pkg/session"
 	"bookmysalon/services/appointment"
 	"bookmysalon/services/availability"
+	"bookmysalon/services/media"
 	"bookmysalon/services/review"
 	"bookmysalon/services/salon"
+	"bookmysalon/services/search"
 	"bookmysalon/services/user"
+	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -25,28 +38,76 @@ func handleInitializationError(err error, message string) {
 
 func main() {
 
+	// Refuse to start without key material to sign and verify tokens
+	handleInitializationError(jwt.LoadKeys(), "Failed to load JWT signing keys: %v")
+
 	// Run the migrations first
 	database.RunMigrations()
 
 	// Services and handlers initialization
-	salonService, err := salon.NewSalonService()
+	policy, err := authz.NewPolicy()
+	handleInitializationError(err, "Failed to initialize authorization policy: %v")
+
+	geocoder, err := geo.NewFromEnv()
+	handleInitializationError(err, "Failed to initialize geocoder: %v")
+
+	salonService, err := salon.NewSalonService(geocoder)
 	handleInitializationError(err, "Failed to initialize salon service: %v")
-	salonHandler := salon.NewSalonHandler(salonService)
+	salonHandler := salon.NewSalonHandler(salonService, policy)
+
+	sessionStore, err := session.NewStore()
+	handleInitializationError(err, "Failed to initialize session store: %v")
+	middleware.SetRevocationChecker(sessionStore)
+
+	mail, err := mailer.NewFromEnv()
+	handleInitializationError(err, "Failed to initialize mailer: %v")
 
-	userServiceImpl := &user.UserServiceImpl{}
+	oidcProviders, err := oidc.ProvidersFromEnv()
+	handleInitializationError(err, "Failed to configure OIDC providers: %v")
+
+	userServiceImpl := &user.UserServiceImpl{
+		Sessions:      sessionStore,
+		Mailer:        mail,
+		AppBaseURL:    os.Getenv("APP_BASE_URL"),
+		OIDCProviders: oidcProviders,
+	}
 	userHandler := user.NewUserHandler(userServiceImpl)
 
+	// Anonymize deleted accounts once their grace period is over
+	user.StartAccountPurger(userServiceImpl, time.Hour)
+
 	appointmentService, err := appointment.NewAppointmentService()
 	handleInitializationError(err, "Failed to initialize appointment service: %v")
-	appointmentHandler := appointment.NewAppointmentHandler(appointmentService)
+	appointmentHandler := appointment.NewAppointmentHandler(appointmentService, policy)
+
+	// Cancel requested appointments their salon did not confirm in time
+	appointment.StartUnconfirmedCanceller(appointmentService, 10*time.Minute)
 
 	availabilityService, err := availability.NewAvailabilityService()
 	handleInitializationError(err, "Failed to initialize availability service: %v")
-	availabilityHandler := availability.NewAvailabilityHandler(availabilityService)
+	availabilityHandler := availability.NewAvailabilityHandler(availabilityService, policy)
 
 	reviewService, err := review.NewReviewService()
 	handleInitializationError(err, "Failed to initialize review service: %v")
-	reviewHandler := review.NewReviewHandler(reviewService)
+	reviewHandler := review.NewReviewHandler(reviewService, policy)
+
+	searchService, err := search.NewSearchService()
+	handleInitializationError(err, "Failed to initialize search service: %v")
+	searchHandler := search.NewSearchHandler(searchService)
+
+	blobStore, err := blobstore.NewFromEnv()
+	handleInitializationError(err, "Failed to initialize blob store: %v")
+
+	mediaService, err := media.NewMediaService(blobStore, os.Getenv("MEDIA_BASE_URL"))
+	handleInitializationError(err, "Failed to initialize media service: %v")
+	mediaHandler := media.NewMediaHandler(mediaService, policy)
+
+	// Remove the files of deleted photos from the blob store
+	media.StartGarbageCollector(mediaService, 10*time.Minute)
+
+	// Role gates used on top of middleware.Authenticate
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	salonManagers := middleware.RequireRole(models.RoleSalonOwner, models.RoleAdmin)
 
 	r := mux.NewRouter()
 
@@ -55,10 +116,18 @@ func main() {
 		w.Write([]byte("Welcome to BookMySalon API!"))
 	}).Methods("GET")
 
+	// Public keys for verifying the tokens we issue
+	r.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jwt.PublicKeySet())
+	}).Methods("GET")
+
 	// Salon routes
-	r.HandleFunc("/salon", middleware.Authenticate(salonHandler.CreateSalon)).Methods("POST")
+	r.HandleFunc("/salon", middleware.Authenticate(salonManagers(salonHandler.CreateSalon))).Methods("POST")
 	r.HandleFunc("/salon/update", middleware.Authenticate(salonHandler.UpdateSalonDetails)).Methods("PUT")
 	r.HandleFunc("/salons", middleware.Authenticate(salonHandler.ListAllSalons)).Methods("GET")
+	r.HandleFunc("/salons/nearby", middleware.Authenticate(salonHandler.ListNearbySalons)).Methods("GET")
+	r.HandleFunc("/salons/status/{status}", middleware.Authenticate(adminOnly(salonHandler.ListSalonsByStatus))).Methods("GET")
 	r.HandleFunc("/service", middleware.Authenticate(salonHandler.AddService)).Methods("POST")
 	r.HandleFunc("/service/update", middleware.Authenticate(salonHandler.UpdateServiceDetails)).Methods("PUT")
 	r.HandleFunc("/service/{serviceID}", middleware.Authenticate(salonHandler.DeleteService)).Methods("DELETE")
@@ -67,14 +136,68 @@ func main() {
 	r.HandleFunc("/salon/{salonID}/average-rating", middleware.Authenticate(salonHandler.GetSalonAverageRating)).Methods("GET")
 	r.HandleFunc("/salon/{salonID}", middleware.Authenticate(salonHandler.GetSalonDetails)).Methods("GET")
 	r.HandleFunc("/salon/{salonID}", middleware.Authenticate(salonHandler.DeleteSalon)).Methods("DELETE")
+	r.HandleFunc("/salon/{salonID}/submit", middleware.Authenticate(salonHandler.SubmitSalon)).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/approve", middleware.Authenticate(adminOnly(salonHandler.ApproveSalon))).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/reject", middleware.Authenticate(adminOnly(salonHandler.RejectSalon))).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/suspend", middleware.Authenticate(adminOnly(salonHandler.SuspendSalon))).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/status-history", middleware.Authenticate(salonHandler.GetSalonStatusHistory)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/members", middleware.Authenticate(salonHandler.AddSalonMember)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/members/{userID}", middleware.Authenticate(salonHandler.RemoveSalonMember)).Methods("DELETE")
+	r.HandleFunc("/salon/{salonID}/hours", middleware.Authenticate(salonHandler.GetOpeningHours)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/hours", middleware.Authenticate(salonHandler.SetOpeningHours)).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/hours/effective", middleware.Authenticate(salonHandler.GetEffectiveHours)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/hours/exceptions", middleware.Authenticate(salonHandler.ListHoursExceptions)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/hours/exceptions", middleware.Authenticate(salonHandler.AddHoursException)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/hours/exceptions/{exceptionID}", middleware.Authenticate(salonHandler.DeleteHoursException)).Methods("DELETE")
+	r.HandleFunc("/salon/{salonID}/policy", middleware.Authenticate(salonHandler.GetSalonPolicy)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/policy", middleware.Authenticate(salonHandler.SetSalonPolicy)).Methods("PUT")
+	r.HandleFunc("/salon/{salonID}/staff", middleware.Authenticate(salonHandler.AddStaff)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/staff", middleware.Authenticate(salonHandler.ListStaffBySalon)).Methods("GET")
+	r.HandleFunc("/staff/{staffID}", middleware.Authenticate(salonHandler.GetStaffDetails)).Methods("GET")
+	r.HandleFunc("/staff/{staffID}", middleware.Authenticate(salonHandler.UpdateStaff)).Methods("PUT")
+	r.HandleFunc("/staff/{staffID}", middleware.Authenticate(salonHandler.DeleteStaff)).Methods("DELETE")
+	r.HandleFunc("/service/{serviceID}/staff", middleware.Authenticate(salonHandler.ListStaffByService)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/categories", middleware.Authenticate(salonHandler.AddCategory)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/categories", middleware.Authenticate(salonHandler.ListCategoriesBySalon)).Methods("GET")
+	r.HandleFunc("/category/{categoryID}", middleware.Authenticate(salonHandler.UpdateCategory)).Methods("PUT")
+	r.HandleFunc("/category/{categoryID}", middleware.Authenticate(salonHandler.DeleteCategory)).Methods("DELETE")
+	r.HandleFunc("/service/{serviceID}/variants", middleware.Authenticate(salonHandler.AddVariant)).Methods("POST")
+	r.HandleFunc("/variant/{variantID}", middleware.Authenticate(salonHandler.UpdateVariant)).Methods("PUT")
+	r.HandleFunc("/variant/{variantID}", middleware.Authenticate(salonHandler.DeleteVariant)).Methods("DELETE")
+	r.HandleFunc("/service/{serviceID}/addons", middleware.Authenticate(salonHandler.AddAddOn)).Methods("POST")
+	r.HandleFunc("/addon/{addonID}", middleware.Authenticate(salonHandler.UpdateAddOn)).Methods("PUT")
+	r.HandleFunc("/addon/{addonID}", middleware.Authenticate(salonHandler.DeleteAddOn)).Methods("DELETE")
 
 	// User routes
 	r.HandleFunc("/register", userHandler.RegisterHandler).Methods("POST")
 	r.HandleFunc("/login", userHandler.LoginHandler).Methods("POST")
+	r.HandleFunc("/login/mfa", userHandler.LoginMFAHandler).Methods("POST")
+	r.HandleFunc("/login/mfa/enroll", userHandler.LoginMFAEnrollHandler).Methods("POST")
+	r.HandleFunc("/auth/{provider}/login", userHandler.OIDCLoginHandler).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", userHandler.OIDCCallbackHandler).Methods("GET", "POST")
+	r.HandleFunc("/auth/{provider}/link", middleware.Authenticate(userHandler.OIDCLinkHandler)).Methods("GET")
+	r.HandleFunc("/auth/{provider}/link", middleware.Authenticate(userHandler.OIDCUnlinkHandler)).Methods("DELETE")
+	r.HandleFunc("/auth/{provider}/link/callback", middleware.Authenticate(userHandler.OIDCLinkCallbackHandler)).Methods("POST")
+	r.HandleFunc("/token/refresh", userHandler.RefreshTokenHandler).Methods("POST")
+	r.HandleFunc("/verify-email", userHandler.VerifyEmailHandler).Methods("POST")
+	r.HandleFunc("/verify-email/resend", middleware.Authenticate(userHandler.ResendVerificationHandler)).Methods("POST")
+	r.HandleFunc("/password/forgot", userHandler.ForgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/password/reset", userHandler.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/logout", middleware.Authenticate(userHandler.LogoutHandler)).Methods("POST")
+	r.HandleFunc("/logout/all", middleware.Authenticate(userHandler.LogoutAllHandler)).Methods("POST")
 	r.HandleFunc("/profile", middleware.Authenticate(userHandler.ProfileHandler)).Methods("GET")
-	r.HandleFunc("/profile", middleware.Authenticate(userHandler.UpdateProfileHandler)).Methods("PUT")
+	r.HandleFunc("/profile", middleware.Authenticate(userHandler.UpdateProfileHandler)).Methods("PUT", "PATCH")
 	r.HandleFunc("/change-password", middleware.Authenticate(userHandler.ChangePasswordHandler)).Methods("PUT")
 	r.HandleFunc("/profile", middleware.Authenticate(userHandler.DeleteAccountHandler)).Methods("DELETE")
+	r.HandleFunc("/profile/export", middleware.Authenticate(userHandler.ExportDataHandler)).Methods("GET")
+	r.HandleFunc("/account/restore", userHandler.RestoreAccountHandler).Methods("POST")
+	r.HandleFunc("/users/{userID}/role", middleware.Authenticate(adminOnly(userHandler.UpdateRoleHandler))).Methods("PUT")
+	r.HandleFunc("/mfa/totp/enroll", middleware.Authenticate(userHandler.EnrollTOTPHandler)).Methods("POST")
+	r.HandleFunc("/mfa/totp/enable", middleware.Authenticate(userHandler.EnableTOTPHandler)).Methods("POST")
+	r.HandleFunc("/mfa/totp/disable", middleware.Authenticate(userHandler.DisableTOTPHandler)).Methods("POST")
+	r.HandleFunc("/mfa/recovery-codes", middleware.Authenticate(userHandler.RegenerateRecoveryCodesHandler)).Methods("POST")
+	r.HandleFunc("/mfa/required-roles", middleware.Authenticate(adminOnly(userHandler.ListMFARequiredRolesHandler))).Methods("GET")
+	r.HandleFunc("/mfa/required-roles/{role}", middleware.Authenticate(adminOnly(userHandler.SetRoleMFARequiredHandler))).Methods("PUT")
 
 	// Appointment routes
 	r.HandleFunc("/appointment", middleware.Authenticate(appointmentHandler.CreateAppointment)).Methods("POST")
@@ -84,15 +207,19 @@ func main() {
 	r.HandleFunc("/appointments/user/{userID}", middleware.Authenticate(appointmentHandler.ListAppointmentsByUserID)).Methods("GET")
 	r.HandleFunc("/appointments/salon/{salonID}", middleware.Authenticate(appointmentHandler.ListAppointmentsBySalonID)).Methods("GET")
 	r.HandleFunc("/appointments/service/{serviceID}", middleware.Authenticate(appointmentHandler.ListAppointmentsByServiceID)).Methods("GET")
-	r.HandleFunc("/appointments/status/{status}", middleware.Authenticate(appointmentHandler.ListAppointmentsByStatus)).Methods("GET")
+	r.HandleFunc("/appointments/staff/{staffID}", middleware.Authenticate(appointmentHandler.ListAppointmentsByStaffID)).Methods("GET")
+	r.HandleFunc("/appointments/status/{status}", middleware.Authenticate(adminOnly(appointmentHandler.ListAppointmentsByStatus))).Methods("GET")
 	r.HandleFunc("/appointment/{appointmentID}/notification", middleware.Authenticate(appointmentHandler.SetNotificationForAppointment)).Methods("PUT")
-	r.HandleFunc("/appointments/upcoming", middleware.Authenticate(appointmentHandler.ListUpcomingAppointments)).Methods("GET")
-	r.HandleFunc("/appointments/past", middleware.Authenticate(appointmentHandler.ListPastAppointments)).Methods("GET")
-	r.HandleFunc("/appointments/range", middleware.Authenticate(appointmentHandler.ListAppointmentsByDateRange)).Methods("GET")
+	r.HandleFunc("/appointments/upcoming", middleware.Authenticate(adminOnly(appointmentHandler.ListUpcomingAppointments))).Methods("GET")
+	r.HandleFunc("/appointments/past", middleware.Authenticate(adminOnly(appointmentHandler.ListPastAppointments))).Methods("GET")
+	r.HandleFunc("/appointments/range", middleware.Authenticate(adminOnly(appointmentHandler.ListAppointmentsByDateRange))).Methods("GET")
 	r.HandleFunc("/appointment/{appointmentID}/cancel", middleware.Authenticate(appointmentHandler.CancelAppointment)).Methods("PUT")
 	r.HandleFunc("/appointment/{appointmentID}/confirm", middleware.Authenticate(appointmentHandler.ConfirmAppointment)).Methods("PUT")
+	r.HandleFunc("/appointment/{appointmentID}/status", middleware.Authenticate(appointmentHandler.ChangeAppointmentStatus)).Methods("PUT")
+	r.HandleFunc("/appointment/{appointmentID}/status-history", middleware.Authenticate(appointmentHandler.GetAppointmentStatusHistory)).Methods("GET")
 	r.HandleFunc("/appointment/{appointmentID}/reschedule", middleware.Authenticate(appointmentHandler.RescheduleAppointment)).Methods("PUT")
-	r.HandleFunc("/appointments/notification", middleware.Authenticate(appointmentHandler.ListAppointmentsByNotificationSetting)).Methods("GET")
+	r.HandleFunc("/appointment/{appointmentID}/reschedules", middleware.Authenticate(appointmentHandler.GetAppointmentReschedules)).Methods("GET")
+	r.HandleFunc("/appointments/notification", middleware.Authenticate(adminOnly(appointmentHandler.ListAppointmentsByNotificationSetting))).Methods("GET")
 
 	// Availability routes
 	r.HandleFunc("/availability", middleware.Authenticate(availabilityHandler.CreateAvailability)).Methods("POST")
@@ -101,12 +228,17 @@ func main() {
 	r.HandleFunc("/availability/{availabilityID}", middleware.Authenticate(availabilityHandler.DeleteAvailability)).Methods("DELETE")
 	r.HandleFunc("/availabilities/salon/{salonID}", middleware.Authenticate(availabilityHandler.ListAvailabilitiesBySalonID)).Methods("GET")
 	r.HandleFunc("/availabilities/service/{serviceID}", middleware.Authenticate(availabilityHandler.ListAvailabilitiesByServiceID)).Methods("GET")
-	r.HandleFunc("/availabilities/status/{status}", middleware.Authenticate(availabilityHandler.ListAvailabilitiesByStatus)).Methods("GET")
+	r.HandleFunc("/availabilities/staff/{staffID}", middleware.Authenticate(availabilityHandler.ListAvailabilitiesByStaffID)).Methods("GET")
+	r.HandleFunc("/availabilities/status/{status}", middleware.Authenticate(adminOnly(availabilityHandler.ListAvailabilitiesByStatus))).Methods("GET")
 	r.HandleFunc("/availabilities/open/{serviceID}/{salonID}", middleware.Authenticate(availabilityHandler.ListOpenAvailabilities)).Methods("GET")
 	r.HandleFunc("/availability/{availabilityID}/book", middleware.Authenticate(availabilityHandler.BookAvailability)).Methods("PUT")
 	r.HandleFunc("/availability/{availabilityID}/cancel", middleware.Authenticate(availabilityHandler.CancelBooking)).Methods("PUT")
 	r.HandleFunc("/availabilities/booked/{serviceID}/{salonID}", middleware.Authenticate(availabilityHandler.ListBookedAvailabilities)).Methods("GET")
 	r.HandleFunc("/availabilities/range", middleware.Authenticate(availabilityHandler.ListAvailabilitiesByDateRange)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/slot-templates", middleware.Authenticate(availabilityHandler.CreateSlotTemplate)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/slot-templates", middleware.Authenticate(availabilityHandler.ListSlotTemplates)).Methods("GET")
+	r.HandleFunc("/slot-template/{templateID}", middleware.Authenticate(availabilityHandler.DeleteSlotTemplate)).Methods("DELETE")
+	r.HandleFunc("/salon/{salonID}/slots/generate", middleware.Authenticate(availabilityHandler.GenerateSlots)).Methods("POST")
 
 	// Define your review routes
 	r.HandleFunc("/reviews", middleware.Authenticate(reviewHandler.CreateReview)).Methods("POST")
@@ -117,6 +249,22 @@ func main() {
 	r.HandleFunc("/reviews/user/{userID}", middleware.Authenticate(reviewHandler.ListReviewsByUserID)).Methods("GET")
 	r.HandleFunc("/reviews/rating/{rating}", middleware.Authenticate(reviewHandler.ListReviewsByRating)).Methods("GET")
 
+	// Search routes
+	r.HandleFunc("/search", middleware.Authenticate(searchHandler.Search)).Methods("GET")
+
+	// Media routes
+	r.HandleFunc("/salon/{salonID}/photos", middleware.Authenticate(mediaHandler.UploadSalonPhoto)).Methods("POST")
+	r.HandleFunc("/salon/{salonID}/photos", middleware.Authenticate(mediaHandler.ListSalonPhotos)).Methods("GET")
+	r.HandleFunc("/salon/{salonID}/photos/order", middleware.Authenticate(mediaHandler.ReorderSalonPhotos)).Methods("PUT")
+	r.HandleFunc("/service/{serviceID}/photos", middleware.Authenticate(mediaHandler.UploadServicePhoto)).Methods("POST")
+	r.HandleFunc("/service/{serviceID}/photos", middleware.Authenticate(mediaHandler.ListServicePhotos)).Methods("GET")
+	r.HandleFunc("/service/{serviceID}/photos/order", middleware.Authenticate(mediaHandler.ReorderServicePhotos)).Methods("PUT")
+	r.HandleFunc("/photo/{photoID}", middleware.Authenticate(mediaHandler.DeletePhoto)).Methods("DELETE")
+	r.HandleFunc("/profile/image", middleware.Authenticate(mediaHandler.UploadProfileImage)).Methods("POST")
+	r.HandleFunc("/profile/image", middleware.Authenticate(mediaHandler.DeleteProfileImage)).Methods("DELETE")
+	// Image tags cannot send a token, so the files are public behind their random keys
+	r.HandleFunc("/media/{key:.+}", mediaHandler.ServeMedia).Methods("GET")
+
 	// Swagger UI and JSON routes
 	fs := http.FileServer(http.Dir("swaggerui"))
 	r.PathPrefix("/swaggerui/").Handler(http.StripPrefix("/swaggerui/", fs))
@@ -29,17 +29,85 @@ type Appointment struct {
 	// example: 3
 	ServiceID int `json:"service_id"`
 
+	// The ID of the staff member booked for the appointment, if the customer picked a stylist.
+	//
+	// required: false
+	// example: 4
+	StaffID *int `json:"staff_id,omitempty"`
+
+	// The ID of the variant of the service booked, required when the service has variants.
+	//
+	// required: false
+	// example: 6
+	VariantID *int `json:"variant_id,omitempty"`
+
+	// The IDs of the add-ons booked along with the service.
+	//
+	// required: false
+	// example: [9]
+	AddOnIDs []int `json:"addon_ids"`
+
+	// The price of the service or variant plus that of the add-ons, worked out when booking.
+	//
+	// required: false
+	// example: 45.00
+	TotalPrice float64 `json:"total_price"`
+
+	// The duration of the service or variant plus that of the add-ons, worked out when booking.
+	//
+	// required: false
+	// example: "PT1H15M"
+	TotalDuration Duration `json:"total_duration"`
+
+	// The availability slot the appointment is booked into. The slot is
+	// claimed when the appointment is created and gives it its salon,
+	// service, staff member and date and time.
+	//
+	// required: false
+	// example: 101
+	AvailabilityID *int `json:"availability_id,omitempty"`
+
 	// The date and time of the appointment.
 	//
 	// required: true
 	// example: "2023-07-12T14:00:00Z"
 	DateTime string `json:"date_time"`
 
-	// The current status of the appointment (e.g., "Confirmed", "Cancelled").
+	// When the service ends, the date and time plus the total duration. The
+	// buffers of the service are not included.
 	//
-	// required: true
-	// example: "Confirmed"
-	Status string `json:"status"`
+	// required: false
+	// example: "2023-07-12T15:15:00Z"
+	EndDateTime string `json:"end_date_time"`
+
+	// The current status of the appointment: "requested", "confirmed",
+	// "checked_in", "in_service", "completed", "cancelled_by_customer",
+	// "cancelled_by_salon" or "no_show". New appointments are requested, and
+	// the status only changes through the status endpoints.
+	//
+	// required: false
+	// example: "confirmed"
+	Status AppointmentStatus `json:"status"`
+
+	// How often the appointment was rescheduled.
+	//
+	// required: false
+	// example: 1
+	RescheduleCount int `json:"reschedule_count"`
+
+	// The fee charged for cancelling the appointment, under the salon's
+	// policy. Only set on cancelled appointments.
+	//
+	// required: false
+	// example: 22.5
+	CancellationFee *float64 `json:"cancellation_fee,omitempty"`
+
+	// The part of the price to refund after a cancellation, the price less
+	// the fee. Only set on cancelled appointments.
+	//
+	// required: false
+	// example: 22.5
+	RefundAmount *float64 `json:"refund_amount,omitempty"`
 
 	// User's notification settings for the appointment (e.g., "Email", "SMS").
 	//
@@ -0,0 +1,81 @@
+// bookmysalon/models/appointment_status.go
+
+package models
+
+// AppointmentStatus is a state in the lifecycle of an appointment.
+type AppointmentStatus string
+
+// Lifecycle states of an appointment. New appointments are requested; the
+// salon confirms them, checks the customer in, starts and completes the
+// service. Cancellations and no-shows end an appointment early.
+const (
+	AppointmentRequested           AppointmentStatus = "requested"
+	AppointmentConfirmed           AppointmentStatus = "confirmed"
+	AppointmentCheckedIn           AppointmentStatus = "checked_in"
+	AppointmentInService           AppointmentStatus = "in_service"
+	AppointmentCompleted           AppointmentStatus = "completed"
+	AppointmentCancelledByCustomer AppointmentStatus = "cancelled_by_customer"
+	AppointmentCancelledBySalon    AppointmentStatus = "cancelled_by_salon"
+	AppointmentNoShow              AppointmentStatus = "no_show"
+)
+
+// IsValid reports whether status is one of the appointment lifecycle states.
+func (status AppointmentStatus) IsValid() bool {
+	switch status {
+	case AppointmentRequested, AppointmentConfirmed, AppointmentCheckedIn, AppointmentInService, AppointmentCompleted,
+		AppointmentCancelledByCustomer, AppointmentCancelledBySalon, AppointmentNoShow:
+		return true
+	}
+	return false
+}
+
+// IsCancelled reports whether the appointment was called off by either side.
+func (status AppointmentStatus) IsCancelled() bool {
+	return status == AppointmentCancelledByCustomer || status == AppointmentCancelledBySalon
+}
+
+// AppointmentStatusChange records a change of the status of an appointment.
+// swagger:model
+type AppointmentStatusChange struct {
+	// The unique ID for the change.
+	//
+	// required: true
+	// example: 7
+	HistoryID int `json:"history_id"`
+
+	// The ID of the appointment.
+	//
+	// required: true
+	// example: 1
+	AppointmentID int `json:"appointment_id"`
+
+	// The status before the change.
+	//
+	// required: true
+	// example: "requested"
+	FromStatus AppointmentStatus `json:"from_status"`
+
+	// The status after the change.
+	//
+	// required: true
+	// example: "confirmed"
+	ToStatus AppointmentStatus `json:"to_status"`
+
+	// The reason given for the change.
+	//
+	// required: false
+	// example: "Stylist is ill."
+	Reason string `json:"reason"`
+
+	// The ID of the user who made the change, if they still exist.
+	//
+	// required: false
+	// example: 2
+	ActorID *int `json:"actor_id,omitempty"`
+
+	// The time of the change.
+	//
+	// required: true
+	// example: "2026-10-17T10:50:00"
+	ChangedAt string `json:"changed_at"`
+}
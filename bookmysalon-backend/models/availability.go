@@ -2,6 +2,12 @@
 
 package models
 
+// Statuses of an availability slot.
+const (
+	AvailabilityAvailable = "available"
+	AvailabilityBooked    = "booked"
+)
+
 // Availability represents the available time slots for a salon service.
 // swagger:model
 type Availability struct {
@@ -23,6 +29,12 @@ type Availability struct {
 	// example: 12
 	ServiceID int `json:"service_id"`
 
+	// The ID of the staff member taking bookings in this slot, if the slot is for a specific stylist.
+	//
+	// required: false
+	// example: 4
+	StaffID *int `json:"staff_id,omitempty"`
+
 	// The starting date and time of the available slot.
 	//
 	// required: true
@@ -35,9 +47,9 @@ type Availability struct {
 	// example: "2023-07-10T11:00:00Z"
 	EndDateTime string `json:"end_date_time"`
 
-	// The status of the availability (e.g., "Open", "Booked").
+	// The status of the availability, "available" or "booked". Defaults to "available".
 	//
-	// required: true
-	// example: "Open"
+	// required: false
+	// example: "available"
 	Status string `json:"status"`
 }
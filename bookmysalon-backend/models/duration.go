@@ -0,0 +1,285 @@
+// bookmysalon/models/duration.go
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDuration = errors.New("duration must be given as ISO-8601, such as PT45M, or as a number of minutes")
+
+// Duration is a length of time, such as that of a service. It is stored in
+// INTERVAL columns and written to JSON as ISO-8601, e.g. "PT1H15M". JSON input
+// may also be a number of minutes, e.g. 75.
+// swagger:strfmt duration
+type Duration time.Duration
+
+// Std returns the duration as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// String formats the duration as ISO-8601 with hours, minutes and seconds,
+// e.g. "PT1H15M" or "PT0S".
+func (d Duration) String() string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+
+	hours := time.Duration(d) / time.Hour
+	minutes := time.Duration(d) % time.Hour / time.Minute
+	rest := time.Duration(d) % time.Minute
+	if hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+	}
+	if minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+	}
+	if rest > 0 {
+		b.WriteString(strconv.FormatFloat(rest.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// MarshalJSON writes the duration as an ISO-8601 string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads an ISO-8601 string or a number of minutes.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var minutes float64
+	if err := json.Unmarshal(data, &minutes); err == nil {
+		return d.setMinutes(minutes)
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ErrInvalidDuration
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		*d = 0
+		return nil
+	}
+	if minutes, err := strconv.ParseFloat(value, 64); err == nil {
+		return d.setMinutes(minutes)
+	}
+
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Duration) setMinutes(minutes float64) error {
+	if math.IsNaN(minutes) || math.IsInf(minutes, 0) || math.Abs(minutes) > math.MaxInt64/float64(time.Minute) {
+		return ErrInvalidDuration
+	}
+	*d = Duration(time.Duration(math.Round(minutes * float64(time.Minute))))
+	return nil
+}
+
+// Value stores the duration as a number of seconds, which Postgres reads as an interval.
+func (d Duration) Value() (driver.Value, error) {
+	return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64) + " seconds", nil
+}
+
+// Scan reads an interval in the output format of Postgres, e.g. "00:45:00" or
+// "1 day 02:00:00", or in ISO-8601. NULL reads as zero. Like Postgres, a month
+// counts as 30 days and a year as 365.25 days.
+func (d *Duration) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case int64:
+		*d = Duration(time.Duration(v) * time.Second)
+		return nil
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Second)))
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a duration", src)
+	}
+
+	if strings.HasPrefix(strings.TrimPrefix(value, "-"), "P") {
+		parsed, err := ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	}
+
+	parsed, err := parseInterval(value)
+	if err != nil {
+		return fmt.Errorf("cannot scan interval %q: %v", value, err)
+	}
+	*d = parsed
+	return nil
+}
+
+// ParseDuration parses an ISO-8601 duration with days, hours, minutes and
+// seconds, e.g. "PT45M", "P1DT2H" or "PT1.5S". Weeks, months and years are not
+// accepted, as their length in time varies.
+func ParseDuration(value string) (Duration, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, ErrInvalidDuration
+	}
+	s = s[1:]
+
+	var total float64
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, ErrInvalidDuration
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+
+		end := strings.IndexAny(s, "DHMS")
+		if end <= 0 {
+			return 0, ErrInvalidDuration
+		}
+		number, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil || number < 0 {
+			return 0, ErrInvalidDuration
+		}
+
+		switch unit := s[end]; {
+		case unit == 'D' && !inTime:
+			total += number * float64(24*time.Hour)
+		case unit == 'H' && inTime:
+			total += number * float64(time.Hour)
+		case unit == 'M' && inTime:
+			total += number * float64(time.Minute)
+		case unit == 'S' && inTime:
+			total += number * float64(time.Second)
+		default:
+			return 0, ErrInvalidDuration
+		}
+		s = s[end+1:]
+	}
+
+	if total > math.MaxInt64 {
+		return 0, ErrInvalidDuration
+	}
+	if negative {
+		total = -total
+	}
+	return Duration(time.Duration(math.Round(total))), nil
+}
+
+// intervalUnits are the lengths of the units in the Postgres interval output.
+var intervalUnits = map[string]float64{
+	"year":  365.25 * 24 * float64(time.Hour),
+	"years": 365.25 * 24 * float64(time.Hour),
+	"mon":   30 * 24 * float64(time.Hour),
+	"mons":  30 * 24 * float64(time.Hour),
+	"day":   24 * float64(time.Hour),
+	"days":  24 * float64(time.Hour),
+}
+
+// parseInterval parses the default Postgres output of an interval, a list of
+// "<number> <unit>" pairs optionally followed by a signed clock "[-]HH:MM:SS[.ffffff]".
+func parseInterval(value string) (Duration, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, ErrInvalidDuration
+	}
+
+	var total float64
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Contains(field, ":") {
+			clock, err := parseClock(field)
+			if err != nil {
+				return 0, err
+			}
+			total += clock
+			continue
+		}
+
+		if i+1 >= len(fields) {
+			return 0, ErrInvalidDuration
+		}
+		number, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+		unit, ok := intervalUnits[fields[i+1]]
+		if !ok {
+			return 0, ErrInvalidDuration
+		}
+		total += number * unit
+		i++
+	}
+
+	return Duration(time.Duration(math.Round(total))), nil
+}
+
+// parseClock parses "[-]HH:MM[:SS[.ffffff]]" into nanoseconds.
+func parseClock(value string) (float64, error) {
+	sign := 1.0
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+		value = value[1:]
+	} else {
+		value = strings.TrimPrefix(value, "+")
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, ErrInvalidDuration
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrInvalidDuration
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, ErrInvalidDuration
+	}
+	var seconds float64
+	if len(parts) == 3 {
+		if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return 0, ErrInvalidDuration
+		}
+	}
+
+	total := float64(hours)*float64(time.Hour) + float64(minutes)*float64(time.Minute) + seconds*float64(time.Second)
+	return sign * total, nil
+}
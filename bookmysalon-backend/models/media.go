@@ -0,0 +1,79 @@
+// bookmysalon/models/media.go
+
+package models
+
+// Photo is an uploaded image of a salon, a service or a user.
+// swagger:model
+type Photo struct {
+	// The unique ID for the photo.
+	//
+	// required: true
+	// example: 12
+	PhotoID int `json:"photo_id"`
+
+	// The ID of the salon the photo shows, if it is a salon photo.
+	//
+	// required: false
+	// example: 1
+	SalonID *int `json:"salon_id,omitempty"`
+
+	// The ID of the service the photo shows, if it is a service photo.
+	//
+	// required: false
+	// example: 4
+	ServiceID *int `json:"service_id,omitempty"`
+
+	// The ID of the user the photo shows, if it is a profile image.
+	//
+	// required: false
+	// example: 7
+	UserID *int `json:"user_id,omitempty"`
+
+	// The URL of the image.
+	//
+	// required: true
+	// example: "/media/salons/1/9f86d081884c7d65.jpg"
+	URL string `json:"url"`
+
+	// The URL of a small JPEG version of the image, at most 320 pixels wide and high.
+	//
+	// required: true
+	// example: "/media/salons/1/9f86d081884c7d65_thumb.jpg"
+	ThumbnailURL string `json:"thumbnail_url"`
+
+	// The MIME type of the image.
+	//
+	// required: true
+	// example: "image/jpeg"
+	ContentType string `json:"content_type"`
+
+	// The size of the image in bytes.
+	//
+	// required: true
+	// example: 482113
+	Size int64 `json:"size"`
+
+	// The width of the image in pixels.
+	//
+	// required: true
+	// example: 1600
+	Width int `json:"width"`
+
+	// The height of the image in pixels.
+	//
+	// required: true
+	// example: 1200
+	Height int `json:"height"`
+
+	// The place of the photo among the photos of its owner, lowest first.
+	//
+	// required: true
+	// example: 0
+	Position int `json:"position"`
+
+	// The time the photo was uploaded.
+	//
+	// required: true
+	// example: "2026-10-17T10:40:00Z"
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,73 @@
+// bookmysalon/models/reschedule.go
+
+package models
+
+// RescheduleRequest asks to move an appointment to a new time, or into an
+// availability slot.
+// swagger:model
+type RescheduleRequest struct {
+	// The new date and time. Ignored when a slot is given.
+	//
+	// required: false
+	// example: "2023-07-14T10:00:00"
+	NewDateTime string `json:"newDateTime"`
+
+	// The availability slot to move the appointment into. The appointment
+	// takes the start and staff member of the slot.
+	//
+	// required: false
+	// example: 102
+	AvailabilityID *int `json:"availability_id,omitempty"`
+}
+
+// AppointmentReschedule records a move of an appointment.
+// swagger:model
+type AppointmentReschedule struct {
+	// The unique ID for the reschedule.
+	//
+	// required: true
+	// example: 4
+	RescheduleID int `json:"reschedule_id"`
+
+	// The ID of the appointment.
+	//
+	// required: true
+	// example: 1
+	AppointmentID int `json:"appointment_id"`
+
+	// The date and time before the move.
+	//
+	// required: true
+	// example: "2023-07-12T14:00:00"
+	FromDateTime string `json:"from_date_time"`
+
+	// The date and time after the move.
+	//
+	// required: true
+	// example: "2023-07-14T10:00:00"
+	ToDateTime string `json:"to_date_time"`
+
+	// The slot the appointment let go of, if it held one.
+	//
+	// required: false
+	// example: 101
+	FromAvailabilityID *int `json:"from_availability_id,omitempty"`
+
+	// The slot the appointment moved into, if any.
+	//
+	// required: false
+	// example: 102
+	ToAvailabilityID *int `json:"to_availability_id,omitempty"`
+
+	// The ID of the user who moved the appointment, if they still exist.
+	//
+	// required: false
+	// example: 7
+	ActorID *int `json:"actor_id,omitempty"`
+
+	// The time of the move.
+	//
+	// required: true
+	// example: "2023-07-10T09:30:00"
+	RescheduledAt string `json:"rescheduled_at"`
+}
@@ -23,23 +23,92 @@ type Salon struct {
 	// example: "123 Beauty St, Pleasantville, 12345"
 	Address string `json:"address"`
 
+	// The street and house number of the salon.
+	//
+	// required: false
+	// example: "123 Beauty St"
+	Street string `json:"street"`
+
+	// The city of the salon.
+	//
+	// required: false
+	// example: "Pleasantville"
+	City string `json:"city"`
+
+	// The postal code of the salon.
+	//
+	// required: false
+	// example: "12345"
+	PostalCode string `json:"postal_code"`
+
+	// The country of the salon, as an ISO 3166-1 alpha-2 code.
+	//
+	// required: false
+	// example: "US"
+	Country string `json:"country"`
+
+	// The latitude of the salon. Filled in from the address when left out.
+	//
+	// required: false
+	// example: 40.7128
+	Latitude *float64 `json:"latitude,omitempty"`
+
+	// The longitude of the salon. Filled in from the address when left out.
+	//
+	// required: false
+	// example: -74.006
+	Longitude *float64 `json:"longitude,omitempty"`
+
 	// The contact details for the salon.
 	//
 	// required: true
 	// example: "(123) 456-7890"
 	ContactDetails string `json:"contact_details"`
 
-	// URL of the photos related to the salon.
+	// The URL of the cover photo of the salon, the first of its uploaded
+	// photos. Read-only; photos are uploaded to /salon/{salonID}/photos.
 	//
 	// required: false
-	// example: "http://example.com/path/to/salon/photo.jpg"
+	// example: "/media/salons/1/9f86d081884c7d65.jpg"
 	Photos string `json:"photos"`
 
-	// The average rating for the salon out of 5.
+	// The average rating for the salon out of 5, worked out from its reviews.
+	// It is ignored on create and update.
 	//
-	// required: true
+	// required: false
 	// example: 4.5
 	AverageRating float64 `json:"average_rating"`
+
+	// The IANA timezone the salon's opening hours are given in. Defaults to UTC.
+	//
+	// required: false
+	// example: "Europe/Berlin"
+	Timezone string `json:"timezone"`
+
+	// The lifecycle state of the salon: draft, submitted, approved, rejected
+	// or suspended. Read-only; it changes through the review endpoints.
+	//
+	// required: false
+	// example: "approved"
+	Status string `json:"status"`
+
+	// The reason given for the last rejection or suspension of the salon.
+	//
+	// required: false
+	// example: "Please add a photo of the salon front."
+	StatusReason string `json:"status_reason,omitempty"`
+}
+
+// NearbySalon is a salon found by a location search.
+// swagger:model
+type NearbySalon struct {
+	Salon
+
+	// The distance from the searched location in kilometers.
+	//
+	// required: true
+	// example: 1.8
+	DistanceKm float64 `json:"distance_km"`
 }
 
 // Service represents a specific service provided by a salon.
@@ -69,15 +138,45 @@ type Service struct {
 	// example: "A stylish haircut tailored to your preference."
 	Description string `json:"description"`
 
-	// The duration of the service.
+	// The duration of the service, as ISO-8601 or a number of minutes.
 	//
 	// required: true
-	// example: "45 minutes"
-	Duration string `json:"duration"`
+	// example: "PT45M"
+	Duration Duration `json:"duration"`
+
+	// Preparation time before the service, during which the salon is busy too.
+	//
+	// required: false
+	// example: "PT5M"
+	BufferBefore Duration `json:"buffer_before"`
+
+	// Cleanup time after the service, during which the salon is busy too.
+	//
+	// required: false
+	// example: "PT10M"
+	BufferAfter Duration `json:"buffer_after"`
 
 	// The price of the service.
 	//
 	// required: true
 	// example: 25.00
 	Price float64 `json:"price"`
+
+	// The name of the salon's category the service is listed under, if any.
+	// Searches match it regardless of case.
+	//
+	// required: false
+	// example: "Hair"
+	Category string `json:"category"`
+
+	// The versions of the service with their own price and duration. A
+	// service with variants is booked as one of them.
+	//
+	// required: false
+	Variants []ServiceVariant `json:"variants"`
+
+	// The optional extras that can be booked with the service.
+	//
+	// required: false
+	AddOns []ServiceAddOn `json:"addons"`
 }
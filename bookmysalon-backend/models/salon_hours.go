@@ -0,0 +1,88 @@
+// bookmysalon/models/salon_hours.go
+
+package models
+
+// OpeningHours is one interval of a salon's weekly opening hours.
+// swagger:model
+type OpeningHours struct {
+	// The day of the week, 0 being Sunday.
+	//
+	// required: true
+	// example: 1
+	Weekday int `json:"weekday"`
+
+	// The local time the salon opens, as HH:MM.
+	//
+	// required: true
+	// example: "09:00"
+	OpensAt string `json:"opens_at"`
+
+	// The local time the salon closes, as HH:MM. "24:00" is midnight.
+	//
+	// required: true
+	// example: "18:00"
+	ClosesAt string `json:"closes_at"`
+}
+
+// HoursException replaces a salon's weekly hours on a range of dates, for
+// holidays, special hours or temporary closures.
+// swagger:model
+type HoursException struct {
+	// The unique ID for the exception.
+	//
+	// required: true
+	// example: 3
+	ExceptionID int `json:"exception_id"`
+
+	// The ID of the salon.
+	//
+	// required: true
+	// example: 1
+	SalonID int `json:"salon_id"`
+
+	// The first date the exception applies to.
+	//
+	// required: true
+	// example: "2023-12-24"
+	StartDate string `json:"start_date"`
+
+	// The last date the exception applies to. Defaults to the start date.
+	//
+	// required: false
+	// example: "2023-12-26"
+	EndDate string `json:"end_date"`
+
+	// The local time the salon opens on these dates, as HH:MM. Leave both times
+	// out to close the salon all day.
+	//
+	// required: false
+	// example: "10:00"
+	OpensAt *string `json:"opens_at,omitempty"`
+
+	// The local time the salon closes on these dates, as HH:MM.
+	//
+	// required: false
+	// example: "14:00"
+	ClosesAt *string `json:"closes_at,omitempty"`
+
+	// Why the hours differ, shown to customers.
+	//
+	// required: false
+	// example: "Christmas"
+	Reason string `json:"reason"`
+}
+
+// DayHours are the effective opening hours of a salon on one date.
+// swagger:model
+type DayHours struct {
+	// The date, in the salon's timezone.
+	//
+	// required: true
+	// example: "2023-12-24"
+	Date string `json:"date"`
+
+	// The intervals the salon is open; empty when it is closed.
+	//
+	// required: true
+	Hours []OpeningHours `json:"hours"`
+}
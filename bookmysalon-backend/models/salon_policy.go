@@ -0,0 +1,41 @@
+// bookmysalon/models/salon_policy.go
+
+package models
+
+// SalonPolicy holds the cancellation and rescheduling rules of a salon. The
+// zero value allows free cancellation and unlimited reschedules at any time.
+// swagger:model
+type SalonPolicy struct {
+	// The ID of the salon.
+	//
+	// required: true
+	// example: 1
+	SalonID int `json:"salon_id"`
+
+	// How long before the start customers can cancel for free. Later
+	// cancellations pay the late fee, and the appointment can no longer be
+	// rescheduled by the customer.
+	//
+	// required: false
+	// example: "PT24H"
+	FreeCancellationNotice Duration `json:"free_cancellation_notice"`
+
+	// The share of the price charged for a late cancellation, from 0 to 100.
+	//
+	// required: false
+	// example: 50
+	LateCancellationFeePercent float64 `json:"late_cancellation_fee_percent"`
+
+	// How often a customer may reschedule an appointment. Leave out for no limit.
+	//
+	// required: false
+	// example: 2
+	MaxReschedules *int `json:"max_reschedules"`
+
+	// How long after booking a requested appointment is cancelled if the
+	// salon has not confirmed it. Zero turns this off.
+	//
+	// required: false
+	// example: "PT12H"
+	ConfirmationWindow Duration `json:"confirmation_window"`
+}
@@ -0,0 +1,68 @@
+// bookmysalon/models/salon_status.go
+
+package models
+
+// Lifecycle states of a salon. Only approved salons are listed, found by
+// search and open for bookings.
+const (
+	SalonStatusDraft     = "draft"
+	SalonStatusSubmitted = "submitted"
+	SalonStatusApproved  = "approved"
+	SalonStatusRejected  = "rejected"
+	SalonStatusSuspended = "suspended"
+)
+
+// IsValidSalonStatus reports whether status is one of the salon lifecycle states.
+func IsValidSalonStatus(status string) bool {
+	switch status {
+	case SalonStatusDraft, SalonStatusSubmitted, SalonStatusApproved, SalonStatusRejected, SalonStatusSuspended:
+		return true
+	}
+	return false
+}
+
+// SalonStatusChange records a change of the status of a salon.
+// swagger:model
+type SalonStatusChange struct {
+	// The unique ID for the change.
+	//
+	// required: true
+	// example: 3
+	HistoryID int `json:"history_id"`
+
+	// The ID of the salon.
+	//
+	// required: true
+	// example: 1
+	SalonID int `json:"salon_id"`
+
+	// The status before the change.
+	//
+	// required: true
+	// example: "submitted"
+	FromStatus string `json:"from_status"`
+
+	// The status after the change.
+	//
+	// required: true
+	// example: "rejected"
+	ToStatus string `json:"to_status"`
+
+	// The reason given for the change.
+	//
+	// required: false
+	// example: "Please add a photo of the salon front."
+	Reason string `json:"reason"`
+
+	// The ID of the user who made the change, if they still exist.
+	//
+	// required: false
+	// example: 2
+	ActorID *int `json:"actor_id,omitempty"`
+
+	// The time of the change.
+	//
+	// required: true
+	// example: "2026-10-17T10:50:00"
+	ChangedAt string `json:"changed_at"`
+}
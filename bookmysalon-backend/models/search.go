@@ -0,0 +1,84 @@
+// bookmysalon/models/search.go
+
+package models
+
+// SearchResult is a page of the salons found by a search, along with the facet
+// counts over all of them.
+// swagger:model
+type SearchResult struct {
+	// The number of salons matching the search.
+	//
+	// required: true
+	// example: 42
+	Total int `json:"total"`
+
+	// The salons on this page.
+	//
+	// required: true
+	Salons []SalonMatch `json:"salons"`
+
+	// The number of salons per value of each filter.
+	//
+	// required: true
+	Facets SearchFacets `json:"facets"`
+}
+
+// SalonMatch is a salon found by a search, with the services that matched.
+// swagger:model
+type SalonMatch struct {
+	Salon
+
+	// The distance from the searched location in kilometers, if one was given.
+	//
+	// required: false
+	// example: 1.8
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+
+	// The lowest price of the matching services.
+	//
+	// required: false
+	// example: 20.5
+	MinPrice *float64 `json:"min_price,omitempty"`
+
+	// The services of the salon matching the search, best match first.
+	//
+	// required: true
+	Services []Service `json:"services"`
+}
+
+// SearchFacets holds the facet counts of a search. Each facet is counted with
+// all filters applied except its own, so clients can show how many salons
+// the other values would give.
+// swagger:model
+type SearchFacets struct {
+	// The number of salons offering a matching service per category.
+	//
+	// required: true
+	Categories []FacetCount `json:"categories"`
+
+	// The number of salons offering a matching service per price range.
+	//
+	// required: true
+	Prices []FacetCount `json:"prices"`
+
+	// The number of salons rated at least 4, 3, 2 and 1.
+	//
+	// required: true
+	Ratings []FacetCount `json:"ratings"`
+}
+
+// FacetCount is the number of salons for one value of a facet.
+// swagger:model
+type FacetCount struct {
+	// The value of the facet.
+	//
+	// required: true
+	// example: "haircut"
+	Value string `json:"value"`
+
+	// The number of salons.
+	//
+	// required: true
+	// example: 12
+	Count int `json:"count"`
+}
@@ -0,0 +1,118 @@
+// bookmysalon/models/service_catalog.go
+
+package models
+
+// ServiceCategory is a section of a salon's menu, such as "Hair" or "Nails".
+// swagger:model
+type ServiceCategory struct {
+	// The unique ID for the category.
+	//
+	// required: true
+	// example: 2
+	CategoryID int `json:"category_id"`
+
+	// The ID of the salon the category belongs to.
+	//
+	// required: true
+	// example: 1
+	SalonID int `json:"salon_id"`
+
+	// The name of the category, unique within the salon regardless of case.
+	//
+	// required: true
+	// example: "Hair"
+	Name string `json:"name"`
+
+	// A brief description of the category.
+	//
+	// required: false
+	// example: "Cuts, colours and styling."
+	Description string `json:"description"`
+
+	// The place of the category on the menu, lowest first.
+	//
+	// required: false
+	// example: 0
+	Position int `json:"position"`
+}
+
+// ServiceVariant is a version of a service with its own price and duration,
+// such as a haircut for short or long hair.
+// swagger:model
+type ServiceVariant struct {
+	// The unique ID for the variant.
+	//
+	// required: true
+	// example: 6
+	VariantID int `json:"variant_id"`
+
+	// The ID of the service the variant belongs to.
+	//
+	// required: true
+	// example: 3
+	ServiceID int `json:"service_id"`
+
+	// The name of the variant.
+	//
+	// required: true
+	// example: "Long hair"
+	Name string `json:"name"`
+
+	// The price of the variant, replacing the price of the service.
+	//
+	// required: true
+	// example: 35.00
+	Price float64 `json:"price"`
+
+	// The duration of the variant, replacing the duration of the service.
+	//
+	// required: true
+	// example: "PT1H"
+	Duration Duration `json:"duration"`
+
+	// The place of the variant among those of the service, lowest first.
+	//
+	// required: false
+	// example: 1
+	Position int `json:"position"`
+}
+
+// ServiceAddOn is an optional extra that can be booked along with a service.
+// swagger:model
+type ServiceAddOn struct {
+	// The unique ID for the add-on.
+	//
+	// required: true
+	// example: 9
+	AddOnID int `json:"addon_id"`
+
+	// The ID of the service the add-on can be booked with.
+	//
+	// required: true
+	// example: 3
+	ServiceID int `json:"service_id"`
+
+	// The name of the add-on.
+	//
+	// required: true
+	// example: "Deep conditioning"
+	Name string `json:"name"`
+
+	// The price added to the booking.
+	//
+	// required: true
+	// example: 10.00
+	Price float64 `json:"price"`
+
+	// The time added to the booking.
+	//
+	// required: false
+	// example: "PT15M"
+	Duration Duration `json:"duration"`
+
+	// The place of the add-on among those of the service, lowest first.
+	//
+	// required: false
+	// example: 0
+	Position int `json:"position"`
+}
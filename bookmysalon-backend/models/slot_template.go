@@ -0,0 +1,51 @@
+// bookmysalon/models/slot_template.go
+
+package models
+
+// SlotTemplate is a recurring weekly window in which a salon offers a
+// service. Slot generation fills it with availabilities of the service's
+// length, buffers included, for a number of weeks ahead.
+// swagger:model
+type SlotTemplate struct {
+	// The unique ID for the template.
+	//
+	// required: true
+	// example: 3
+	TemplateID int `json:"template_id"`
+
+	// The ID of the salon.
+	//
+	// required: true
+	// example: 5
+	SalonID int `json:"salon_id"`
+
+	// The ID of the service offered in the window.
+	//
+	// required: true
+	// example: 12
+	ServiceID int `json:"service_id"`
+
+	// The ID of the staff member taking the bookings, if the slots are for a specific stylist.
+	//
+	// required: false
+	// example: 4
+	StaffID *int `json:"staff_id,omitempty"`
+
+	// The day of the week, 0 being Sunday.
+	//
+	// required: true
+	// example: 1
+	Weekday int `json:"weekday"`
+
+	// The local time the window starts, as HH:MM.
+	//
+	// required: true
+	// example: "09:00"
+	StartsAt string `json:"starts_at"`
+
+	// The local time the window ends, as HH:MM. "24:00" is midnight.
+	//
+	// required: true
+	// example: "13:00"
+	EndsAt string `json:"ends_at"`
+}
@@ -0,0 +1,55 @@
+// bookmysalon/models/staff.go
+
+package models
+
+// Staff represents a stylist or other staff member working at a salon.
+// swagger:model
+type Staff struct {
+	// The unique ID for the staff member.
+	//
+	// required: true
+	// example: 4
+	StaffID int `json:"staff_id"`
+
+	// The ID of the salon the staff member works at.
+	//
+	// required: true
+	// example: 1
+	SalonID int `json:"salon_id"`
+
+	// The ID of the user account of the staff member, if they have one.
+	//
+	// required: false
+	// example: 12
+	UserID *int `json:"user_id,omitempty"`
+
+	// The name shown to customers.
+	//
+	// required: true
+	// example: "Jamie"
+	Name string `json:"name"`
+
+	// A short introduction of the staff member.
+	//
+	// required: false
+	// example: "Colour specialist with ten years of experience."
+	Bio string `json:"bio"`
+
+	// URL to a photo of the staff member.
+	//
+	// required: false
+	// example: "http://example.com/path/to/staff/photo.jpg"
+	Photo string `json:"photo"`
+
+	// Whether the staff member can currently be booked.
+	//
+	// required: true
+	// example: true
+	Active bool `json:"active"`
+
+	// The IDs of the salon's services the staff member can perform.
+	//
+	// required: true
+	// example: [1, 3]
+	ServiceIDs []int `json:"service_ids"`
+}
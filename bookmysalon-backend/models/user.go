@@ -2,6 +2,23 @@
 
 package models
 
+// Platform-wide roles a user can hold.
+const (
+	RoleCustomer   = "customer"
+	RoleSalonOwner = "salon_owner"
+	RoleSalonStaff = "salon_staff"
+	RoleAdmin      = "admin"
+)
+
+// IsValidRole reports whether role is one of the known platform roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleSalonOwner, RoleSalonStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 // User represents the data for a user in the system.
 // swagger:model
 type User struct {
@@ -17,11 +34,9 @@ type User struct {
 	// example: "john_doe"
 	Username string `json:"username"`
 
-	// The password for the user. This will be stored encrypted and is never returned in API responses.
-	//
-	// required: true
-	// example: "securepassword123"
-	Password string `json:"password"`
+	// The password for the user. This is stored hashed and never serialized;
+	// requests carrying a password decode it into their own field.
+	Password string `json:"-"`
 
 	// The email address associated with the user.
 	//
@@ -29,12 +44,67 @@ type User struct {
 	// example: "johndoe@example.com"
 	Email string `json:"email"`
 
-	// The profile image URL for the user.
+	// Whether the user has confirmed ownership of the email address.
+	//
+	// required: false
+	// example: true
+	EmailVerified bool `json:"email_verified"`
+
+	// The name shown to salons and in reviews instead of the username.
+	//
+	// required: false
+	// example: "John D."
+	DisplayName string `json:"display_name"`
+
+	// The phone number of the user in international format.
 	//
 	// required: false
-	// example: "http://example.com/path/to/image.jpg"
+	// example: "+14155550123"
+	PhoneNumber string `json:"phone_number"`
+
+	// The URL of the profile image of the user. Read-only; the image is
+	// uploaded to /profile/image.
+	//
+	// required: false
+	// example: "/media/users/7/2c26b46b68ffc68f.jpg"
 	ProfileImage string `json:"profile_image"`
 
+	// The language the user wants to be contacted in, as a BCP 47 tag.
+	//
+	// required: false
+	// example: "en-US"
+	PreferredLanguage string `json:"preferred_language"`
+
+	// The IANA time zone appointment times are shown in.
+	//
+	// required: false
+	// example: "Europe/Berlin"
+	Timezone string `json:"timezone"`
+
+	// Whether the user agreed to receive marketing emails.
+	//
+	// required: false
+	// example: false
+	MarketingConsent bool `json:"marketing_consent"`
+
+	// When the marketing consent was last given or withdrawn.
+	//
+	// required: false
+	// example: "2023-01-05T10:00:00Z"
+	MarketingConsentUpdatedAt string `json:"marketing_consent_updated_at,omitempty"`
+
+	// The platform role of the user (customer, salon_owner, salon_staff or admin).
+	//
+	// required: false
+	// example: "customer"
+	Role string `json:"role"`
+
+	// Whether the user signs in with a TOTP code in addition to the password.
+	//
+	// required: false
+	// example: false
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+
 	// The date the user joined the platform.
 	//
 	// required: false
@@ -47,3 +117,32 @@ type User struct {
 	// example: "2023-01-10"
 	LastLogin string `json:"last_login"`
 }
+
+// ProfileUpdate is a partial update of the profile of a user. Only the fields
+// present in the request are changed; an empty string clears an optional field.
+// swagger:model
+type ProfileUpdate struct {
+	// example: "johndoe@example.com"
+	Email *string `json:"email"`
+
+	// example: "John D."
+	DisplayName *string `json:"display_name"`
+
+	// example: "+14155550123"
+	PhoneNumber *string `json:"phone_number"`
+
+	// Only an empty string is accepted, which removes the profile image.
+	// New images are uploaded to /profile/image.
+	//
+	// example: ""
+	ProfileImage *string `json:"profile_image"`
+
+	// example: "en-US"
+	PreferredLanguage *string `json:"preferred_language"`
+
+	// example: "Europe/Berlin"
+	Timezone *string `json:"timezone"`
+
+	// example: true
+	MarketingConsent *bool `json:"marketing_consent"`
+}
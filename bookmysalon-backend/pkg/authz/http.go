@@ -0,0 +1,15 @@
+package authz
+
+import "net/http"
+
+// WriteError writes the HTTP response matching an authorization failure.
+func WriteError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrUnauthenticated:
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case ErrForbidden:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
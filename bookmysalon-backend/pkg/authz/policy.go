@@ -0,0 +1,47 @@
+package authz
+
+import (
+	"bookmysalon/models"
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnauthenticated is returned when no authenticated caller is present on the context.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrForbidden is returned when the caller is not allowed to act on a resource.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Per-salon membership roles stored in salon_members.
+const (
+	SalonRoleOwner = "owner"
+	SalonRoleStaff = "staff"
+)
+
+// Policy decides whether the authenticated caller may act on a resource.
+type Policy interface {
+	// AuthorizeSalon allows platform admins and the owners or staff of the salon.
+	AuthorizeSalon(ctx context.Context, salonID int) error
+
+	// AuthorizeSalonOwner allows platform admins and the owners of the salon.
+	AuthorizeSalonOwner(ctx context.Context, salonID int) error
+
+	// AuthorizeService allows platform admins and the owners or staff of the salon offering the service.
+	AuthorizeService(ctx context.Context, serviceID int) error
+
+	// AuthorizeStaff allows platform admins, the owners or staff of the salon the
+	// staff member works at and the user linked to the staff member.
+	AuthorizeStaff(ctx context.Context, staffID int) error
+
+	// AuthorizeUser allows platform admins and the user themselves.
+	AuthorizeUser(ctx context.Context, userID int) error
+
+	// AuthorizeAppointment allows platform admins, the customer who booked the
+	// appointment and the owners or staff of the salon it was booked at.
+	AuthorizeAppointment(ctx context.Context, appointment *models.Appointment) error
+
+	// AuthorizeReview allows platform admins and the author of the review.
+	AuthorizeReview(ctx context.Context, review *models.Review) error
+}
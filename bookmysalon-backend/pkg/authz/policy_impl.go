@@ -0,0 +1,150 @@
+package authz
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/middleware"
+	"context"
+	"database/sql"
+	"log"
+)
+
+// policyImpl is the database-backed implementation of the Policy interface.
+type policyImpl struct {
+	db *sql.DB
+}
+
+// NewPolicy initializes and returns an instance of Policy.
+func NewPolicy() (Policy, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &policyImpl{
+		db: db,
+	}, nil
+}
+
+// AuthorizeSalon allows platform admins and the owners or staff of the salon.
+func (p *policyImpl) AuthorizeSalon(ctx context.Context, salonID int) error {
+	return p.authorizeSalonMember(ctx, salonID, SalonRoleOwner, SalonRoleStaff)
+}
+
+// AuthorizeSalonOwner allows platform admins and the owners of the salon.
+func (p *policyImpl) AuthorizeSalonOwner(ctx context.Context, salonID int) error {
+	return p.authorizeSalonMember(ctx, salonID, SalonRoleOwner)
+}
+
+// AuthorizeService allows platform admins and the owners or staff of the salon offering the service.
+func (p *policyImpl) AuthorizeService(ctx context.Context, serviceID int) error {
+	if _, ok := middleware.UserIDFromContext(ctx); !ok {
+		return ErrUnauthenticated
+	}
+	if middleware.HasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+
+	const query = `SELECT salon_id FROM services WHERE service_id=$1`
+
+	var salonID int
+	err := p.db.QueryRowContext(ctx, query, serviceID).Scan(&salonID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrForbidden
+		}
+		log.Printf("Error looking up salon of service: %v", err)
+		return err
+	}
+
+	return p.AuthorizeSalon(ctx, salonID)
+}
+
+// AuthorizeStaff allows platform admins, the owners or staff of the salon the
+// staff member works at and the user linked to the staff member.
+func (p *policyImpl) AuthorizeStaff(ctx context.Context, staffID int) error {
+	callerID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if middleware.HasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+
+	const query = `SELECT salon_id, user_id FROM staff WHERE staff_id=$1`
+
+	var salonID int
+	var userID sql.NullInt64
+	err := p.db.QueryRowContext(ctx, query, staffID).Scan(&salonID, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrForbidden
+		}
+		log.Printf("Error looking up salon of staff member: %v", err)
+		return err
+	}
+
+	if userID.Valid && int(userID.Int64) == callerID {
+		return nil
+	}
+	return p.AuthorizeSalon(ctx, salonID)
+}
+
+// AuthorizeUser allows platform admins and the user themselves.
+func (p *policyImpl) AuthorizeUser(ctx context.Context, userID int) error {
+	callerID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if callerID == userID || middleware.HasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// AuthorizeAppointment allows platform admins, the customer who booked the
+// appointment and the owners or staff of the salon it was booked at.
+func (p *policyImpl) AuthorizeAppointment(ctx context.Context, appointment *models.Appointment) error {
+	callerID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if appointment.UserID == callerID {
+		return nil
+	}
+	return p.AuthorizeSalon(ctx, appointment.SalonID)
+}
+
+// AuthorizeReview allows platform admins and the author of the review.
+func (p *policyImpl) AuthorizeReview(ctx context.Context, review *models.Review) error {
+	return p.AuthorizeUser(ctx, review.UserID)
+}
+
+// authorizeSalonMember checks that the caller is an admin or holds one of the given roles in the salon.
+func (p *policyImpl) authorizeSalonMember(ctx context.Context, salonID int, roles ...string) error {
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if middleware.HasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+
+	const query = `SELECT role FROM salon_members WHERE salon_id=$1 AND user_id=$2`
+
+	var memberRole string
+	err := p.db.QueryRowContext(ctx, query, salonID, userID).Scan(&memberRole)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrForbidden
+		}
+		log.Printf("Error checking salon membership: %v", err)
+		return err
+	}
+
+	for _, role := range roles {
+		if memberRole == role {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
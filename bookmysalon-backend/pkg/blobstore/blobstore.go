@@ -0,0 +1,119 @@
+// Package blobstore stores uploaded files, such as photos, under string keys.
+//
+// Two stores are available: LocalStore writes to a directory and suits
+// development and single-server setups; S3Store talks to Amazon S3 or any
+// S3-compatible server such as MinIO.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Environment variables selecting the blob store implementation.
+const (
+	// BlobStoreEnv is "local" (default) or "s3".
+	BlobStoreEnv = "BLOBSTORE"
+
+	// BlobStoreDirEnv is the directory of the local store, "media" by default.
+	BlobStoreDirEnv = "BLOBSTORE_DIR"
+
+	// S3EndpointEnv is the base URL of the S3 server, e.g. "http://localhost:9000"
+	// for a local MinIO. Defaults to Amazon S3 in the configured region.
+	S3EndpointEnv = "S3_ENDPOINT"
+
+	// S3RegionEnv is the region requests are signed for, "us-east-1" by default.
+	S3RegionEnv = "S3_REGION"
+
+	// S3BucketEnv is the bucket the blobs are stored in.
+	S3BucketEnv = "S3_BUCKET"
+
+	// S3AccessKeyIDEnv and S3SecretAccessKeyEnv are the credentials of the store.
+	S3AccessKeyIDEnv     = "S3_ACCESS_KEY_ID"
+	S3SecretAccessKeyEnv = "S3_SECRET_ACCESS_KEY"
+)
+
+const (
+	defaultDir    = "media"
+	defaultRegion = "us-east-1"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Info describes a stored blob.
+type Info struct {
+	ContentType string
+	Size        int64
+}
+
+// Store keeps blobs under keys such as "salons/1/3f2a.jpg". Keys use forward
+// slashes and must not contain "." or ".." segments.
+type Store interface {
+	// Put stores data under key, replacing any blob already there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Get opens the blob under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+
+	// Delete removes the blob under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv returns the blob store configured through the environment.
+func NewFromEnv() (Store, error) {
+	switch kind := os.Getenv(BlobStoreEnv); kind {
+	case "", "local":
+		dir := os.Getenv(BlobStoreDirEnv)
+		if dir == "" {
+			dir = defaultDir
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		region := os.Getenv(S3RegionEnv)
+		if region == "" {
+			region = defaultRegion
+		}
+		endpoint := os.Getenv(S3EndpointEnv)
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		bucket := os.Getenv(S3BucketEnv)
+		if bucket == "" {
+			return nil, fmt.Errorf("%s must be set for the s3 blob store", S3BucketEnv)
+		}
+		return NewS3Store(S3Config{
+			Endpoint:        endpoint,
+			Region:          region,
+			Bucket:          bucket,
+			AccessKeyID:     os.Getenv(S3AccessKeyIDEnv),
+			SecretAccessKey: os.Getenv(S3SecretAccessKeyEnv),
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", kind)
+	}
+}
+
+// ValidKey reports whether key can be used with any store.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/-_.", r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files in a directory. The content type is derived
+// from the extension of the key.
+type LocalStore struct {
+	Dir string
+}
+
+// NewLocalStore returns a store writing to dir, creating it if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+// Put writes the blob to a temporary file first, so readers never see a partial blob.
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Get opens the file of a blob.
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, Info{}, ErrNotFound
+		}
+		return nil, Info{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return f, Info{ContentType: contentType, Size: stat.Size()}, nil
+}
+
+// Delete removes the file of a blob.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,205 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config holds the location and credentials of an S3 bucket.
+type S3Config struct {
+	// Endpoint is the base URL of the server, e.g. "https://s3.eu-west-1.amazonaws.com"
+	// or "http://localhost:9000". Buckets are addressed in the path, which
+	// S3-compatible servers support without DNS setup.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store keeps blobs in a bucket of Amazon S3 or an S3-compatible server.
+// Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	config S3Config
+	client *http.Client
+
+	// now is the clock used for signing.
+	now func() time.Time
+}
+
+// NewS3Store returns a store for the configured bucket.
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if _, err := url.Parse(config.Endpoint); err != nil || config.Endpoint == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket must be set")
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &S3Store{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}, nil
+}
+
+// Put uploads a blob with a PUT Object request.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put", key, resp)
+	}
+	return nil
+}
+
+// Get downloads a blob with a GET Object request.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, Info{}, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, Info{}, responseError("get", key, resp)
+	}
+
+	return resp.Body, Info{ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}, nil
+}
+
+// Delete removes a blob with a DELETE Object request.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("delete", key, resp)
+	}
+	return nil
+}
+
+// request builds a signed request for the object under key.
+func (s *S3Store) request(ctx context.Context, method, key string, body []byte, contentType string) (*http.Request, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	endpoint, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// Keys only hold characters that need no escaping, see ValidKey.
+	canonicalPath := endpoint.EscapedPath() + "/" + url.PathEscape(s.config.Bucket) + "/" + key
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.Scheme+"://"+endpoint.Host+canonicalPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, canonicalPath, body)
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request.
+func (s *S3Store) sign(req *http.Request, canonicalPath string, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+		names = append([]string{"content-type"}, names...)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		"", // no query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// responseError turns an unexpected response into an error carrying the
+// start of the S3 error document.
+func responseError(operation, key string, resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s failed: %s: %s", operation, key, resp.Status, strings.TrimSpace(string(detail)))
+}
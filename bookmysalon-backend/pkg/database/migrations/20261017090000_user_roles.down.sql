@@ -0,0 +1,5 @@
+-- pkg/database/migrations/20261017090000_user_roles.down.sql
+
+DROP TABLE IF EXISTS salon_members;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,14 @@
+-- pkg/database/migrations/20261017090000_user_roles.up.sql
+
+-- Platform-wide role of each user
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(50) NOT NULL DEFAULT 'customer'
+    CHECK (role IN ('customer', 'salon_owner', 'salon_staff', 'admin'));
+
+-- Owners and staff of each salon
+CREATE TABLE salon_members (
+    salon_id INTEGER REFERENCES salons(salon_id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('owner', 'staff')),
+    PRIMARY KEY (salon_id, user_id)
+);
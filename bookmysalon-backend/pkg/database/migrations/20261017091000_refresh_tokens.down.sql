@@ -0,0 +1,6 @@
+-- pkg/database/migrations/20261017091000_refresh_tokens.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_revoked_at;
+
+DROP TABLE IF EXISTS revoked_access_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,26 @@
+-- pkg/database/migrations/20261017091000_refresh_tokens.up.sql
+
+-- Refresh tokens are stored hashed and rotated on every use. Tokens issued
+-- from the same login share a session_id so a whole session can be revoked.
+CREATE TABLE refresh_tokens (
+    token_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL, -- SHA-256 of the opaque token
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    replaced_by INTEGER REFERENCES refresh_tokens(token_id)
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+-- Access tokens revoked through /logout, kept until they would have expired
+CREATE TABLE revoked_access_tokens (
+    token_id VARCHAR(64) PRIMARY KEY, -- jti claim
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Access tokens issued before this instant are rejected ("log out all devices")
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP;
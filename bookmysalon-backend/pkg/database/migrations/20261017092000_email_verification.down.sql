@@ -0,0 +1,5 @@
+-- pkg/database/migrations/20261017092000_email_verification.down.sql
+
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,16 @@
+-- pkg/database/migrations/20261017092000_email_verification.up.sql
+
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Single-use, time-limited tokens sent to users by email
+CREATE TABLE user_tokens (
+    token_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    token_hash CHAR(64) UNIQUE NOT NULL, -- SHA-256 of the token
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
@@ -0,0 +1,3 @@
+-- pkg/database/migrations/20261017093000_login_attempts.down.sql
+
+DROP TABLE IF EXISTS login_attempts;
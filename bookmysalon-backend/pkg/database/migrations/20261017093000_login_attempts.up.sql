@@ -0,0 +1,14 @@
+-- pkg/database/migrations/20261017093000_login_attempts.up.sql
+
+-- Audit trail of every login attempt, also used to throttle brute-force attacks
+CREATE TABLE login_attempts (
+    attempt_id SERIAL PRIMARY KEY,
+    username VARCHAR(255) NOT NULL, -- as submitted, may not match any user
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    outcome VARCHAR(50) NOT NULL CHECK (outcome IN ('success', 'invalid_credentials', 'throttled')),
+    attempted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_username ON login_attempts(username, attempted_at);
+CREATE INDEX idx_login_attempts_ip_address ON login_attempts(ip_address, attempted_at);
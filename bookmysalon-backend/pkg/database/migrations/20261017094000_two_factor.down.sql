@@ -0,0 +1,13 @@
+-- pkg/database/migrations/20261017094000_two_factor.down.sql
+
+DELETE FROM login_attempts WHERE outcome = 'invalid_mfa_code';
+ALTER TABLE login_attempts DROP CONSTRAINT login_attempts_outcome_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_outcome_check
+    CHECK (outcome IN ('success', 'invalid_credentials', 'throttled'));
+
+DROP TABLE IF EXISTS mfa_required_roles;
+DROP TABLE IF EXISTS user_recovery_codes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
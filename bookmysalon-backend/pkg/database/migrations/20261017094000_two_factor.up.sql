@@ -0,0 +1,28 @@
+-- pkg/database/migrations/20261017094000_two_factor.up.sql
+
+-- TOTP secret of the user; set on enrollment, in use once totp_enabled is true
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT; -- last accepted time step, so a code cannot be replayed
+
+-- One-time recovery codes for users who lost their authenticator
+CREATE TABLE user_recovery_codes (
+    code_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) UNIQUE NOT NULL, -- SHA-256 of the code
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+-- Roles whose users must have two-factor authentication enabled
+CREATE TABLE mfa_required_roles (
+    role VARCHAR(50) PRIMARY KEY CHECK (role IN ('customer', 'salon_owner', 'salon_staff', 'admin')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Wrong second-factor codes count towards login throttling
+ALTER TABLE login_attempts DROP CONSTRAINT login_attempts_outcome_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_outcome_check
+    CHECK (outcome IN ('success', 'invalid_credentials', 'invalid_mfa_code', 'throttled'));
@@ -0,0 +1,4 @@
+-- pkg/database/migrations/20261017095000_identities.down.sql
+
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,25 @@
+-- pkg/database/migrations/20261017095000_identities.up.sql
+
+-- Accounts at external OpenID providers linked to users
+CREATE TABLE identities (
+    identity_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- the provider's stable user ID (sub claim)
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+-- Pending authorization requests, consumed by the callback
+CREATE TABLE oidc_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    link_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE, -- set when linking to a logged-in user
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,8 @@
+-- pkg/database/migrations/20261017096000_user_profile.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS marketing_consent_updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS marketing_consent;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_language;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_number;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,8 @@
+-- pkg/database/migrations/20261017096000_user_profile.up.sql
+
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN phone_number VARCHAR(20); -- E.164, e.g. +14155550123
+ALTER TABLE users ADD COLUMN preferred_language VARCHAR(35) NOT NULL DEFAULT 'en'; -- BCP 47 tag
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'; -- IANA time zone
+ALTER TABLE users ADD COLUMN marketing_consent BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN marketing_consent_updated_at TIMESTAMP;
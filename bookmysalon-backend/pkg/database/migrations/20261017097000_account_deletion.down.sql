@@ -0,0 +1,6 @@
+-- pkg/database/migrations/20261017097000_account_deletion.down.sql
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,10 @@
+-- pkg/database/migrations/20261017097000_account_deletion.up.sql
+
+-- Accounts are deleted in two steps: deleted_at starts a grace period during
+-- which the user can restore the account; afterwards the personal data is
+-- scrubbed and anonymized_at is set. The row itself is kept so that bookings,
+-- reviews and transactions still reference a user.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN anonymized_at TIMESTAMP;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL AND anonymized_at IS NULL;
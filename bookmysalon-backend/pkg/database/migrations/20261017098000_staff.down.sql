@@ -0,0 +1,10 @@
+-- pkg/database/migrations/20261017098000_staff.down.sql
+
+DROP INDEX IF EXISTS idx_appointments_staff_id;
+DROP INDEX IF EXISTS idx_availabilities_staff_id;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS staff_id;
+ALTER TABLE availabilities DROP COLUMN IF EXISTS staff_id;
+
+DROP TABLE IF EXISTS staff_services;
+DROP TABLE IF EXISTS staff;
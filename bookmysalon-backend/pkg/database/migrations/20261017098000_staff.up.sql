@@ -0,0 +1,34 @@
+-- pkg/database/migrations/20261017098000_staff.up.sql
+
+-- Stylists and other staff of each salon. A staff member may have a user
+-- account, but does not need one to be bookable.
+CREATE TABLE staff (
+    staff_id SERIAL PRIMARY KEY,
+    salon_id INTEGER NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    bio TEXT,
+    photo VARCHAR(255),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (salon_id, user_id)
+);
+
+CREATE INDEX idx_staff_salon_id ON staff(salon_id);
+
+-- Services each staff member can perform
+CREATE TABLE staff_services (
+    staff_id INTEGER REFERENCES staff(staff_id) ON DELETE CASCADE,
+    service_id INTEGER REFERENCES services(service_id) ON DELETE CASCADE,
+    PRIMARY KEY (staff_id, service_id)
+);
+
+CREATE INDEX idx_staff_services_service_id ON staff_services(service_id);
+
+-- Slots and bookings can be tied to a specific staff member. Removing the
+-- staff member keeps the bookings, just without the stylist.
+ALTER TABLE availabilities ADD COLUMN staff_id INTEGER REFERENCES staff(staff_id) ON DELETE SET NULL;
+ALTER TABLE appointments ADD COLUMN staff_id INTEGER REFERENCES staff(staff_id) ON DELETE SET NULL;
+
+CREATE INDEX idx_availabilities_staff_id ON availabilities(staff_id);
+CREATE INDEX idx_appointments_staff_id ON appointments(staff_id);
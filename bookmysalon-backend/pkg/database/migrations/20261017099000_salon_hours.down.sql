@@ -0,0 +1,6 @@
+-- pkg/database/migrations/20261017099000_salon_hours.down.sql
+
+DROP TABLE IF EXISTS salon_hours_exceptions;
+DROP TABLE IF EXISTS salon_opening_hours;
+
+ALTER TABLE salons DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,33 @@
+-- pkg/database/migrations/20261017099000_salon_hours.up.sql
+
+-- Opening hours are local times in the salon's timezone (an IANA name)
+ALTER TABLE salons ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Weekly opening hours; a day can have several intervals, e.g. around a lunch
+-- break. weekday 0 is Sunday.
+CREATE TABLE salon_opening_hours (
+    opening_hours_id SERIAL PRIMARY KEY,
+    salon_id INTEGER NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at TIME NOT NULL,
+    closes_at TIME NOT NULL,
+    CHECK (opens_at < closes_at)
+);
+
+CREATE INDEX idx_salon_opening_hours_salon_id ON salon_opening_hours(salon_id);
+
+-- Holidays, special hours and temporary closures. On the covered dates they
+-- replace the weekly hours; rows without times close the salon all day.
+CREATE TABLE salon_hours_exceptions (
+    exception_id SERIAL PRIMARY KEY,
+    salon_id INTEGER NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    opens_at TIME,
+    closes_at TIME,
+    reason TEXT,
+    CHECK (end_date >= start_date),
+    CHECK ((opens_at IS NULL AND closes_at IS NULL) OR opens_at < closes_at)
+);
+
+CREATE INDEX idx_salon_hours_exceptions_salon_dates ON salon_hours_exceptions(salon_id, end_date, start_date);
@@ -0,0 +1,11 @@
+-- pkg/database/migrations/20261017100000_salon_location.down.sql
+
+DROP INDEX IF EXISTS idx_salons_location;
+
+ALTER TABLE salons DROP CONSTRAINT IF EXISTS salons_coordinates_check;
+ALTER TABLE salons DROP COLUMN IF EXISTS longitude;
+ALTER TABLE salons DROP COLUMN IF EXISTS latitude;
+ALTER TABLE salons DROP COLUMN IF EXISTS country;
+ALTER TABLE salons DROP COLUMN IF EXISTS postal_code;
+ALTER TABLE salons DROP COLUMN IF EXISTS city;
+ALTER TABLE salons DROP COLUMN IF EXISTS street;
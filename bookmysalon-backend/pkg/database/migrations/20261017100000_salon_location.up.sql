@@ -0,0 +1,14 @@
+-- pkg/database/migrations/20261017100000_salon_location.up.sql
+
+-- Structured address next to the free-text one, and the coordinates used by
+-- the nearby search. Salons without coordinates are not found by it.
+ALTER TABLE salons ADD COLUMN street VARCHAR(255);
+ALTER TABLE salons ADD COLUMN city VARCHAR(255);
+ALTER TABLE salons ADD COLUMN postal_code VARCHAR(32);
+ALTER TABLE salons ADD COLUMN country VARCHAR(64);
+ALTER TABLE salons ADD COLUMN latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE salons ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180);
+
+ALTER TABLE salons ADD CONSTRAINT salons_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+CREATE INDEX idx_salons_location ON salons(latitude, longitude) WHERE latitude IS NOT NULL;
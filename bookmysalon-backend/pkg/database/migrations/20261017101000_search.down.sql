@@ -0,0 +1,10 @@
+-- pkg/database/migrations/20261017101000_search.down.sql
+
+DROP INDEX IF EXISTS idx_availabilities_open_slots;
+DROP INDEX IF EXISTS idx_services_category;
+DROP INDEX IF EXISTS idx_services_search;
+DROP INDEX IF EXISTS idx_salons_search;
+
+ALTER TABLE services DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE salons DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE services DROP COLUMN IF EXISTS category;
@@ -0,0 +1,20 @@
+-- pkg/database/migrations/20261017101000_search.up.sql
+
+-- Free-form category of a service, stored lowercase, e.g. "haircut" or "nails".
+ALTER TABLE services ADD COLUMN category VARCHAR(64);
+
+-- Full-text search documents. Names weigh more than descriptions.
+ALTER TABLE salons ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(name, '')), 'A')
+) STORED;
+
+ALTER TABLE services ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(category, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX idx_salons_search ON salons USING GIN(search_vector);
+CREATE INDEX idx_services_search ON services USING GIN(search_vector);
+CREATE INDEX idx_services_category ON services(category);
+CREATE INDEX idx_availabilities_open_slots ON availabilities(service_id, start_date_time) WHERE status = 'available';
@@ -0,0 +1,18 @@
+-- pkg/database/migrations/20261017102000_service_catalog.down.sql
+
+DROP TABLE IF EXISTS appointment_addons;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS total_duration;
+ALTER TABLE appointments DROP COLUMN IF EXISTS total_price;
+ALTER TABLE appointments DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS service_addons;
+DROP TABLE IF EXISTS service_variants;
+
+DROP INDEX IF EXISTS idx_services_salon_category;
+DROP INDEX IF EXISTS idx_services_category;
+CREATE INDEX idx_services_category ON services(category);
+
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_category_fkey;
+
+DROP TABLE IF EXISTS service_categories;
@@ -0,0 +1,63 @@
+-- pkg/database/migrations/20261017102000_service_catalog.up.sql
+
+-- Sections of a salon's menu, e.g. "Hair" or "Nails", in display order.
+CREATE TABLE service_categories (
+    category_id SERIAL PRIMARY KEY,
+    salon_id INTEGER NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    description TEXT,
+    position INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (salon_id, name)
+);
+
+CREATE UNIQUE INDEX idx_service_categories_salon_name ON service_categories(salon_id, LOWER(name));
+
+-- Every category already used by a service becomes a category of its salon.
+INSERT INTO service_categories(salon_id, name)
+SELECT DISTINCT salon_id, category FROM services WHERE category IS NOT NULL;
+
+-- Services keep the category name, so renaming a category carries over to
+-- its services and searches.
+ALTER TABLE services ADD CONSTRAINT services_category_fkey
+    FOREIGN KEY (salon_id, category) REFERENCES service_categories(salon_id, name) ON UPDATE CASCADE;
+
+DROP INDEX IF EXISTS idx_services_category;
+CREATE INDEX idx_services_category ON services(LOWER(category));
+CREATE INDEX idx_services_salon_category ON services(salon_id, category);
+
+-- Versions of a service with their own price and duration, e.g. short and
+-- long hair. A service with variants is booked as one of them.
+CREATE TABLE service_variants (
+    variant_id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(service_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    duration INTERVAL NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (service_id, name)
+);
+
+-- Optional extras booked along with a service, e.g. a deep conditioning.
+CREATE TABLE service_addons (
+    addon_id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(service_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    duration INTERVAL NOT NULL DEFAULT '0',
+    position INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (service_id, name)
+);
+
+-- What was booked and what it came to at the time of booking, so later
+-- price changes leave existing appointments alone.
+ALTER TABLE appointments ADD COLUMN variant_id INTEGER REFERENCES service_variants(variant_id) ON DELETE SET NULL;
+ALTER TABLE appointments ADD COLUMN total_price NUMERIC(10, 2);
+ALTER TABLE appointments ADD COLUMN total_duration INTERVAL;
+
+CREATE TABLE appointment_addons (
+    appointment_id INTEGER REFERENCES appointments(appointment_id) ON DELETE CASCADE,
+    addon_id INTEGER REFERENCES service_addons(addon_id) ON DELETE CASCADE,
+    PRIMARY KEY (appointment_id, addon_id)
+);
+
+CREATE INDEX idx_appointment_addons_addon_id ON appointment_addons(addon_id);
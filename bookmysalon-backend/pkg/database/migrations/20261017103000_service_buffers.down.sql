@@ -0,0 +1,5 @@
+-- pkg/database/migrations/20261017103000_service_buffers.down.sql
+
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_buffers_check;
+ALTER TABLE services DROP COLUMN IF EXISTS buffer_after;
+ALTER TABLE services DROP COLUMN IF EXISTS buffer_before;
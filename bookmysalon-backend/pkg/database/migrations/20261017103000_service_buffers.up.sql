@@ -0,0 +1,9 @@
+-- pkg/database/migrations/20261017103000_service_buffers.up.sql
+
+-- Preparation time before and cleanup time after a service. Bookings keep the
+-- salon busy for them, on top of the service itself.
+ALTER TABLE services ADD COLUMN buffer_before INTERVAL NOT NULL DEFAULT '0';
+ALTER TABLE services ADD COLUMN buffer_after INTERVAL NOT NULL DEFAULT '0';
+
+ALTER TABLE services ADD CONSTRAINT services_buffers_check
+    CHECK (buffer_before >= INTERVAL '0' AND buffer_after >= INTERVAL '0');
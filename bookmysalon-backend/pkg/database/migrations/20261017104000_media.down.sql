@@ -0,0 +1,6 @@
+-- pkg/database/migrations/20261017104000_media.down.sql
+
+DROP TRIGGER IF EXISTS photos_queue_blobs ON photos;
+DROP FUNCTION IF EXISTS queue_photo_blobs();
+DROP TABLE IF EXISTS media_garbage;
+DROP TABLE IF EXISTS photos;
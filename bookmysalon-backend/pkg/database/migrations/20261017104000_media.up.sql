@@ -0,0 +1,43 @@
+-- pkg/database/migrations/20261017104000_media.up.sql
+
+-- Uploaded images. Each photo belongs to exactly one salon, service or user
+-- and is kept in the blob store under blob_key, with a thumbnail under
+-- thumbnail_key. Photos of the same owner are shown in position order.
+CREATE TABLE photos (
+    photo_id SERIAL PRIMARY KEY,
+    salon_id INT REFERENCES salons(salon_id) ON DELETE CASCADE,
+    service_id INT REFERENCES services(service_id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    blob_key VARCHAR(512) NOT NULL UNIQUE,
+    thumbnail_key VARCHAR(512) NOT NULL UNIQUE,
+    content_type VARCHAR(64) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT photos_owner_check CHECK (num_nonnulls(salon_id, service_id, user_id) = 1)
+);
+
+CREATE INDEX idx_photos_salon ON photos(salon_id, position) WHERE salon_id IS NOT NULL;
+CREATE INDEX idx_photos_service ON photos(service_id, position) WHERE service_id IS NOT NULL;
+CREATE INDEX idx_photos_user ON photos(user_id) WHERE user_id IS NOT NULL;
+
+-- Blobs whose photo is gone, waiting to be removed from the blob store. Rows
+-- are queued by a trigger, so photos deleted along with their salon, service
+-- or user leave no files behind either.
+CREATE TABLE media_garbage (
+    blob_key VARCHAR(512) PRIMARY KEY,
+    queued_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE FUNCTION queue_photo_blobs() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO media_garbage(blob_key) VALUES (OLD.blob_key), (OLD.thumbnail_key)
+    ON CONFLICT (blob_key) DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER photos_queue_blobs AFTER DELETE ON photos
+    FOR EACH ROW EXECUTE FUNCTION queue_photo_blobs();
@@ -0,0 +1,8 @@
+-- pkg/database/migrations/20261017105000_salon_status.down.sql
+
+DROP TABLE IF EXISTS salon_status_history;
+DROP INDEX IF EXISTS idx_salons_status;
+ALTER TABLE salons DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE salons DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE salons DROP CONSTRAINT IF EXISTS salons_status_check;
+ALTER TABLE salons DROP COLUMN IF EXISTS status;
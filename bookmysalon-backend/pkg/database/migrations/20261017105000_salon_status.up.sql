@@ -0,0 +1,28 @@
+-- pkg/database/migrations/20261017105000_salon_status.up.sql
+
+-- New salons start as drafts and go live once an admin approves them:
+-- draft -> submitted -> approved or rejected, approved <-> suspended. Salons
+-- that existed before the review was introduced stay live.
+ALTER TABLE salons ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'approved';
+ALTER TABLE salons ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE salons ADD CONSTRAINT salons_status_check
+    CHECK (status IN ('draft', 'submitted', 'approved', 'rejected', 'suspended'));
+
+-- The reason given for the last rejection or suspension.
+ALTER TABLE salons ADD COLUMN status_reason TEXT;
+ALTER TABLE salons ADD COLUMN status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_salons_status ON salons(status);
+
+-- Every change of status, with who made it and why.
+CREATE TABLE salon_status_history (
+    history_id SERIAL PRIMARY KEY,
+    salon_id INT NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    reason TEXT,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_salon_status_history_salon ON salon_status_history(salon_id, changed_at);
@@ -0,0 +1,6 @@
+-- pkg/database/migrations/20261017106000_appointment_slots.down.sql
+
+ALTER TABLE availabilities ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE availabilities ALTER COLUMN status DROP DEFAULT;
+DROP INDEX IF EXISTS idx_appointments_availability;
+ALTER TABLE appointments DROP COLUMN IF EXISTS availability_id;
@@ -0,0 +1,12 @@
+-- pkg/database/migrations/20261017106000_appointment_slots.up.sql
+
+-- The availability an appointment was booked into. The slot is claimed in the
+-- same transaction as the appointment is created, and released when it is
+-- cancelled or deleted; the unique index keeps a slot from being held twice.
+ALTER TABLE appointments ADD COLUMN availability_id INTEGER REFERENCES availabilities(availability_id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_appointments_availability ON appointments(availability_id) WHERE availability_id IS NOT NULL;
+
+ALTER TABLE availabilities ALTER COLUMN status SET DEFAULT 'available';
+UPDATE availabilities SET status = 'available' WHERE status IS NULL;
+ALTER TABLE availabilities ALTER COLUMN status SET NOT NULL;
@@ -0,0 +1,16 @@
+-- pkg/database/migrations/20261017107000_appointment_status.down.sql
+
+DROP TABLE IF EXISTS appointment_status_history;
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_status_check;
+ALTER TABLE appointments ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE appointments ALTER COLUMN status DROP DEFAULT;
+
+UPDATE appointments SET status = CASE
+    WHEN status = 'completed' THEN 'completed'
+    WHEN status IN ('cancelled_by_customer', 'cancelled_by_salon', 'no_show') THEN 'cancelled'
+    ELSE 'booked'
+END;
+
+ALTER TABLE appointments ADD CONSTRAINT appointments_status_check
+    CHECK (status IN ('booked', 'cancelled', 'completed'));
@@ -0,0 +1,34 @@
+-- pkg/database/migrations/20261017107000_appointment_status.up.sql
+
+-- Appointments move through requested -> confirmed -> checked_in ->
+-- in_service -> completed, or end as cancelled_by_customer,
+-- cancelled_by_salon or no_show. Appointments booked before the lifecycle was
+-- introduced were taken as firm bookings, so they count as confirmed. Who
+-- cancelled the old cancelled ones was not kept; they are put down to the
+-- customer.
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_status_check;
+
+UPDATE appointments SET status = CASE status
+    WHEN 'completed' THEN 'completed'
+    WHEN 'cancelled' THEN 'cancelled_by_customer'
+    ELSE 'confirmed'
+END;
+
+ALTER TABLE appointments ALTER COLUMN status SET DEFAULT 'requested';
+ALTER TABLE appointments ALTER COLUMN status SET NOT NULL;
+ALTER TABLE appointments ADD CONSTRAINT appointments_status_check
+    CHECK (status IN ('requested', 'confirmed', 'checked_in', 'in_service', 'completed',
+        'cancelled_by_customer', 'cancelled_by_salon', 'no_show'));
+
+-- Every change of status, with who made it and when.
+CREATE TABLE appointment_status_history (
+    history_id SERIAL PRIMARY KEY,
+    appointment_id INT NOT NULL REFERENCES appointments(appointment_id) ON DELETE CASCADE,
+    from_status VARCHAR(32) NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    reason TEXT,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_appointment_status_history_appointment ON appointment_status_history(appointment_id, changed_at);
@@ -0,0 +1,8 @@
+-- pkg/database/migrations/20261017108000_salon_policies.down.sql
+
+DROP INDEX IF EXISTS idx_appointments_requested;
+ALTER TABLE appointments DROP COLUMN IF EXISTS refund_amount;
+ALTER TABLE appointments DROP COLUMN IF EXISTS cancellation_fee;
+ALTER TABLE appointments DROP COLUMN IF EXISTS reschedule_count;
+ALTER TABLE appointments DROP COLUMN IF EXISTS created_at;
+DROP TABLE IF EXISTS salon_policies;
@@ -0,0 +1,29 @@
+-- pkg/database/migrations/20261017108000_salon_policies.up.sql
+
+-- Cancellation and rescheduling rules of a salon. Salons without a row
+-- allow free cancellation and unlimited reschedules at any time and never
+-- cancel unconfirmed bookings.
+CREATE TABLE salon_policies (
+    salon_id INT PRIMARY KEY REFERENCES salons(salon_id) ON DELETE CASCADE,
+    -- Customers cancelling later than this before the start pay the late fee,
+    -- and cannot reschedule any more.
+    free_cancellation_notice INTERVAL NOT NULL DEFAULT INTERVAL '0',
+    late_cancellation_fee_percent NUMERIC(5, 2) NOT NULL DEFAULT 0
+        CHECK (late_cancellation_fee_percent BETWEEN 0 AND 100),
+    -- NULL for no limit.
+    max_reschedules INT CHECK (max_reschedules >= 0),
+    -- Requested appointments not confirmed this long after booking are
+    -- cancelled. Zero turns it off.
+    confirmation_window INTERVAL NOT NULL DEFAULT INTERVAL '0',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE appointments ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE appointments ADD COLUMN reschedule_count INT NOT NULL DEFAULT 0;
+
+-- The outcome of a cancellation: what the customer owes and what is left of
+-- the price to refund.
+ALTER TABLE appointments ADD COLUMN cancellation_fee NUMERIC(10, 2);
+ALTER TABLE appointments ADD COLUMN refund_amount NUMERIC(10, 2);
+
+CREATE INDEX idx_appointments_requested ON appointments(created_at) WHERE status = 'requested';
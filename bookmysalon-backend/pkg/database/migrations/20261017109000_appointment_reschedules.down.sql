@@ -0,0 +1,3 @@
+-- pkg/database/migrations/20261017109000_appointment_reschedules.down.sql
+
+DROP TABLE IF EXISTS appointment_reschedules;
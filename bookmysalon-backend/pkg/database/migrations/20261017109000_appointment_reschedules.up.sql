@@ -0,0 +1,16 @@
+-- pkg/database/migrations/20261017109000_appointment_reschedules.up.sql
+
+-- Every reschedule of an appointment: where it moved from and to, including
+-- the slots it let go of and claimed, and who moved it.
+CREATE TABLE appointment_reschedules (
+    reschedule_id SERIAL PRIMARY KEY,
+    appointment_id INT NOT NULL REFERENCES appointments(appointment_id) ON DELETE CASCADE,
+    from_date_time TIMESTAMP NOT NULL,
+    to_date_time TIMESTAMP NOT NULL,
+    from_availability_id INT REFERENCES availabilities(availability_id) ON DELETE SET NULL,
+    to_availability_id INT REFERENCES availabilities(availability_id) ON DELETE SET NULL,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    rescheduled_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_appointment_reschedules_appointment ON appointment_reschedules(appointment_id, rescheduled_at);
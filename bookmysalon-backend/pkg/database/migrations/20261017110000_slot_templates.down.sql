@@ -0,0 +1,5 @@
+-- pkg/database/migrations/20261017110000_slot_templates.down.sql
+
+DROP INDEX IF EXISTS idx_availabilities_template_start;
+ALTER TABLE availabilities DROP COLUMN IF EXISTS template_id;
+DROP TABLE IF EXISTS slot_templates;
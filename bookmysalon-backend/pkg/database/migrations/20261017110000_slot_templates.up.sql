@@ -0,0 +1,24 @@
+-- pkg/database/migrations/20261017110000_slot_templates.up.sql
+
+-- Recurring weekly windows in which a salon offers a service, optionally
+-- with a specific staff member. The slot generator lays slots of the
+-- service's length, with its buffers between them, into each window for a
+-- rolling number of weeks ahead. Times are local to the salon.
+CREATE TABLE slot_templates (
+    template_id SERIAL PRIMARY KEY,
+    salon_id INT NOT NULL REFERENCES salons(salon_id) ON DELETE CASCADE,
+    service_id INT NOT NULL REFERENCES services(service_id) ON DELETE CASCADE,
+    staff_id INT REFERENCES staff(staff_id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    starts_at TIME NOT NULL,
+    ends_at TIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_slot_templates_salon ON slot_templates(salon_id, weekday);
+
+-- The template a slot was generated from. The unique index makes generating
+-- the same weeks again a no-op.
+ALTER TABLE availabilities ADD COLUMN template_id INT REFERENCES slot_templates(template_id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_availabilities_template_start ON availabilities(template_id, start_date_time) WHERE template_id IS NOT NULL;
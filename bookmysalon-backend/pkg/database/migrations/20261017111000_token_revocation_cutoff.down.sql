@@ -0,0 +1,8 @@
+-- pkg/database/migrations/20261017111000_token_revocation_cutoff.down.sql
+
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP;
+
+UPDATE users SET tokens_revoked_at = to_timestamp(tokens_revoked_before)::timestamp
+WHERE tokens_revoked_before IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_revoked_before;
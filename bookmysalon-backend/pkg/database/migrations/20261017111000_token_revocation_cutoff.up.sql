@@ -0,0 +1,12 @@
+-- pkg/database/migrations/20261017111000_token_revocation_cutoff.up.sql
+
+-- The "log out all devices" cutoff, in Unix seconds like the iat claim it is
+-- compared with. Access tokens issued before it are rejected.
+ALTER TABLE users ADD COLUMN tokens_revoked_before BIGINT;
+
+-- Rounded up, so tokens issued within the second of an earlier revocation
+-- stay rejected.
+UPDATE users SET tokens_revoked_before = CEIL(EXTRACT(EPOCH FROM tokens_revoked_at::timestamptz))
+WHERE tokens_revoked_at IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN tokens_revoked_at;
@@ -0,0 +1,4 @@
+-- pkg/database/migrations/20261017112000_salon_ratings.down.sql
+
+-- The ratings set before cannot be restored; the computed ones are kept.
+SELECT 1;
@@ -0,0 +1,4 @@
+-- pkg/database/migrations/20261017112000_salon_ratings.up.sql
+
+-- Ratings were taken from salon edits; work them out from the reviews instead
+UPDATE salons s SET average_rating = COALESCE((SELECT AVG(r.rating) FROM reviews r WHERE r.salon_id = s.salon_id), 0);
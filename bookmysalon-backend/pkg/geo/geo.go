@@ -0,0 +1,86 @@
+// Package geo provides the distance calculations behind location search and
+// the geocoding of salon addresses.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// EarthRadiusKm is the mean radius of the earth used for distances.
+const EarthRadiusKm = 6371.0
+
+var ErrInvalidPoint = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180")
+
+// Point is a position given in degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid reports whether the point lies within the range of latitudes and longitudes.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180 &&
+		!math.IsNaN(p.Lat) && !math.IsNaN(p.Lng)
+}
+
+// Distance returns the great-circle distance between two points in kilometers.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Box is a range of latitudes and longitudes.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoundingBox returns a box containing every point within radiusKm of center.
+// It is meant to narrow down candidates cheaply before the exact distance is
+// computed. Near the poles and the antimeridian it spans all longitudes.
+func BoundingBox(center Point, radiusKm float64) Box {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	box := Box{
+		MinLat: center.Lat - dLat,
+		MaxLat: center.Lat + dLat,
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	dLng := degrees(math.Asin(math.Min(1, math.Sin(radiusKm/EarthRadiusKm)/math.Cos(radians(center.Lat)))))
+	if center.Lng-dLng >= -180 && center.Lng+dLng <= 180 {
+		box.MinLng = center.Lng - dLng
+		box.MaxLng = center.Lng + dLng
+	}
+	return box
+}
+
+// DistanceSQL returns a SQL expression computing Distance in PostgreSQL
+// between the point in the columns lat and lng and the one given by the
+// query parameters latParam and lngParam, such as "$1" and "$2".
+func DistanceSQL(lat, lng, latParam, lngParam string) string {
+	return fmt.Sprintf(`2 * %.1[5]f * ASIN(LEAST(1, SQRT(
+		POWER(SIN(RADIANS(%[1]s - %[3]s) / 2), 2) +
+		COS(RADIANS(%[3]s)) * COS(RADIANS(%[1]s)) * POWER(SIN(RADIANS(%[2]s - %[4]s) / 2), 2)
+	)))`, lat, lng, latParam, lngParam, EarthRadiusKm)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,211 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables selecting the geocoder implementation.
+const (
+	// GeocoderEnv is "none" (default), "static" or "nominatim".
+	GeocoderEnv = "GEOCODER"
+
+	// GeocoderFileEnv is the JSON file the static geocoder reads, mapping
+	// addresses as formatted by Address.String to points.
+	GeocoderFileEnv = "GEOCODER_FILE"
+
+	// NominatimURLEnv overrides the base URL of the Nominatim geocoder.
+	NominatimURLEnv = "NOMINATIM_URL"
+)
+
+const defaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	ErrNoGeocoder      = errors.New("no geocoder configured")
+)
+
+// Address is a structured postal address.
+type Address struct {
+	Street     string
+	City       string
+	PostalCode string
+	Country    string
+}
+
+// String formats the address on one line, leaving out empty parts.
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Street, strings.TrimSpace(a.PostalCode + " " + a.City), a.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsEmpty reports whether there is nothing to geocode.
+func (a Address) IsEmpty() bool {
+	return a.String() == ""
+}
+
+// Geocoder resolves addresses to coordinates.
+type Geocoder interface {
+	Geocode(ctx context.Context, address Address) (Point, error)
+}
+
+// NewFromEnv returns the geocoder configured through the environment.
+func NewFromEnv() (Geocoder, error) {
+	switch kind := os.Getenv(GeocoderEnv); kind {
+	case "", "none":
+		return NoopGeocoder{}, nil
+	case "static":
+		file := os.Getenv(GeocoderFileEnv)
+		if file == "" {
+			return nil, fmt.Errorf("%s must be set for the static geocoder", GeocoderFileEnv)
+		}
+		return LoadStaticGeocoder(file)
+	case "nominatim":
+		baseURL := os.Getenv(NominatimURLEnv)
+		if baseURL == "" {
+			baseURL = defaultNominatimURL
+		}
+		return NewNominatimGeocoder(baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", kind)
+	}
+}
+
+// NoopGeocoder resolves nothing. Salons then need their coordinates set explicitly.
+type NoopGeocoder struct{}
+
+// Geocode always fails with ErrNoGeocoder.
+func (NoopGeocoder) Geocode(ctx context.Context, address Address) (Point, error) {
+	return Point{}, ErrNoGeocoder
+}
+
+// StaticGeocoder resolves addresses from a fixed table. It works offline and
+// is intended for tests and local development.
+type StaticGeocoder struct {
+	points map[string]Point
+}
+
+// NewStaticGeocoder returns a geocoder for the given addresses, keyed by the
+// one-line format of Address.String. Keys are matched case-insensitively.
+func NewStaticGeocoder(points map[string]Point) *StaticGeocoder {
+	g := &StaticGeocoder{points: map[string]Point{}}
+	for address, p := range points {
+		g.points[normalizeAddress(address)] = p
+	}
+	return g
+}
+
+// LoadStaticGeocoder reads the table of a StaticGeocoder from a JSON file of
+// the form {"1 Main St, 12345 Springfield, US": {"lat": 1.5, "lng": 2.5}}.
+func LoadStaticGeocoder(file string) (*StaticGeocoder, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var points map[string]Point
+	if err := json.Unmarshal(data, &points); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return NewStaticGeocoder(points), nil
+}
+
+// Geocode looks the address up in the table.
+func (g *StaticGeocoder) Geocode(ctx context.Context, address Address) (Point, error) {
+	p, ok := g.points[normalizeAddress(address.String())]
+	if !ok {
+		return Point{}, ErrAddressNotFound
+	}
+	return p, nil
+}
+
+// NominatimGeocoder resolves addresses through the search API of a Nominatim
+// server, such as the one of OpenStreetMap.
+type NominatimGeocoder struct {
+	BaseURL string
+	client  *http.Client
+}
+
+// NewNominatimGeocoder returns a geocoder querying the server at baseURL.
+func NewNominatimGeocoder(baseURL string) *NominatimGeocoder {
+	return &NominatimGeocoder{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Geocode returns the best match of the server for the address.
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address Address) (Point, error) {
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	if address.Street != "" {
+		params.Set("street", address.Street)
+	}
+	if address.City != "" {
+		params.Set("city", address.City)
+	}
+	if address.PostalCode != "" {
+		params.Set("postalcode", address.PostalCode)
+	}
+	if address.Country != "" {
+		params.Set("country", address.Country)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.BaseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return Point{}, err
+	}
+	// The usage policy of the public servers requires an identifying user agent.
+	req.Header.Set("User-Agent", "bookmysalon-backend")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return Point{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Point{}, fmt.Errorf("geocoding failed: %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&results); err != nil {
+		return Point{}, err
+	}
+	if len(results) == 0 {
+		return Point{}, ErrAddressNotFound
+	}
+
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return Point{}, err
+	}
+	lng, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{Lat: lat, Lng: lng}, nil
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.Join(strings.Fields(address), " "))
+}
@@ -1,44 +1,106 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-// Retrieve JWT secret from environment variable or use a default one for demonstration (not recommended for production)
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
+// AccessTokenTTL is how long an access token stays valid. Clients renew it with a refresh token.
+const AccessTokenTTL = 15 * time.Minute
+
+// MFATokenTTL is how long a user has to complete the second login step.
+const MFATokenTTL = 5 * time.Minute
+
+// PurposeMFA marks a token that only proves the password step of a login.
+// It is not accepted as an access token.
+const PurposeMFA = "mfa"
 
 // Claims struct to hold the JWT claims
 type Claims struct {
-	Username string `json:"username"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	Purpose   string `json:"purpose,omitempty"`
 	jwt.StandardClaims
 }
 
-// GenerateToken generates a new JWT token for a given username
-func GenerateToken(username string) (string, error) {
-	expirationTime := time.Now().Add(1 * time.Hour)
+// GenerateToken generates a new JWT access token for a given user within a login session.
+// Every token gets a unique ID (jti) so that it can be revoked individually.
+func GenerateToken(userID int, username, role, sessionID string) (string, error) {
+	return signToken(&Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+	}, AccessTokenTTL)
+}
 
-	claims := &Claims{
+// GenerateMFAToken generates the short-lived token handed out after a correct
+// password when the user still has to enter a second factor.
+func GenerateMFAToken(userID int, username string) (string, error) {
+	return signToken(&Claims{
+		UserID:   userID,
 		Username: username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+		Purpose:  PurposeMFA,
+	}, MFATokenTTL)
+}
+
+func signToken(claims *Claims, ttl time.Duration) (string, error) {
+	if keys == nil {
+		return "", ErrNoKeys
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        tokenID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	// The kid header tells verifiers which key of the set signed the token.
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.id
+	return token.SignedString(keys.active.privateKey)
 }
 
-// VerifyToken verifies a given token and returns the claims
+// VerifyToken verifies a given access token and returns the claims
 func VerifyToken(tk string) (*Claims, error) {
+	claims, err := parseToken(tk)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// VerifyMFAToken verifies a token issued by GenerateMFAToken and returns the claims
+func VerifyMFAToken(tk string) (*Claims, error) {
+	claims, err := parseToken(tk)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("not an MFA token")
+	}
+	return claims, nil
+}
+
+func parseToken(tk string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tk, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tk, claims, lookupVerificationKey)
 
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
@@ -59,3 +121,12 @@ func VerifyToken(tk string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// NewTokenID returns a random identifier suitable for a jti or session ID.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
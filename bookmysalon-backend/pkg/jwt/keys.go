@@ -0,0 +1,276 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Environment variables configuring the signing keys.
+const (
+	// KeysDirEnv names a directory of PEM files, one key per file named <kid>.pem.
+	// Private keys (RSA or Ed25519, PKCS#1 or PKCS#8) can sign and verify; public
+	// keys only verify, which is how a retired key is kept until its tokens expire.
+	KeysDirEnv = "JWT_KEYS_DIR"
+
+	// ActiveKeyIDEnv selects the kid used for signing. It defaults to the
+	// lexicographically greatest private key, so date-named keys rotate naturally.
+	ActiveKeyIDEnv = "JWT_ACTIVE_KEY_ID"
+)
+
+var (
+	ErrNoKeys          = errors.New("no JWT signing keys loaded")
+	ErrUnknownKeyID    = errors.New("unknown key ID")
+	ErrUnsupportedKey  = errors.New("unsupported key type, expected RSA or Ed25519")
+	ErrKeyAlgMismatch  = errors.New("token algorithm does not match key")
+	ErrActiveKeyPublic = errors.New("active key has no private part")
+)
+
+// signingKey is one key of the key set, identified by its kid.
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey // nil for verify-only keys
+	publicKey  crypto.PublicKey
+}
+
+// keySet holds every key accepted for verification and the one used for signing.
+type keySet struct {
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+// keys is the process-wide key set, populated by LoadKeys.
+var keys *keySet
+
+// LoadKeys reads the signing keys configured through the environment. It must
+// succeed before tokens can be issued or verified.
+func LoadKeys() error {
+	dir := os.Getenv(KeysDirEnv)
+	if dir == "" {
+		return fmt.Errorf("%s is not set", KeysDirEnv)
+	}
+
+	set, err := loadKeySet(dir, os.Getenv(ActiveKeyIDEnv))
+	if err != nil {
+		return err
+	}
+
+	keys = set
+	return nil
+}
+
+// loadKeySet reads every *.pem file in dir and selects the active signing key.
+func loadKeySet(dir, activeID string) (*keySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &keySet{keys: make(map[string]*signingKey)}
+	var privateIDs []string
+	for _, path := range paths {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		set.keys[key.id] = key
+		if key.privateKey != nil {
+			privateIDs = append(privateIDs, key.id)
+		}
+	}
+
+	if len(privateIDs) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	if activeID == "" {
+		sort.Strings(privateIDs)
+		activeID = privateIDs[len(privateIDs)-1]
+	}
+
+	active, ok := set.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, activeID)
+	}
+	if active.privateKey == nil {
+		return nil, ErrActiveKeyPublic
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// loadKeyFile parses a single PEM key file; the file name without extension is the kid.
+func loadKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &signingKey{id: strings.TrimSuffix(filepath.Base(path), ".pem")}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.privateKey, key.publicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.publicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.privateKey, key.publicKey = SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.publicKey = SigningMethodEdDSA, k
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	return key, nil
+}
+
+// lookupVerificationKey is the jwt.Keyfunc selecting the public key named by the token's kid.
+func lookupVerificationKey(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		return nil, ErrNoKeys
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrKeyAlgMismatch
+	}
+
+	return key.publicKey, nil
+}
+
+// JSONWebKey is the public part of a signing key in JWK format (RFC 7517).
+type JSONWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// PublicKey decodes the key. RSA, EC (P-256, P-384, P-521) and Ed25519 keys
+// are supported, which covers what OpenID providers publish.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return key, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, ErrUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicKeySet returns every verification key, so that other services can
+// validate our tokens without sharing a secret.
+func PublicKeySet() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if keys == nil {
+		return set
+	}
+
+	for _, key := range keys.keys {
+		jwk := JSONWebKey{KeyID: key.id, Algorithm: key.method.Alg(), Use: "sig"}
+		switch k := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -0,0 +1,119 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables selecting the mailer implementation.
+const (
+	// MailerEnv is "log" (default) or "file".
+	MailerEnv = "MAILER"
+
+	// MailerDirEnv is the directory the file mailer writes messages to.
+	MailerDirEnv = "MAILER_DIR"
+
+	// MailFromEnv is the sender address used on outgoing messages.
+	MailFromEnv = "MAIL_FROM"
+)
+
+const defaultFrom = "no-reply@bookmysalon.local"
+
+// Message is a plain-text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as verification and password reset links.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewFromEnv returns the mailer configured through the environment.
+func NewFromEnv() (Mailer, error) {
+	from := os.Getenv(MailFromEnv)
+	if from == "" {
+		from = defaultFrom
+	}
+
+	switch kind := os.Getenv(MailerEnv); kind {
+	case "", "log":
+		return &LogMailer{From: from}, nil
+	case "file":
+		dir := os.Getenv(MailerDirEnv)
+		if dir == "" {
+			return nil, fmt.Errorf("%s must be set for the file mailer", MailerDirEnv)
+		}
+		return NewFileMailer(dir, from)
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", kind)
+	}
+}
+
+// LogMailer writes messages to the application log. Intended for local development.
+type LogMailer struct {
+	From string
+}
+
+// Send logs the message instead of delivering it.
+func (m *LogMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	log.Printf("Mail from %s to %s: %s\n%s", msg.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes every message as an .eml file into a directory, so that
+// local setups and tests can pick up the links they contain.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer returns a FileMailer writing into dir, creating it if needed.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+// Send writes the message to a new file in the mailer directory.
+func (m *FileMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().UTC().Format("20060102T150405"), m.seq, sanitize(msg.To))
+	m.mu.Unlock()
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		msg.From, msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
+
+// sanitize keeps a recipient address safe for use in a file name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
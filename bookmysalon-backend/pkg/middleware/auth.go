@@ -4,10 +4,31 @@ package middleware
 
 import (
 	"bookmysalon/pkg/jwt"
+	"context"
 	"net/http"
 	"strings"
 )
 
+// contextKey is an unexported type for keys defined in this package, so that
+// values stored by the middleware cannot collide with keys from other packages.
+type contextKey string
+
+// claimsContextKey is the key under which the verified JWT claims are stored.
+const claimsContextKey contextKey = "userClaims"
+
+// RevocationChecker reports whether a verified access token has since been revoked.
+type RevocationChecker interface {
+	IsRevoked(claims *jwt.Claims) (bool, error)
+}
+
+// revocationChecker is consulted by Authenticate for every request once set.
+var revocationChecker RevocationChecker
+
+// SetRevocationChecker registers the checker Authenticate uses to reject revoked tokens.
+func SetRevocationChecker(c RevocationChecker) {
+	revocationChecker = c
+}
+
 func Authenticate(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -24,12 +45,53 @@ func Authenticate(next http.HandlerFunc) http.HandlerFunc {
 
 		tokenStr := parts[1]
 
-		_, err := jwt.VerifyToken(tokenStr)
+		claims, err := jwt.VerifyToken(tokenStr)
 		if err != nil {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		if revocationChecker != nil {
+			revoked, err := revocationChecker.IsRevoked(claims)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	}
+}
+
+// WithClaims returns a copy of ctx carrying the given claims.
+func WithClaims(ctx context.Context, claims *jwt.Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ClaimsFromContext returns the claims stored by Authenticate, if any.
+func ClaimsFromContext(ctx context.Context) (*jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*jwt.Claims)
+	return claims, ok && claims != nil
+}
+
+// UserIDFromContext returns the ID of the authenticated user.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.UserID == 0 {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// UsernameFromContext returns the username of the authenticated user.
+func UsernameFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Username == "" {
+		return "", false
 	}
+	return claims.Username, true
 }
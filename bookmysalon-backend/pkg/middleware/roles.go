@@ -0,0 +1,47 @@
+// middleware/roles.go
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequireRole returns a middleware that only lets callers holding one of the
+// given roles through. It must be wrapped by Authenticate so that the claims
+// are available on the request context.
+func RequireRole(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			for _, role := range roles {
+				if claims.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		}
+	}
+}
+
+// RoleFromContext returns the platform role of the authenticated user.
+func RoleFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Role == "" {
+		return "", false
+	}
+	return claims.Role, true
+}
+
+// HasRole reports whether the authenticated user holds the given role.
+func HasRole(ctx context.Context, role string) bool {
+	r, ok := RoleFromContext(ctx)
+	return ok && r == role
+}
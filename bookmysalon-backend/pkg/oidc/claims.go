@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IDTokenClaims are the claims of an ID token we rely on.
+type IDTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// Valid checks the time claims; it is called by the JWT parser.
+func (c *IDTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("token is expired")
+	}
+	if c.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("token used before issued")
+	}
+	return nil
+}
+
+// IsEmailVerified reports whether the provider vouches for the email address.
+func (c *IDTokenClaims) IsEmailVerified() bool {
+	return c.Email != "" && bool(c.EmailVerified)
+}
+
+// audience is the aud claim, which is either a single string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts both true and "true", as some providers (Apple) send booleans as strings.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = v == "true"
+	default:
+		*b = false
+	}
+	return nil
+}
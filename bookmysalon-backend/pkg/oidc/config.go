@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ProvidersEnv lists the enabled providers, e.g. "google,apple". Each one is
+// configured through OIDC_<NAME>_* variables:
+//
+//	OIDC_<NAME>_ISSUER         issuer URL, required
+//	OIDC_<NAME>_CLIENT_ID      client ID registered with the provider, required
+//	OIDC_<NAME>_CLIENT_SECRET  client secret, if the provider requires one
+//	OIDC_<NAME>_REDIRECT_URL   redirect URI registered with the provider, required
+//	OIDC_<NAME>_SCOPES         space separated scopes, default "openid email profile"
+//	OIDC_<NAME>_AUTH_URL       authorization endpoint  \
+//	OIDC_<NAME>_TOKEN_URL      token endpoint           } default to the issuer's discovery document
+//	OIDC_<NAME>_JWKS_URL       key set                 /
+const ProvidersEnv = "OIDC_PROVIDERS"
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// Config describes an OpenID provider we accept logins from.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Endpoints left empty are read from <Issuer>/.well-known/openid-configuration.
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+}
+
+// ProvidersFromEnv returns the providers configured through the environment,
+// keyed by name. No provider is configured when ProvidersEnv is empty.
+func ProvidersFromEnv() (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+
+	for _, name := range strings.Split(os.Getenv(ProvidersEnv), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			JWKSURL:      os.Getenv(prefix + "JWKS_URL"),
+		}
+
+		provider, err := NewProvider(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC provider %s: %v", name, err)
+		}
+		providers[name] = provider
+	}
+
+	return providers, nil
+}
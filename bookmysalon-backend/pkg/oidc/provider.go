@@ -0,0 +1,320 @@
+// Package oidc implements the relying-party side of OpenID Connect: the
+// authorization code flow with PKCE and the verification of ID tokens.
+package oidc
+
+import (
+	"bookmysalon/pkg/jwt"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// keySetMinRefresh limits how often the key set is fetched again for an unknown kid.
+	keySetMinRefresh = time.Minute
+
+	// clockSkew is the leeway granted on the exp and iat claims of ID tokens.
+	clockSkew = time.Minute
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrNonceMismatch  = errors.New("ID token nonce does not match")
+)
+
+// Provider is an OpenID provider such as Google or Apple.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	discovered    bool
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider validates the configuration. Endpoints are discovered on first use,
+// so the provider does not have to be reachable when the server starts.
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("issuer, client ID and redirect URL are required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultScopes
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name returns the name the provider was configured under.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL to send the user to. The code challenge is derived
+// from the verifier that has to be presented again to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.cfg.AuthURL + sep + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for the provider's tokens and returns
+// the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %d %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no ID token")
+	}
+	return token.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an
+// ID token and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwtgo.ParseWithClaims(rawIDToken, claims, func(token *jwtgo.Token) (interface{}, error) {
+		// Never let the token pick a symmetric algorithm: the client secret is not a signing key.
+		// The asymmetric methods check that the key has the matching type.
+		if _, ok := token.Method.(*jwtgo.SigningMethodHMAC); ok || token.Method == jwtgo.SigningMethodNone {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if !claims.Audience.contains(p.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return claims, nil
+}
+
+// discover fills in the endpoints missing from the configuration from the
+// issuer's discovery document. A failed discovery is retried on the next call.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered || (p.cfg.AuthURL != "" && p.cfg.TokenURL != "" && p.cfg.JWKSURL != "") {
+		p.discovered = true
+		return nil
+	}
+
+	wellKnown := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	status, err := p.doJSON(req, &doc)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("discovery failed: %d", status)
+	}
+	if doc.Issuer != p.cfg.Issuer {
+		return fmt.Errorf("discovery document is for issuer %q", doc.Issuer)
+	}
+
+	if p.cfg.AuthURL == "" {
+		p.cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if p.cfg.TokenURL == "" {
+		p.cfg.TokenURL = doc.TokenEndpoint
+	}
+	if p.cfg.JWKSURL == "" {
+		p.cfg.JWKSURL = doc.JWKSURI
+	}
+	if p.cfg.AuthURL == "" || p.cfg.TokenURL == "" || p.cfg.JWKSURL == "" {
+		return errors.New("discovery document is missing endpoints")
+	}
+
+	p.discovered = true
+	return nil
+}
+
+// key returns the provider's public key with the given kid. The key set is
+// fetched again when the kid is unknown, since providers rotate their keys.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keySetMinRefresh {
+		return nil, jwt.ErrUnknownKeyID
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwt.JSONWebKeySet
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set failed: %d", status)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the whole set.
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, jwt.ErrUnknownKeyID
+}
+
+// lookupKey finds a cached key. A token without kid is accepted only when the set has a single key.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636).
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge derives the S256 code challenge of a verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns a random value for the state or nonce parameters.
+func NewState() (string, error) {
+	return randomString(32)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
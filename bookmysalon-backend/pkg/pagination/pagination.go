@@ -0,0 +1,328 @@
+// Package pagination reads the limit, cursor, sort and filter parameters of
+// list endpoints and turns them into keyset-paginated SQL queries.
+//
+// A list declares a Spec: the columns it can be sorted by and the filters it
+// accepts. Anything else is rejected, so clients cannot order or filter by
+// arbitrary columns. The cursor of the next page holds the sort value and the
+// ID of the last item, base64 encoded; clients pass it back unchanged. Items
+// without a sort value come last in either direction.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the page size.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidLimit  = errors.New("limit must be a positive number")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("sort is not supported by this list")
+)
+
+// Field is a sortable column and the JSON field of the listed items holding its value.
+type Field struct {
+	Column string
+	JSON   string
+}
+
+// Type is the type of a filter value.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Filter is a query parameter comparing a column to its value.
+type Filter struct {
+	Column string
+	// Op is the SQL comparison operator, "=" by default.
+	Op   string
+	Type Type
+}
+
+// Spec describes how a list can be paginated, sorted and filtered.
+type Spec struct {
+	// ID is the unique column that breaks ties between equal sort values.
+	ID Field
+
+	// Sorts are the accepted values of the sort parameter. A leading "-"
+	// reverses the order, as in "-date_time".
+	Sorts map[string]Field
+
+	// DefaultSort applies when no sort is given, e.g. "name" or "-rating".
+	DefaultSort string
+
+	// Filters are the accepted filter parameters, by name.
+	Filters map[string]Filter
+}
+
+// Query is a validated page request.
+type Query struct {
+	Limit int
+
+	sort    string
+	order   Field
+	desc    bool
+	id      Field
+	after   *cursor
+	filters []condition
+}
+
+type condition struct {
+	sql   string
+	value interface{}
+}
+
+type cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    json.RawMessage `json:"id"`
+}
+
+// Page is the envelope of a paginated list. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// Parse reads the limit, cursor, sort and filter parameters of a request.
+// Parameters that are not part of the spec are left alone.
+func Parse(r *http.Request, spec Spec) (Query, error) {
+	params := r.URL.Query()
+	q := Query{Limit: DefaultLimit, id: spec.ID}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return Query{}, ErrInvalidLimit
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+		q.Limit = limit
+	}
+
+	q.sort = params.Get("sort")
+	if q.sort == "" {
+		q.sort = spec.DefaultSort
+	}
+	field, ok := spec.Sorts[strings.TrimPrefix(q.sort, "-")]
+	if !ok {
+		return Query{}, ErrInvalidSort
+	}
+	q.order = field
+	q.desc = strings.HasPrefix(q.sort, "-")
+
+	if value := params.Get("cursor"); value != "" {
+		after, err := decodeCursor(value)
+		if err != nil || after.Sort != q.sort {
+			return Query{}, ErrInvalidCursor
+		}
+		q.after = after
+	}
+
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		filter := spec.Filters[name]
+		raw := params.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := parseValue(raw, filter.Type)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid value for %s: %q", name, raw)
+		}
+		op := filter.Op
+		if op == "" {
+			op = "="
+		}
+		q.filters = append(q.filters, condition{sql: filter.Column + " " + op + " ", value: value})
+	}
+
+	return q, nil
+}
+
+// SQL completes a query of the form "SELECT ... FROM ...", whose own
+// conditions are in where (if any) with the parameters args, by the filters,
+// the cursor, the order and the limit of the page. One more row than the
+// limit is fetched to tell whether there is a next page.
+func (q Query) SQL(selectFrom, where string, args ...interface{}) (string, []interface{}) {
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var conditions []string
+	if where != "" {
+		conditions = append(conditions, "("+where+")")
+	}
+	for _, c := range q.filters {
+		conditions = append(conditions, c.sql+param(c.value))
+	}
+	if q.after != nil {
+		op := ">"
+		if q.desc {
+			op = "<"
+		}
+		switch {
+		case q.order.Column == q.id.Column:
+			conditions = append(conditions, q.id.Column+" "+op+" "+param(jsonValue(q.after.ID)))
+		case isNull(q.after.Value):
+			// Rows without a sort value come last, ordered by ID.
+			conditions = append(conditions, "("+q.order.Column+" IS NULL AND "+q.id.Column+" "+op+" "+param(jsonValue(q.after.ID))+")")
+		default:
+			// A row comparison with NULL is NULL, so the rows without a sort
+			// value, which follow all others, are added explicitly.
+			conditions = append(conditions, "(("+q.order.Column+", "+q.id.Column+") "+op+
+				" ("+param(jsonValue(q.after.Value))+", "+param(jsonValue(q.after.ID))+") OR "+q.order.Column+" IS NULL)")
+		}
+	}
+
+	query := selectFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	direction := " ASC"
+	if q.desc {
+		direction = " DESC"
+	}
+	if q.order.Column == q.id.Column {
+		query += " ORDER BY " + q.id.Column + direction
+	} else {
+		query += " ORDER BY " + q.order.Column + direction + " NULLS LAST, " + q.id.Column + direction
+	}
+	query += " LIMIT " + param(q.Limit+1)
+
+	return query, args
+}
+
+// NewPage cuts the items fetched by a query built with SQL down to the page
+// and sets the cursor of the next one.
+func NewPage[T any](items []T, q Query) (*Page[T], error) {
+	page := &Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) <= q.Limit {
+		return page, nil
+	}
+
+	page.Items = items[:q.Limit]
+	next, err := q.cursorAfter(page.Items[q.Limit-1])
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = next
+	return page, nil
+}
+
+// cursorAfter encodes the position of an item, read from its JSON fields.
+func (q Query) cursorAfter(item interface{}) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+
+	c := cursor{Sort: q.sort, Value: fields[q.order.JSON], ID: fields[q.id.JSON]}
+	if c.ID == nil || (q.order.Column != q.id.Column && c.Value == nil) {
+		return "", fmt.Errorf("pagination: item has no field %q or %q", q.order.JSON, q.id.JSON)
+	}
+
+	data, err = json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// isNull reports whether a value of a cursor is a JSON null.
+func isNull(raw json.RawMessage) bool {
+	return strings.TrimSpace(string(raw)) == "null"
+}
+
+// jsonValue turns a value of a cursor into a query parameter.
+func jsonValue(raw json.RawMessage) interface{} {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string, bool:
+		return v
+	default:
+		return nil
+	}
+}
+
+// timeLayouts are the accepted formats of Time filters. Times without UTC
+// offset are taken as they are, like the times stored in the database.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func parseValue(raw string, t Type) (interface{}, error) {
+	switch t {
+	case Int:
+		return strconv.Atoi(raw)
+	case Float:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(f) {
+			return nil, strconv.ErrSyntax
+		}
+		return f, nil
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		for _, layout := range timeLayouts {
+			if value, err := time.Parse(layout, raw); err == nil {
+				return value.Format("2006-01-02 15:04:05"), nil
+			}
+		}
+		return nil, strconv.ErrSyntax
+	default:
+		return raw, nil
+	}
+}
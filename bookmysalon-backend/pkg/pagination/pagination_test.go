@@ -0,0 +1,229 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var testSpec = Spec{
+	ID: Field{Column: "salon_id", JSON: "salon_id"},
+	Sorts: map[string]Field{
+		"id":             {Column: "salon_id", JSON: "salon_id"},
+		"name":           {Column: "name", JSON: "name"},
+		"average_rating": {Column: "average_rating", JSON: "average_rating"},
+	},
+	DefaultSort: "name",
+	Filters: map[string]Filter{
+		"city":       {Column: "city", Type: String},
+		"min_rating": {Column: "average_rating", Op: ">=", Type: Float},
+		"from":       {Column: "created_at", Op: ">=", Type: Time},
+	},
+}
+
+type testSalon struct {
+	SalonID       int      `json:"salon_id"`
+	Name          string   `json:"name"`
+	AverageRating *float64 `json:"average_rating"`
+}
+
+func parse(t *testing.T, rawQuery string) (Query, error) {
+	t.Helper()
+	return Parse(httptest.NewRequest("GET", "/salons?"+rawQuery, nil), testSpec)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantSort  string
+		wantErr   bool
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultLimit, wantSort: "name"},
+		{name: "limit", query: "limit=10", wantLimit: 10, wantSort: "name"},
+		{name: "limit capped", query: "limit=1000", wantLimit: MaxLimit, wantSort: "name"},
+		{name: "descending sort", query: "sort=-average_rating", wantLimit: DefaultLimit, wantSort: "-average_rating"},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "bad limit", query: "limit=ten", wantErr: true},
+		{name: "unknown sort", query: "sort=password", wantErr: true},
+		{name: "bad filter", query: "min_rating=high", wantErr: true},
+		{name: "bad time filter", query: "from=yesterday", wantErr: true},
+		{name: "bad cursor", query: "cursor=not-a-cursor", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parse(t, tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want an error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			if q.Limit != tt.wantLimit || q.sort != tt.wantSort {
+				t.Errorf("Parse(%q) = limit %d, sort %q; want %d, %q", tt.query, q.Limit, q.sort, tt.wantLimit, tt.wantSort)
+			}
+		})
+	}
+}
+
+func TestSQL(t *testing.T) {
+	const selectFrom = "SELECT * FROM salons"
+
+	tests := []struct {
+		name     string
+		query    string
+		where    string
+		args     []interface{}
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "first page",
+			query:    "limit=2",
+			wantSQL:  "SELECT * FROM salons ORDER BY name ASC NULLS LAST, salon_id ASC LIMIT $1",
+			wantArgs: []interface{}{3},
+		},
+		{
+			name:     "sorted by ID",
+			query:    "sort=-id&limit=2",
+			wantSQL:  "SELECT * FROM salons ORDER BY salon_id DESC LIMIT $1",
+			wantArgs: []interface{}{3},
+		},
+		{
+			name:     "own condition and filters",
+			query:    "city=Berlin&min_rating=4&from=2026-10-17",
+			where:    "status = $1",
+			args:     []interface{}{"approved"},
+			wantSQL:  "SELECT * FROM salons WHERE (status = $1) AND city = $2 AND created_at >= $3 AND average_rating >= $4 ORDER BY name ASC NULLS LAST, salon_id ASC LIMIT $5",
+			wantArgs: []interface{}{"approved", "Berlin", "2026-10-17 00:00:00", 4.0, 51},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parse(t, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sql, args := q.SQL(selectFrom, tt.where, tt.args...)
+			if sql != tt.wantSQL {
+				t.Errorf("SQL() =\n%s\nwant\n%s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("SQL() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	rated := 4.5
+
+	tests := []struct {
+		name     string
+		sort     string
+		last     testSalon
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "by name",
+			sort:     "name",
+			last:     testSalon{SalonID: 7, Name: "Cut & Go"},
+			wantSQL:  "SELECT * FROM salons WHERE ((name, salon_id) > ($1, $2) OR name IS NULL) ORDER BY name ASC NULLS LAST, salon_id ASC LIMIT $3",
+			wantArgs: []interface{}{"Cut & Go", "7", 2},
+		},
+		{
+			name:     "by rating, descending",
+			sort:     "-average_rating",
+			last:     testSalon{SalonID: 7, AverageRating: &rated},
+			wantSQL:  "SELECT * FROM salons WHERE ((average_rating, salon_id) < ($1, $2) OR average_rating IS NULL) ORDER BY average_rating DESC NULLS LAST, salon_id DESC LIMIT $3",
+			wantArgs: []interface{}{"4.5", "7", 2},
+		},
+		{
+			name:     "after an unrated salon",
+			sort:     "-average_rating",
+			last:     testSalon{SalonID: 7},
+			wantSQL:  "SELECT * FROM salons WHERE (average_rating IS NULL AND salon_id < $1) ORDER BY average_rating DESC NULLS LAST, salon_id DESC LIMIT $2",
+			wantArgs: []interface{}{"7", 2},
+		},
+		{
+			name:     "by ID",
+			sort:     "id",
+			last:     testSalon{SalonID: 7},
+			wantSQL:  "SELECT * FROM salons WHERE salon_id > $1 ORDER BY salon_id ASC LIMIT $2",
+			wantArgs: []interface{}{"7", 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parse(t, "limit=1&sort="+tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page, err := NewPage([]testSalon{tt.last, {SalonID: 8}}, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) != 1 || page.NextCursor == "" {
+				t.Fatalf("NewPage() = %d items, cursor %q; want 1 item and a cursor", len(page.Items), page.NextCursor)
+			}
+
+			next, err := parse(t, "limit=1&sort="+tt.sort+"&cursor="+page.NextCursor)
+			if err != nil {
+				t.Fatalf("Parse(cursor): %v", err)
+			}
+			sql, args := next.SQL("SELECT * FROM salons", "")
+			if sql != tt.wantSQL {
+				t.Errorf("SQL() =\n%s\nwant\n%s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("SQL() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCursorOfOtherSort(t *testing.T) {
+	q, err := parse(t, "limit=1&sort=name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := NewPage([]testSalon{{SalonID: 1, Name: "A"}, {SalonID: 2, Name: "B"}}, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parse(t, "sort=-name&cursor="+page.NextCursor); err != ErrInvalidCursor {
+		t.Errorf("Parse() with the cursor of another sort: err = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestLastPage(t *testing.T) {
+	q, err := parse(t, "limit=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := NewPage([]testSalon{{SalonID: 1}, {SalonID: 2}}, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.NextCursor != "" {
+		t.Errorf("NewPage() = %d items, cursor %q; want 2 items and no cursor", len(page.Items), page.NextCursor)
+	}
+
+	empty, err := NewPage[testSalon](nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Items == nil {
+		t.Error("NewPage(nil) has nil items, want an empty list")
+	}
+}
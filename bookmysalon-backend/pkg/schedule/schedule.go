@@ -0,0 +1,193 @@
+// Package schedule works out when a salon is open from its weekly opening
+// hours, its date-specific exceptions and its timezone.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	// Salons may be in any timezone, whatever the host has installed.
+	_ "time/tzdata"
+)
+
+const (
+	// DateLayout is the format of calendar dates.
+	DateLayout = "2006-01-02"
+
+	// WallLayout is the format times are stored in: the wall time of the
+	// salon, without UTC offset.
+	WallLayout = "2006-01-02T15:04:05"
+
+	// minutesPerDay is the closing time of an interval that runs until midnight.
+	minutesPerDay = 24 * 60
+)
+
+var (
+	ErrInvalidClock    = errors.New("time of day must be given as HH:MM")
+	ErrInvalidInterval = errors.New("opening time must be before closing time")
+	ErrInvalidTime     = errors.New("invalid date and time")
+	ErrInvalidPeriod   = errors.New("end must be after start")
+	ErrOutsideHours    = errors.New("outside the salon's opening hours")
+)
+
+// Interval is a period of a day during which a salon is open, in minutes after
+// local midnight. Intervals do not span midnight; Closes may be 24:00.
+type Interval struct {
+	Opens  int
+	Closes int
+}
+
+// NewInterval parses an interval from two HH:MM times of day.
+func NewInterval(opens, closes string) (Interval, error) {
+	o, err := ParseClock(opens)
+	if err != nil {
+		return Interval{}, err
+	}
+	c, err := ParseClock(closes)
+	if err != nil {
+		return Interval{}, err
+	}
+	if o >= c {
+		return Interval{}, ErrInvalidInterval
+	}
+	return Interval{Opens: o, Closes: c}, nil
+}
+
+// Exception replaces the weekly hours from From to To, both inclusive dates in
+// DateLayout. An exception without interval closes the salon on those dates.
+type Exception struct {
+	From     string
+	To       string
+	Interval *Interval
+}
+
+// Calendar holds everything needed to tell whether a salon is open.
+type Calendar struct {
+	Location   *time.Location
+	Weekly     map[time.Weekday][]Interval
+	Exceptions []Exception
+}
+
+// HoursOn returns the intervals the salon is open on a date, sorted by opening time.
+// Exceptions covering the date take precedence over the weekly hours, and a
+// closure takes precedence over special hours. A salon that has not set any
+// weekly hours is open all day unless an exception says otherwise.
+func (c *Calendar) HoursOn(date time.Time) []Interval {
+	day := date.Format(DateLayout)
+
+	var special []Interval
+	excepted := false
+	for _, e := range c.Exceptions {
+		if day < e.From || day > e.To {
+			continue
+		}
+		if e.Interval == nil {
+			return nil
+		}
+		excepted = true
+		special = append(special, *e.Interval)
+	}
+
+	var hours []Interval
+	switch {
+	case excepted:
+		hours = special
+	case len(c.Weekly) == 0:
+		return []Interval{{Opens: 0, Closes: minutesPerDay}}
+	default:
+		hours = append(hours, c.Weekly[date.Weekday()]...)
+	}
+
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Opens < hours[j].Opens })
+	return merge(hours)
+}
+
+// IsOpen reports whether the salon is open for the whole period from start to end.
+func (c *Calendar) IsOpen(start, end time.Time) bool {
+	start = start.In(c.Location)
+	end = end.In(c.Location)
+	if !end.After(start) {
+		return false
+	}
+
+	day := midnight(start)
+	from := minutesSince(day, start)
+	to := minutesSince(day, end)
+	if to > minutesPerDay {
+		// Intervals end at midnight at the latest.
+		return false
+	}
+
+	for _, interval := range c.HoursOn(day) {
+		if interval.Opens <= from && to <= interval.Closes {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseClock parses a time of day given as HH:MM or HH:MM:SS into minutes after
+// midnight. 24:00 is accepted as the end of the day.
+func ParseClock(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" || value == "24:00:00" {
+		return minutesPerDay, nil
+	}
+
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, ErrInvalidClock
+}
+
+// FormatClock formats minutes after midnight as HH:MM.
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseTime parses a date and time. Values without UTC offset are taken to be
+// in loc, the salon's timezone.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidTime
+}
+
+// merge joins overlapping or adjacent intervals of a sorted slice.
+func merge(hours []Interval) []Interval {
+	var merged []Interval
+	for _, interval := range hours {
+		if n := len(merged); n > 0 && interval.Opens <= merged[n-1].Closes {
+			if interval.Closes > merged[n-1].Closes {
+				merged[n-1].Closes = interval.Closes
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// minutesSince counts the wall clock minutes from local midnight of day to t,
+// so that opening hours keep their meaning on days with a DST change.
+func minutesSince(day, t time.Time) int {
+	days := int(midnight(t).Sub(day).Hours()+12) / 24
+	return days*minutesPerDay + t.Hour()*60 + t.Minute()
+}
@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrSalonNotFound = errors.New("salon not found")
+
+// Load reads the calendar of a salon. Only the exceptions touching the dates
+// between from and to are loaded.
+func Load(db *sql.DB, salonID int, from, to time.Time) (*Calendar, error) {
+	loc, err := Location(db, salonID)
+	if err != nil {
+		return nil, err
+	}
+	return load(db, salonID, loc, from, to)
+}
+
+func load(db *sql.DB, salonID int, loc *time.Location, from, to time.Time) (*Calendar, error) {
+	cal := &Calendar{Location: loc, Weekly: map[time.Weekday][]Interval{}}
+
+	rows, err := db.Query(`SELECT weekday, opens_at, closes_at FROM salon_opening_hours WHERE salon_id=$1`, salonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var weekday int
+		var opens, closes string
+		if err := rows.Scan(&weekday, &opens, &closes); err != nil {
+			return nil, err
+		}
+		interval, err := NewInterval(opens, closes)
+		if err != nil {
+			return nil, err
+		}
+		cal.Weekly[time.Weekday(weekday)] = append(cal.Weekly[time.Weekday(weekday)], interval)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const exceptionsQuery = `
+		SELECT TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'), opens_at, closes_at
+		FROM salon_hours_exceptions
+		WHERE salon_id=$1 AND end_date >= $2 AND start_date <= $3
+	`
+
+	exceptions, err := db.Query(exceptionsQuery, salonID,
+		from.In(loc).Format(DateLayout), to.In(loc).Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer exceptions.Close()
+
+	for exceptions.Next() {
+		var e Exception
+		var opens, closes sql.NullString
+		if err := exceptions.Scan(&e.From, &e.To, &opens, &closes); err != nil {
+			return nil, err
+		}
+		if opens.Valid && closes.Valid {
+			interval, err := NewInterval(opens.String, closes.String)
+			if err != nil {
+				return nil, err
+			}
+			e.Interval = &interval
+		}
+		cal.Exceptions = append(cal.Exceptions, e)
+	}
+	return cal, exceptions.Err()
+}
+
+// Location returns the timezone of a salon.
+func Location(db *sql.DB, salonID int) (*time.Location, error) {
+	var name string
+	if err := db.QueryRow(`SELECT timezone FROM salons WHERE salon_id=$1`, salonID).Scan(&name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSalonNotFound
+		}
+		return nil, err
+	}
+	return time.LoadLocation(name)
+}
+
+// WallTime converts a date and time to the wall time of a salon, the form it
+// is stored in. Times with a UTC offset are moved into the salon's timezone;
+// the database would otherwise drop the offset and keep the wrong wall time.
+func WallTime(db *sql.DB, salonID int, value string) (string, error) {
+	loc, err := Location(db, salonID)
+	if err != nil {
+		return "", err
+	}
+	t, err := ParseTime(value, loc)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(WallLayout), nil
+}
+
+// CheckOpen verifies that a salon is open for the whole period from start to
+// end. Times without UTC offset are in the salon's timezone.
+func CheckOpen(db *sql.DB, salonID int, start, end string) error {
+	loc, err := Location(db, salonID)
+	if err != nil {
+		return err
+	}
+	startTime, err := ParseTime(start, loc)
+	if err != nil {
+		return err
+	}
+	endTime, err := ParseTime(end, loc)
+	if err != nil {
+		return err
+	}
+	return checkOpen(db, salonID, loc, startTime, endTime)
+}
+
+// CheckOpenFor is CheckOpen for a booking starting at start and lasting length,
+// which keeps the salon busy from before ahead of it until after past its end,
+// e.g. for preparation and cleanup.
+func CheckOpenFor(db *sql.DB, salonID int, start string, before, length, after time.Duration) error {
+	loc, err := Location(db, salonID)
+	if err != nil {
+		return err
+	}
+	startTime, err := ParseTime(start, loc)
+	if err != nil {
+		return err
+	}
+	return checkOpen(db, salonID, loc, startTime.Add(-before), startTime.Add(length+after))
+}
+
+func checkOpen(db *sql.DB, salonID int, loc *time.Location, start, end time.Time) error {
+	if !end.After(start) {
+		return ErrInvalidPeriod
+	}
+
+	cal, err := load(db, salonID, loc, start, end)
+	if err != nil {
+		return err
+	}
+	if !cal.IsOpen(start, end) {
+		return ErrOutsideHours
+	}
+	return nil
+}
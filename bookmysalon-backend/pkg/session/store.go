@@ -0,0 +1,52 @@
+package session
+
+import (
+	"bookmysalon/pkg/jwt"
+	"errors"
+	"time"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used before the user has to log in again.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// The whole session is revoked, since the token has most likely been stolen.
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+// TokenPair is the set of tokens handed to a client after login or refresh.
+type TokenPair struct {
+	// The JWT access token, sent as a Bearer token on API calls.
+	AccessToken string `json:"token"`
+
+	// The opaque refresh token, exchanged at /token/refresh for a new pair.
+	RefreshToken string `json:"refresh_token"`
+
+	// Always "Bearer".
+	TokenType string `json:"token_type"`
+
+	// Lifetime of the access token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Store issues, rotates and revokes login sessions.
+type Store interface {
+	// Issue starts a new session for the user and returns its first token pair.
+	Issue(userID int, username, role string) (*TokenPair, error)
+
+	// Refresh rotates a refresh token: the presented token is revoked and a new pair is returned.
+	Refresh(refreshToken string) (*TokenPair, error)
+
+	// Revoke ends the session the given access token belongs to.
+	Revoke(claims *jwt.Claims) error
+
+	// RevokeAll ends every session of the user, logging them out on all devices.
+	RevokeAll(userID int) error
+
+	// IsRevoked reports whether the given access token has been revoked.
+	IsRevoked(claims *jwt.Claims) (bool, error)
+}
@@ -0,0 +1,250 @@
+package session
+
+import (
+	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/jwt"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// storeImpl is the database-backed implementation of the Store interface.
+type storeImpl struct {
+	db *sql.DB
+}
+
+// NewStore initializes and returns an instance of Store.
+func NewStore() (Store, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &storeImpl{
+		db: db,
+	}, nil
+}
+
+// Issue starts a new session for the user and returns its first token pair.
+func (s *storeImpl) Issue(userID int, username, role string) (*TokenPair, error) {
+	sessionID, err := jwt.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pair, _, err := issuePair(tx, userID, username, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new pair is returned.
+func (s *storeImpl) Refresh(refreshToken string) (*TokenPair, error) {
+	const selectQuery = `
+		SELECT rt.token_id, rt.user_id, rt.session_id, rt.revoked_at IS NOT NULL, rt.expires_at <= NOW(), u.username, u.role
+		FROM refresh_tokens rt JOIN users u ON u.id = rt.user_id
+		WHERE rt.token_hash=$1
+		FOR UPDATE OF rt
+	`
+	const rotateQuery = `UPDATE refresh_tokens SET revoked_at=NOW(), replaced_by=$1 WHERE token_id=$2`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID, userID    int
+		sessionID          string
+		revoked, expired   bool
+		username, userRole string
+	)
+	err = tx.QueryRow(selectQuery, hashToken(refreshToken)).Scan(&tokenID, &userID, &sessionID, &revoked, &expired, &username, &userRole)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidRefreshToken
+		}
+		log.Printf("Error looking up refresh token: %v", err)
+		return nil, err
+	}
+
+	if revoked {
+		// A rotated token was replayed: treat the session as compromised.
+		tx.Rollback()
+		if err := s.revokeSession(sessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if expired {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, newTokenID, err := issuePair(tx, userID, username, userRole, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(rotateQuery, newTokenID, tokenID); err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Revoke ends the session the given access token belongs to.
+func (s *storeImpl) Revoke(claims *jwt.Claims) error {
+	const revokeAccessQuery = `
+		INSERT INTO revoked_access_tokens(token_id, expires_at) VALUES($1, to_timestamp($2))
+		ON CONFLICT (token_id) DO NOTHING
+	`
+	const purgeQuery = `DELETE FROM revoked_access_tokens WHERE expires_at < NOW()`
+
+	if _, err := s.db.Exec(revokeAccessQuery, claims.Id, claims.ExpiresAt); err != nil {
+		log.Printf("Error revoking access token: %v", err)
+		return err
+	}
+
+	if err := s.revokeSession(claims.SessionID); err != nil {
+		return err
+	}
+
+	// Entries are only needed until the token would have expired anyway.
+	if _, err := s.db.Exec(purgeQuery); err != nil {
+		log.Printf("Error purging revoked access tokens: %v", err)
+	}
+	return nil
+}
+
+// RevokeAll ends every session of the user, logging them out on all devices.
+func (s *storeImpl) RevokeAll(userID int) error {
+	const userQuery = `UPDATE users SET tokens_revoked_before=$2 WHERE id=$1`
+	const refreshQuery = `UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(userQuery, userID, revocationCutoff(time.Now())); err != nil {
+		log.Printf("Error revoking access tokens of user: %v", err)
+		return err
+	}
+	if _, err := tx.Exec(refreshQuery, userID); err != nil {
+		log.Printf("Error revoking refresh tokens of user: %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsRevoked reports whether the given access token has been revoked, either
+// individually through its jti or by a "log out all devices" issued after it.
+func (s *storeImpl) IsRevoked(claims *jwt.Claims) (bool, error) {
+	const query = `
+		SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE token_id=$1),
+			(SELECT tokens_revoked_before FROM users WHERE id=$2)
+	`
+
+	var revoked bool
+	var cutoff sql.NullInt64
+	if err := s.db.QueryRow(query, claims.Id, claims.UserID).Scan(&revoked, &cutoff); err != nil {
+		log.Printf("Error checking token revocation: %v", err)
+		return false, err
+	}
+	return revoked || (cutoff.Valid && issuedBefore(claims, cutoff.Int64)), nil
+}
+
+// revocationCutoff returns the cutoff RevokeAll records at now. Tokens carry
+// their issue time in whole seconds, so the cutoff is the second of now:
+// tokens issued from then on, such as the pair a caller hands out right
+// after revoking, stay valid. Access tokens issued earlier within that second
+// slip through until they expire; their refresh tokens are revoked anyway.
+func revocationCutoff(now time.Time) int64 {
+	return now.Unix()
+}
+
+// issuedBefore reports whether a token was issued before a revocation cutoff.
+func issuedBefore(claims *jwt.Claims, cutoff int64) bool {
+	return claims.IssuedAt < cutoff
+}
+
+// revokeSession revokes every outstanding refresh token of a session.
+func (s *storeImpl) revokeSession(sessionID string) error {
+	const query = `UPDATE refresh_tokens SET revoked_at=NOW() WHERE session_id=$1 AND revoked_at IS NULL`
+
+	if _, err := s.db.Exec(query, sessionID); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return err
+	}
+	return nil
+}
+
+// issuePair generates an access token and persists a new refresh token for the session.
+// It returns the pair together with the ID of the stored refresh token row.
+func issuePair(tx *sql.Tx, userID int, username, role, sessionID string) (*TokenPair, int, error) {
+	const query = `
+		INSERT INTO refresh_tokens(user_id, session_id, token_hash, expires_at)
+		VALUES($1, $2, $3, NOW() + $4 * INTERVAL '1 second') RETURNING token_id
+	`
+
+	accessToken, err := jwt.GenerateToken(userID, username, role, sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var tokenID int
+	err = tx.QueryRow(query, userID, sessionID, hashToken(refreshToken), int64(RefreshTokenTTL.Seconds())).Scan(&tokenID)
+	if err != nil {
+		log.Printf("Error storing refresh token: %v", err)
+		return nil, 0, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(jwt.AccessTokenTTL.Seconds()),
+	}, tokenID, nil
+}
+
+// newRefreshToken returns a random, URL-safe opaque token.
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token. Only hashes are stored, so a
+// database leak does not expose usable refresh tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
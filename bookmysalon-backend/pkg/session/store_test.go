@@ -0,0 +1,34 @@
+package session
+
+import (
+	"bookmysalon/pkg/jwt"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+func TestRevocationCutoff(t *testing.T) {
+	revokedAt := time.Date(2026, 10, 17, 12, 0, 0, 999999000, time.UTC)
+	cutoff := revocationCutoff(revokedAt)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"issued a second earlier", revokedAt.Add(-time.Second), true},
+		{"issued earlier the same second", revokedAt.Add(-500 * time.Millisecond), false},
+		{"issued right after revoking", revokedAt.Add(time.Microsecond), false},
+		{"issued a second later", revokedAt.Add(time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &jwt.Claims{StandardClaims: jwtgo.StandardClaims{IssuedAt: tt.issuedAt.Unix()}}
+			if got := issuedBefore(claims, cutoff); got != tt.revoked {
+				t.Errorf("issuedBefore(%v, %d) = %v, want %v", tt.issuedAt, cutoff, got, tt.revoked)
+			}
+		})
+	}
+}
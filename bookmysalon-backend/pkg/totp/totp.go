@@ -0,0 +1,123 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as used by
+// authenticator apps: HMAC-SHA1, 6 digits, 30 second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the number of digits in a code.
+	Digits = 6
+
+	// Period is how long a code stays current.
+	Period = 30 * time.Second
+
+	// Skew is the number of steps before and after the current one that are
+	// still accepted, to allow for clock drift on the user's device.
+	Skew = 1
+
+	// secretSize is the length of generated secrets in bytes (160 bits, as recommended by RFC 4226).
+	secretSize = 20
+)
+
+// ErrInvalidSecret is returned for secrets that are not valid base32.
+var ErrInvalidSecret = errors.New("invalid TOTP secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret, base32 encoded without padding.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// URI that authenticator apps import, usually through a QR code.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step returns the time step t falls into.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for the given secret and time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step), nil
+}
+
+// Validate checks a code against the steps around t. It returns the matched
+// step, so that callers can reject a code that has already been used.
+func Validate(secret, passcode string, t time.Time) (int64, bool) {
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != Digits {
+		return 0, false
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		if hmac.Equal([]byte(code(key, step)), []byte(passcode)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// code computes the HOTP value (RFC 4226) of key for the given counter.
+func code(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
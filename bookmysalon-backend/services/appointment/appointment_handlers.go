@@ -2,7 +2,12 @@ package appointment
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,19 +18,22 @@ import (
 
 type AppointmentHandler struct {
 	service AppointmentService
+	policy  authz.Policy
 }
 
-func NewAppointmentHandler(s AppointmentService) *AppointmentHandler {
-	return &AppointmentHandler{service: s}
+func NewAppointmentHandler(s AppointmentService, p authz.Policy) *AppointmentHandler {
+	return &AppointmentHandler{service: s, policy: p}
 }
 
 // @Summary Create a new appointment
-// @Description Create a new appointment with the input payload
+// @Description Create a new appointment with the input payload. The total price and duration are worked out from the service or the chosen variant and the add-ons, and the salon must be open for the whole of it. With an availability_id the appointment is booked into that slot, which is claimed in the same transaction.
 // @Accept  json
 // @Produce  json
 // @Param appointment body models.Appointment true "Create Appointment"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string "Slot Already Booked"
 // @Failure 500 {object} map[string]string
 // @Router /appointment [post]
 func (h *AppointmentHandler) CreateAppointment(w http.ResponseWriter, r *http.Request) {
@@ -36,10 +44,37 @@ func (h *AppointmentHandler) CreateAppointment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Customers book for themselves; booking on behalf of someone else is
+	// reserved for the salon's owners and staff.
+	if appointment.UserID == 0 {
+		appointment.UserID = userID
+	} else if appointment.UserID != userID {
+		if err := h.policy.AuthorizeSalon(r.Context(), appointment.SalonID); err != nil {
+			authz.WriteError(w, err)
+			return
+		}
+	}
+
 	newAppointment, err := h.service.Create(&appointment)
 	if err != nil {
-		log.Println("Failed to create appointment:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrInvalidStaff, ErrServiceNotFound, ErrSalonNotBookable, ErrVariantRequired, ErrInvalidVariant, ErrInvalidAddOn,
+			ErrSlotNotFound, ErrSlotMismatch, ErrSlotTooShort, schedule.ErrInvalidTime, schedule.ErrOutsideHours:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case schedule.ErrSalonNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrSlotTaken:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Println("Failed to create appointment:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -53,6 +88,7 @@ func (h *AppointmentHandler) CreateAppointment(w http.ResponseWriter, r *http.Re
 // @Param appointmentID path int true "Appointment ID"
 // @Success 200 {object} models.Appointment
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string "Appointment Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID} [get]
@@ -64,14 +100,8 @@ func (h *AppointmentHandler) GetAppointmentDetails(w http.ResponseWriter, r *htt
 		return
 	}
 
-	appointment, err := h.service.GetByID(appointmentID)
-	if err != nil {
-		switch err {
-		case ErrAppointmentNotFound:
-			http.Error(w, err.Error(), http.StatusNotFound)
-		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+	appointment, ok := h.authorizeAppointment(w, r, appointmentID)
+	if !ok {
 		return
 	}
 
@@ -79,12 +109,15 @@ func (h *AppointmentHandler) GetAppointmentDetails(w http.ResponseWriter, r *htt
 }
 
 // @Summary Update appointment details
-// @Description Update details of an existing appointment
+// @Description Update the notification settings and add-ons of a requested or confirmed appointment, which is priced again. Its service, variant, staff member and time are changed by rescheduling it, and its status through the status endpoints.
 // @Accept  json
 // @Produce  json
 // @Param appointment body models.Appointment true "Update Appointment"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 409 {object} map[string]string "Appointment Closed Or Booking Changed"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/update [put]
 func (h *AppointmentHandler) UpdateAppointmentDetails(w http.ResponseWriter, r *http.Request) {
@@ -95,10 +128,24 @@ func (h *AppointmentHandler) UpdateAppointmentDetails(w http.ResponseWriter, r *
 		return
 	}
 
+	if _, ok := h.authorizeAppointment(w, r, appointment.AppointmentID); !ok {
+		return
+	}
+
 	updatedAppointment, err := h.service.Update(&appointment)
 	if err != nil {
-		log.Println("Failed to update appointment:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrServiceNotFound, ErrSalonNotBookable, ErrVariantRequired, ErrInvalidVariant, ErrInvalidAddOn,
+			ErrSlotTooShort, schedule.ErrInvalidTime, schedule.ErrOutsideHours:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case schedule.ErrSalonNotFound, ErrAppointmentNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrAppointmentClosed, ErrBookingFixed:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Println("Failed to update appointment:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -106,12 +153,13 @@ func (h *AppointmentHandler) UpdateAppointmentDetails(w http.ResponseWriter, r *
 }
 
 // @Summary Delete an appointment
-// @Description Delete an appointment by its ID
+// @Description Delete an appointment by its ID. Only the salon's owners and staff and the admins can delete appointments; customers cancel them, which applies the salon's cancellation policy.
 // @Accept  json
 // @Produce  json
 // @Param appointmentID path int true "Appointment ID"
 // @Success 204 "Successfully deleted"
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string "Appointment Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID} [delete]
@@ -123,6 +171,16 @@ func (h *AppointmentHandler) DeleteAppointment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	appointment, ok := h.authorizeAppointment(w, r, appointmentID)
+	if !ok {
+		return
+	}
+	// Deleting skips the cancellation policy, so customers cancel instead.
+	if err := h.policy.AuthorizeSalon(r.Context(), appointment.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
 	err = h.service.Delete(appointmentID)
 	if err != nil {
 		switch err {
@@ -142,8 +200,12 @@ func (h *AppointmentHandler) DeleteAppointment(w http.ResponseWriter, r *http.Re
 // @Accept  json
 // @Produce  json
 // @Param userID path int true "User ID"
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/user/{userID} [get]
 func (h *AppointmentHandler) ListAppointmentsByUserID(w http.ResponseWriter, r *http.Request) {
@@ -154,7 +216,18 @@ func (h *AppointmentHandler) ListAppointmentsByUserID(w http.ResponseWriter, r *
 		return
 	}
 
-	appointments, err := h.service.ListByUserID(userID)
+	if err := h.policy.AuthorizeUser(r.Context(), userID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByUserID(userID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -168,8 +241,12 @@ func (h *AppointmentHandler) ListAppointmentsByUserID(w http.ResponseWriter, r *
 // @Accept  json
 // @Produce  json
 // @Param salonID path int true "Salon ID"
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/salon/{salonID} [get]
 func (h *AppointmentHandler) ListAppointmentsBySalonID(w http.ResponseWriter, r *http.Request) {
@@ -180,7 +257,18 @@ func (h *AppointmentHandler) ListAppointmentsBySalonID(w http.ResponseWriter, r
 		return
 	}
 
-	appointments, err := h.service.ListBySalonID(salonID)
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListBySalonID(salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -194,8 +282,12 @@ func (h *AppointmentHandler) ListAppointmentsBySalonID(w http.ResponseWriter, r
 // @Accept  json
 // @Produce  json
 // @Param serviceID path int true "Service ID"
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/service/{serviceID} [get]
 func (h *AppointmentHandler) ListAppointmentsByServiceID(w http.ResponseWriter, r *http.Request) {
@@ -206,7 +298,59 @@ func (h *AppointmentHandler) ListAppointmentsByServiceID(w http.ResponseWriter,
 		return
 	}
 
-	appointments, err := h.service.ListByServiceID(serviceID)
+	if err := h.policy.AuthorizeService(r.Context(), serviceID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByServiceID(serviceID, page)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(appointments)
+}
+
+// @Summary List appointments by staff ID
+// @Description Retrieve all appointments booked with a specific staff member
+// @Accept  json
+// @Produce  json
+// @Param staffID path int true "Staff ID"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /appointments/staff/{staffID} [get]
+func (h *AppointmentHandler) ListAppointmentsByStaffID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	staffID, err := strconv.Atoi(vars["staffID"])
+	if err != nil {
+		http.Error(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeStaff(r.Context(), staffID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByStaffID(staffID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -219,18 +363,27 @@ func (h *AppointmentHandler) ListAppointmentsByServiceID(w http.ResponseWriter,
 // @Description Retrieve all appointments with a specific status
 // @Accept  json
 // @Produce  json
-// @Param status path string true "Status"
-// @Success 200 {array} models.Appointment
+// @Param status path string true "requested, confirmed, checked_in, in_service, completed, cancelled_by_customer, cancelled_by_salon or no_show"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/status/{status} [get]
 func (h *AppointmentHandler) ListAppointmentsByStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	status := vars["status"]
+	status := models.AppointmentStatus(vars["status"])
 
-	appointments, err := h.service.ListByStatus(status)
+	page, err := pagination.Parse(r, appointmentList)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByStatus(status, page)
+	if err != nil {
+		writeStatusError(w, err)
 		return
 	}
 
@@ -245,6 +398,8 @@ func (h *AppointmentHandler) ListAppointmentsByStatus(w http.ResponseWriter, r *
 // @Param notificationSetting body string true "Notification Setting"
 // @Success 200 {object} models.Appointment
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID}/notification [put]
 func (h *AppointmentHandler) SetNotificationForAppointment(w http.ResponseWriter, r *http.Request) {
@@ -255,6 +410,10 @@ func (h *AppointmentHandler) SetNotificationForAppointment(w http.ResponseWriter
 		return
 	}
 
+	if _, ok := h.authorizeAppointment(w, r, appointmentID); !ok {
+		return
+	}
+
 	var notificationSetting string
 	if err := json.NewDecoder(r.Body).Decode(&notificationSetting); err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
@@ -274,11 +433,21 @@ func (h *AppointmentHandler) SetNotificationForAppointment(w http.ResponseWriter
 // @Description Retrieve all upcoming appointments for the current day and beyond
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/upcoming [get]
 func (h *AppointmentHandler) ListUpcomingAppointments(w http.ResponseWriter, r *http.Request) {
-	appointments, err := h.service.ListUpcoming()
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListUpcoming(page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -291,11 +460,21 @@ func (h *AppointmentHandler) ListUpcomingAppointments(w http.ResponseWriter, r *
 // @Description Retrieve all past appointments
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/past [get]
 func (h *AppointmentHandler) ListPastAppointments(w http.ResponseWriter, r *http.Request) {
-	appointments, err := h.service.ListPast()
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListPast(page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -310,7 +489,10 @@ func (h *AppointmentHandler) ListPastAppointments(w http.ResponseWriter, r *http
 // @Produce  json
 // @Param startDate query string true "Start Date (RFC3339 format)"
 // @Param endDate query string true "End Date (RFC3339 format)"
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/range [get]
@@ -330,7 +512,13 @@ func (h *AppointmentHandler) ListAppointmentsByDateRange(w http.ResponseWriter,
 		return
 	}
 
-	appointments, err := h.service.ListByDateRange(startDate, endDate)
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByDateRange(startDate, endDate, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -340,12 +528,16 @@ func (h *AppointmentHandler) ListAppointmentsByDateRange(w http.ResponseWriter,
 }
 
 // @Summary Cancel an appointment
-// @Description Cancel an appointment by ID and update its status to "Cancelled"
+// @Description Cancel an appointment by ID and release the slot it was booked into. It becomes "cancelled_by_customer" when the customer cancels and "cancelled_by_salon" otherwise. The response carries the cancellation_fee and refund_amount: customers cancelling within the salon's free cancellation notice pay its late fee.
 // @Accept  json
 // @Produce  json
 // @Param appointmentID path int true "Appointment ID"
-// @Success 200
+// @Param reason body statusReason false "Optional reason"
+// @Success 200 {object} models.Appointment
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 409 {object} map[string]string "Appointment Cannot Be Cancelled"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID}/cancel [put]
 func (h *AppointmentHandler) CancelAppointment(w http.ResponseWriter, r *http.Request) {
@@ -356,22 +548,36 @@ func (h *AppointmentHandler) CancelAppointment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	err = h.service.Cancel(appointmentID)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	appointment, ok := h.authorizeAppointment(w, r, appointmentID)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// The body is optional.
+	var body statusReason
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	status := models.AppointmentCancelledBySalon
+	if userID, _ := middleware.UserIDFromContext(r.Context()); userID == appointment.UserID {
+		status = models.AppointmentCancelledByCustomer
+	}
+
+	h.changeStatus(w, r, appointmentID, status, body.Reason)
 }
 
 // @Summary Confirm an appointment
-// @Description Confirm an appointment by ID and update its status to "Confirmed"
+// @Description Confirm a requested appointment by ID
 // @Accept  json
 // @Produce  json
 // @Param appointmentID path int true "Appointment ID"
-// @Success 200
+// @Success 200 {object} models.Appointment
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 409 {object} map[string]string "Appointment Cannot Be Confirmed"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID}/confirm [put]
 func (h *AppointmentHandler) ConfirmAppointment(w http.ResponseWriter, r *http.Request) {
@@ -382,23 +588,32 @@ func (h *AppointmentHandler) ConfirmAppointment(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err = h.service.Confirm(appointmentID)
+	appointment, err := h.service.GetByID(appointmentID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeAppointmentError(w, err)
+		return
+	}
+
+	// Only the salon's owners and staff may confirm its appointments.
+	if err := h.policy.AuthorizeSalon(r.Context(), appointment.SalonID); err != nil {
+		authz.WriteError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	h.changeStatus(w, r, appointmentID, models.AppointmentConfirmed, "")
 }
 
 // @Summary Reschedule an appointment
-// @Description Reschedule an appointment by ID and update its date and time
+// @Description Move a requested or confirmed appointment to a new date and time, or into an availability slot with availability_id. The new time is checked against the salon's hours and the staff member, and must fit the booked service. The slot the appointment held is released and the new one claimed in one transaction; an appointment booked into a slot can only move into another open slot. Customers are held to the salon's policy: no reschedules within the free cancellation notice, and at most the salon's number of reschedules.
 // @Accept  json
 // @Produce  json
 // @Param appointmentID path int true "Appointment ID"
-// @Param newDateTime body string true "New Date and Time (RFC3339 format)"
+// @Param reschedule body models.RescheduleRequest true "New date and time (RFC3339 format) or slot"
 // @Success 200 {object} models.Appointment
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 409 {object} map[string]string "Appointment Closed, Slot Taken Or Not Reschedulable Under The Salon's Policy"
 // @Failure 500 {object} map[string]string
 // @Router /appointment/{appointmentID}/reschedule [put]
 func (h *AppointmentHandler) RescheduleAppointment(w http.ResponseWriter, r *http.Request) {
@@ -409,37 +624,88 @@ func (h *AppointmentHandler) RescheduleAppointment(w http.ResponseWriter, r *htt
 		return
 	}
 
-	var newDateTime struct {
-		NewDateTime string `json:"newDateTime"`
+	if _, ok := h.authorizeAppointment(w, r, appointmentID); !ok {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&newDateTime); err != nil {
+	var request models.RescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	updatedAppointment, err := h.service.Reschedule(appointmentID, newDateTime.NewDateTime)
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	updatedAppointment, err := h.service.Reschedule(appointmentID, request, userID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrServiceNotFound, ErrInvalidStaff, ErrSlotNotFound, ErrSlotMismatch, ErrSlotTooShort,
+			schedule.ErrInvalidTime, schedule.ErrOutsideHours:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrAppointmentClosed, ErrRescheduleTooLate, ErrRescheduleLimit, ErrSlotTaken, ErrNoOpenSlot:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			writeAppointmentError(w, err)
+		}
 		return
 	}
 
 	json.NewEncoder(w).Encode(updatedAppointment)
 }
 
+// @Summary Get the reschedules of an appointment
+// @Description Retrieve the moves of an appointment with the slots it let go of and claimed, oldest first
+// @Accept  json
+// @Produce  json
+// @Param appointmentID path int true "Appointment ID"
+// @Success 200 {array} models.AppointmentReschedule
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /appointment/{appointmentID}/reschedules [get]
+func (h *AppointmentHandler) GetAppointmentReschedules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appointmentID, err := strconv.Atoi(vars["appointmentID"])
+	if err != nil {
+		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.authorizeAppointment(w, r, appointmentID); !ok {
+		return
+	}
+
+	reschedules, err := h.service.ListReschedules(appointmentID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(reschedules)
+}
+
 // @Summary List appointments by notification setting
 // @Description Retrieve all appointments with a specific notification setting
 // @Accept  json
 // @Produce  json
 // @Param setting query string true "Notification Setting"
-// @Success 200 {array} models.Appointment
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Appointment]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /appointments/notification [get]
 func (h *AppointmentHandler) ListAppointmentsByNotificationSetting(w http.ResponseWriter, r *http.Request) {
 	setting := r.URL.Query().Get("setting")
 
-	appointments, err := h.service.ListByNotificationSetting(setting)
+	page, err := pagination.Parse(r, appointmentList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointments, err := h.service.ListByNotificationSetting(setting, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -447,3 +713,30 @@ func (h *AppointmentHandler) ListAppointmentsByNotificationSetting(w http.Respon
 
 	json.NewEncoder(w).Encode(appointments)
 }
+
+// authorizeAppointment loads an appointment and checks that the caller may act on it.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *AppointmentHandler) authorizeAppointment(w http.ResponseWriter, r *http.Request, appointmentID int) (*models.Appointment, bool) {
+	appointment, err := h.service.GetByID(appointmentID)
+	if err != nil {
+		writeAppointmentError(w, err)
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeAppointment(r.Context(), appointment); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return appointment, true
+}
+
+// writeAppointmentError writes the HTTP response for an error returned while loading an appointment.
+func writeAppointmentError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrAppointmentNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
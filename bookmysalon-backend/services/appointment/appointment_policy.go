@@ -0,0 +1,142 @@
+package appointment
+
+import (
+	"bookmysalon/models"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+var (
+	ErrRescheduleLimit   = errors.New("the salon allows no more reschedules of this appointment")
+	ErrRescheduleTooLate = errors.New("the appointment is too close to its start to be rescheduled")
+)
+
+// unconfirmedReason is recorded on appointments the salon did not confirm in time.
+const unconfirmedReason = "not confirmed by the salon in time"
+
+// lockedAppointment is an appointment locked for a change, along with what
+// the policy of its salon says about it.
+type lockedAppointment struct {
+	status          models.AppointmentStatus
+	userID          int
+	availabilityID  sql.NullInt64
+	price           float64
+	rescheduleCount int
+	// late is set within the free cancellation notice before the start.
+	late           bool
+	feePercent     float64
+	maxReschedules sql.NullInt64
+}
+
+// lockAppointment locks an appointment for the rest of tx and evaluates the
+// policy of its salon. The start is a wall time in the salon's timezone, so
+// it is compared with the time there.
+func lockAppointment(tx *sql.Tx, appointmentID int) (*lockedAppointment, error) {
+	const query = `
+		SELECT a.status, a.user_id, a.availability_id, COALESCE(a.total_price, 0), a.reschedule_count,
+			COALESCE(p.free_cancellation_notice > INTERVAL '0'
+				AND a.date_time - (NOW() AT TIME ZONE s.timezone) < p.free_cancellation_notice, FALSE),
+			COALESCE(p.late_cancellation_fee_percent, 0), p.max_reschedules
+		FROM appointments a
+		JOIN salons s ON s.salon_id = a.salon_id
+		LEFT JOIN salon_policies p ON p.salon_id = a.salon_id
+		WHERE a.appointment_id=$1
+		FOR UPDATE OF a
+	`
+
+	var locked lockedAppointment
+	err := tx.QueryRow(query, appointmentID).Scan(&locked.status, &locked.userID, &locked.availabilityID, &locked.price, &locked.rescheduleCount,
+		&locked.late, &locked.feePercent, &locked.maxReschedules)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAppointmentNotFound
+		}
+		log.Printf("Error locking appointment: %v", err)
+		return nil, err
+	}
+	return &locked, nil
+}
+
+// cancellationFee works out what cancelling the appointment with the given
+// status costs. Only late cancellations by the customer pay a fee.
+func (l *lockedAppointment) cancellationFee(to models.AppointmentStatus) float64 {
+	if to != models.AppointmentCancelledByCustomer || !l.late {
+		return 0
+	}
+	return math.Round(l.price*l.feePercent) / 100
+}
+
+// checkReschedule applies the salon's rescheduling rules to a reschedule by
+// the customer.
+func (l *lockedAppointment) checkReschedule() error {
+	if l.late {
+		return ErrRescheduleTooLate
+	}
+	if l.maxReschedules.Valid && int64(l.rescheduleCount) >= l.maxReschedules.Int64 {
+		return ErrRescheduleLimit
+	}
+	return nil
+}
+
+// CancelUnconfirmed cancels the requested appointments their salon did not
+// confirm within its confirmation window, and returns how many it cancelled.
+func (a *appointmentServiceImpl) CancelUnconfirmed() (int, error) {
+	const query = `
+		SELECT a.appointment_id
+		FROM appointments a JOIN salon_policies p ON p.salon_id = a.salon_id
+		WHERE a.status='requested' AND p.confirmation_window > INTERVAL '0'
+			AND a.created_at + p.confirmation_window < NOW()
+	`
+
+	rows, err := a.db.Query(query)
+	if err != nil {
+		log.Printf("Error listing unconfirmed appointments: %v", err)
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		_, err := a.changeStatus(id, models.AppointmentCancelledBySalon, unconfirmedReason, nil)
+		switch err {
+		case nil:
+			cancelled++
+		case ErrIllegalTransition, ErrAppointmentNotFound:
+			// Confirmed, cancelled or deleted in the meantime.
+		default:
+			return cancelled, err
+		}
+	}
+	return cancelled, nil
+}
+
+// StartUnconfirmedCanceller runs CancelUnconfirmed in the background at the given interval.
+func StartUnconfirmedCanceller(service AppointmentService, interval time.Duration) {
+	go func() {
+		for {
+			n, err := service.CancelUnconfirmed()
+			if err != nil {
+				log.Printf("Error cancelling unconfirmed appointments: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Cancelled %d unconfirmed appointments", n)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package appointment
+
+import (
+	"bookmysalon/models"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrNoOpenSlot = errors.New("there is no open slot for this service at the new time that is long enough for the appointment")
+
+// Reschedule moves a requested or confirmed appointment to a new time or
+// into an availability slot, keeping the duration it was booked for. The
+// move is checked against the salon's hours, the staff member and the
+// salon's policy when the customer makes it. In one transaction the slot
+// the appointment held is released, the new one claimed and the move
+// recorded. An appointment booked into a slot can only move into another
+// open slot.
+func (a *appointmentServiceImpl) Reschedule(appointmentID int, request models.RescheduleRequest, actorID int) (*models.Appointment, error) {
+	existing, err := a.GetByID(appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOpen(existing.Status) {
+		return nil, ErrAppointmentClosed
+	}
+
+	moved := *existing
+	moved.DateTime = request.NewDateTime
+	moved.AvailabilityID = request.AvailabilityID
+	var slotLength time.Duration
+	if moved.AvailabilityID != nil {
+		// The appointment takes the staff member of the slot.
+		moved.StaffID = nil
+		length, err := a.fillFromSlot(&moved)
+		if err != nil {
+			return nil, err
+		}
+		slotLength = length
+	}
+
+	if err := a.checkStaff(&moved); err != nil {
+		return nil, err
+	}
+	span, err := a.bookedSpan(existing)
+	if err != nil {
+		return nil, err
+	}
+	if moved.AvailabilityID != nil && span.length > slotLength {
+		return nil, ErrSlotTooShort
+	}
+	if err := a.checkHours(moved.SalonID, &moved.DateTime, span); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		log.Printf("Error rescheduling appointment: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	locked, err := lockAppointment(tx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOpen(locked.status) {
+		return nil, ErrAppointmentClosed
+	}
+	if actorID == locked.userID {
+		if err := locked.checkReschedule(); err != nil {
+			return nil, err
+		}
+	}
+
+	const historyQuery = `
+		INSERT INTO appointment_reschedules(appointment_id, from_date_time, to_date_time, from_availability_id, to_availability_id, actor_id)
+		SELECT appointment_id, date_time, $2::timestamp, availability_id, $3::int, $4::int FROM appointments WHERE appointment_id=$1
+	`
+	const updateQuery = `
+		UPDATE appointments SET date_time=$1, staff_id=$2, availability_id=$3, reschedule_count=reschedule_count+1
+		WHERE appointment_id=$4
+	`
+
+	// The old slot is released first, so moving within the same slot works.
+	heldSlot := locked.availabilityID.Valid
+	if err := releaseSlot(tx, appointmentID); err != nil {
+		return nil, err
+	}
+	if moved.AvailabilityID != nil {
+		if err := claimSlot(tx, &moved); err != nil {
+			return nil, err
+		}
+	} else {
+		slotID, err := reclaimSlot(tx, &moved, moved.DateTime, span.length)
+		if err != nil {
+			return nil, err
+		}
+		if slotID == nil && heldSlot {
+			return nil, ErrNoOpenSlot
+		}
+		moved.AvailabilityID = slotID
+	}
+
+	if _, err := tx.Exec(historyQuery, appointmentID, moved.DateTime, moved.AvailabilityID, actorID); err != nil {
+		log.Printf("Error recording appointment reschedule: %v", err)
+		return nil, err
+	}
+	if _, err := tx.Exec(updateQuery, moved.DateTime, moved.StaffID, moved.AvailabilityID, appointmentID); err != nil {
+		if isSlotConflict(err) {
+			return nil, ErrSlotTaken
+		}
+		log.Printf("Error rescheduling appointment: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error rescheduling appointment: %v", err)
+		return nil, err
+	}
+
+	return a.GetByID(appointmentID)
+}
+
+// ListReschedules retrieves the moves of an appointment, oldest first.
+func (a *appointmentServiceImpl) ListReschedules(appointmentID int) ([]models.AppointmentReschedule, error) {
+	const query = `
+		SELECT reschedule_id, appointment_id,
+			TO_CHAR(from_date_time, 'YYYY-MM-DD"T"HH24:MI:SS'), TO_CHAR(to_date_time, 'YYYY-MM-DD"T"HH24:MI:SS'),
+			from_availability_id, to_availability_id, actor_id,
+			TO_CHAR(rescheduled_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+		FROM appointment_reschedules WHERE appointment_id=$1 ORDER BY rescheduled_at, reschedule_id
+	`
+
+	rows, err := a.db.Query(query, appointmentID)
+	if err != nil {
+		log.Printf("Error listing appointment reschedules: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reschedules := []models.AppointmentReschedule{}
+	for rows.Next() {
+		var reschedule models.AppointmentReschedule
+		var fromSlot, toSlot, actorID sql.NullInt64
+		if err := rows.Scan(&reschedule.RescheduleID, &reschedule.AppointmentID, &reschedule.FromDateTime, &reschedule.ToDateTime,
+			&fromSlot, &toSlot, &actorID, &reschedule.RescheduledAt); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			return nil, err
+		}
+		reschedule.FromAvailabilityID = nullableID(fromSlot)
+		reschedule.ToAvailabilityID = nullableID(toSlot)
+		reschedule.ActorID = nullableID(actorID)
+		reschedules = append(reschedules, reschedule)
+	}
+
+	return reschedules, rows.Err()
+}
+
+// nullableID converts a nullable ID column to a pointer.
+func nullableID(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	n := int(id.Int64)
+	return &n
+}
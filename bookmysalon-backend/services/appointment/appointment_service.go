@@ -2,6 +2,7 @@ package appointment
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
 	"time"
 )
 
@@ -14,44 +15,61 @@ type AppointmentService interface {
 	GetByID(appointmentID int) (*models.Appointment, error)
 
 	// Update updates an existing appointment based on the given appointment.
+	// Only requested and confirmed appointments can be updated, and only their
+	// notification settings and add-ons; they move by rescheduling.
 	Update(appointment *models.Appointment) (*models.Appointment, error)
 
 	// Delete deletes an appointment based on the given appointment ID.
 	Delete(appointmentID int) error
 
-	// ListByUserID retrieves all appointments of a specific user.
-	ListByUserID(userID int) ([]*models.Appointment, error)
+	// ListByUserID retrieves a page of the appointments of a specific user.
+	ListByUserID(userID int, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// ListBySalonID retrieves all appointments of a specific salon.
-	ListBySalonID(salonID int) ([]*models.Appointment, error)
+	// ListBySalonID retrieves a page of the appointments of a specific salon.
+	ListBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// ListByServiceID retrieves all appointments for a specific service.
-	ListByServiceID(serviceID int) ([]*models.Appointment, error)
+	// ListByServiceID retrieves a page of the appointments for a specific service.
+	ListByServiceID(serviceID int, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// ListByStatus retrieves all appointments with a specific status.
-	ListByStatus(status string) ([]*models.Appointment, error)
+	// ListByStatus retrieves a page of the appointments with a specific status.
+	ListByStatus(status models.AppointmentStatus, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
 	// SetNotification updates the notification settings of an appointment.
 	SetNotification(appointmentID int, notificationSetting string) (*models.Appointment, error)
 
-	// ListUpcoming retrieves all upcoming appointments for the current day and beyond.
-	ListUpcoming() ([]*models.Appointment, error)
+	// ListUpcoming retrieves a page of the upcoming appointments for the current day and beyond.
+	ListUpcoming(page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// ListPast retrieves all past appointments.
-	ListPast() ([]*models.Appointment, error)
+	// ListPast retrieves a page of the past appointments.
+	ListPast(page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// ListByDateRange retrieves all appointments between the specified start and end dates.
-	ListByDateRange(startDate, endDate time.Time) ([]*models.Appointment, error)
+	// ListByDateRange retrieves a page of the appointments between the specified start and end dates.
+	ListByDateRange(startDate, endDate time.Time, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 
-	// Cancel cancels an appointment and updates its status to "Cancelled".
-	Cancel(appointmentID int) error
+	// ChangeStatus moves an appointment to a new status, recording who did it
+	// and why. Moves the lifecycle does not allow fail with ErrIllegalTransition.
+	// Cancellations record the fee and refund under the salon's policy.
+	ChangeStatus(appointmentID int, to models.AppointmentStatus, reason string, actorID int) (*models.Appointment, error)
 
-	// Confirm confirms an appointment and updates its status to "Confirmed".
-	Confirm(appointmentID int) error
+	// ListStatusHistory retrieves the status changes of an appointment, oldest first.
+	ListStatusHistory(appointmentID int) ([]models.AppointmentStatusChange, error)
 
-	// Reschedule changes the date and time of an existing appointment.
-	Reschedule(appointmentID int, newDateTime string) (*models.Appointment, error)
+	// Reschedule moves a requested or confirmed appointment to a new time or
+	// into an availability slot, swapping the slot it held for the new one.
+	// actorID is the user making the change; the salon's rescheduling rules
+	// apply when it is the customer.
+	Reschedule(appointmentID int, request models.RescheduleRequest, actorID int) (*models.Appointment, error)
 
-	// ListByNotificationSetting retrieves all appointments with a specific notification setting (e.g., "Email" or "SMS").
-	ListByNotificationSetting(setting string) ([]*models.Appointment, error)
+	// ListReschedules retrieves the moves of an appointment, oldest first.
+	ListReschedules(appointmentID int) ([]models.AppointmentReschedule, error)
+
+	// CancelUnconfirmed cancels the requested appointments their salon did not
+	// confirm within its confirmation window, and returns how many it cancelled.
+	CancelUnconfirmed() (int, error)
+
+	// ListByStaffID retrieves a page of the appointments booked with a specific staff member.
+	ListByStaffID(staffID int, page pagination.Query) (*pagination.Page[*models.Appointment], error)
+
+	// ListByNotificationSetting retrieves a page of the appointments with a specific notification setting (e.g., "Email" or "SMS").
+	ListByNotificationSetting(setting string, page pagination.Query) (*pagination.Page[*models.Appointment], error)
 }
@@ -3,13 +3,30 @@ package appointment
 import (
 	"bookmysalon/models"
 	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
 	"database/sql"
 	"errors"
 	"log"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-var ErrAppointmentNotFound = errors.New("appointment not found")
+var (
+	ErrAppointmentNotFound = errors.New("appointment not found")
+	ErrInvalidStaff        = errors.New("staff member does not perform this service at this salon")
+	ErrServiceNotFound     = errors.New("service not found at this salon")
+	ErrVariantRequired     = errors.New("a variant must be chosen for this service")
+	ErrSalonNotBookable    = errors.New("the salon is not taking bookings")
+	ErrInvalidVariant      = errors.New("variant is not one of the service's variants")
+	ErrInvalidAddOn        = errors.New("add-on is not one of the service's add-ons")
+	ErrSlotNotFound        = errors.New("availability slot not found")
+	ErrSlotMismatch        = errors.New("appointment does not match the salon, service or staff member of the slot")
+	ErrSlotTooShort        = errors.New("slot is shorter than the booked service and add-ons")
+	ErrSlotTaken           = errors.New("slot is already booked")
+	ErrBookingFixed        = errors.New("the service, variant, staff member and time of an appointment cannot be updated; reschedule it through PUT /appointment/{appointmentID}/reschedule")
+)
 
 const (
 	ErrorAppointmentInsert   = "Error inserting appointment"
@@ -18,6 +35,31 @@ const (
 	ErrorAppointmentDelete   = "Error deleting appointment"
 )
 
+// appointmentColumns selects an appointment in the order scanAppointment expects.
+const appointmentColumns = `
+	appointment_id, user_id, salon_id, service_id, staff_id, variant_id, availability_id, date_time, status, notification_settings,
+	COALESCE(total_price, 0), total_duration, reschedule_count, cancellation_fee, refund_amount,
+	date_time + COALESCE(total_duration, (SELECT s.duration FROM services s WHERE s.service_id = appointments.service_id), INTERVAL '0'),
+	ARRAY(SELECT aa.addon_id FROM appointment_addons aa WHERE aa.appointment_id = appointments.appointment_id ORDER BY aa.addon_id)
+`
+
+// appointmentList describes how lists of appointments can be sorted and filtered.
+var appointmentList = pagination.Spec{
+	ID: pagination.Field{Column: "appointment_id", JSON: "appointment_id"},
+	Sorts: map[string]pagination.Field{
+		"id":        {Column: "appointment_id", JSON: "appointment_id"},
+		"date_time": {Column: "date_time", JSON: "date_time"},
+	},
+	DefaultSort: "date_time",
+	Filters: map[string]pagination.Filter{
+		"status":   {Column: "status", Type: pagination.String},
+		"salon_id": {Column: "salon_id", Type: pagination.Int},
+		"staff_id": {Column: "staff_id", Type: pagination.Int},
+		"from":     {Column: "date_time", Op: ">=", Type: pagination.Time},
+		"to":       {Column: "date_time", Op: "<", Type: pagination.Time},
+	},
+}
+
 type appointmentServiceImpl struct {
 	db *sql.DB
 }
@@ -33,31 +75,80 @@ func NewAppointmentService() (AppointmentService, error) {
 	}, nil
 }
 
-// Create inserts a new appointment into the database.
+// Create inserts a new appointment into the database as requested, pricing
+// it from its service or variant and its add-ons. An appointment booked into an
+// availability slot claims the slot in the same transaction; of two
+// concurrent bookings of a slot one fails with ErrSlotTaken.
 func (a *appointmentServiceImpl) Create(appointment *models.Appointment) (*models.Appointment, error) {
 	const query = `
-		INSERT INTO appointments(user_id, salon_id, service_id, date_time, status, notification_settings) 
-		VALUES($1, $2, $3, $4, $5, $6) RETURNING appointment_id
+		INSERT INTO appointments(user_id, salon_id, service_id, staff_id, variant_id, availability_id, date_time, status, notification_settings, total_price, total_duration) 
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING appointment_id
 	`
 
-	err := a.db.QueryRow(query, appointment.UserID, appointment.SalonID, appointment.ServiceID, appointment.DateTime, appointment.Status, appointment.NotificationSettings).Scan(&appointment.AppointmentID)
+	var slotLength time.Duration
+	if appointment.AvailabilityID != nil {
+		length, err := a.fillFromSlot(appointment)
+		if err != nil {
+			return nil, err
+		}
+		slotLength = length
+	}
+	appointment.Status = models.AppointmentRequested
+
+	if err := a.checkStaff(appointment); err != nil {
+		return nil, err
+	}
+	span, err := a.quote(appointment)
+	if err != nil {
+		return nil, err
+	}
+	if appointment.AvailabilityID != nil && span.length > slotLength {
+		return nil, ErrSlotTooShort
+	}
+	if err := a.checkHours(appointment.SalonID, &appointment.DateTime, span); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
 	if err != nil {
 		log.Printf("%s: %v", ErrorAppointmentInsert, err)
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return appointment, nil
+	if appointment.AvailabilityID != nil {
+		if err := claimSlot(tx, appointment); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRow(query, appointment.UserID, appointment.SalonID, appointment.ServiceID, appointment.StaffID, appointment.VariantID, appointment.AvailabilityID,
+		appointment.DateTime, appointment.Status, appointment.NotificationSettings, appointment.TotalPrice, appointment.TotalDuration).Scan(&appointment.AppointmentID)
+	if err != nil {
+		if isSlotConflict(err) {
+			return nil, ErrSlotTaken
+		}
+		log.Printf("%s: %v", ErrorAppointmentInsert, err)
+		return nil, err
+	}
+
+	if err := setAddOns(tx, appointment.AppointmentID, appointment.AddOnIDs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorAppointmentInsert, err)
+		return nil, err
+	}
+
+	return a.GetByID(appointment.AppointmentID)
 }
 
 // GetByID retrieves an appointment by its ID.
 func (a *appointmentServiceImpl) GetByID(appointmentID int) (*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE appointment_id=$1
-	`
+	const query = `SELECT ` + appointmentColumns + ` FROM appointments WHERE appointment_id=$1`
 
-	appointment := &models.Appointment{}
-	err := a.db.QueryRow(query, appointmentID).Scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings)
+	appointment, err := scanAppointment(a.db.QueryRow(query, appointmentID).Scan)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrAppointmentNotFound
@@ -69,129 +160,217 @@ func (a *appointmentServiceImpl) GetByID(appointmentID int) (*models.Appointment
 	return appointment, nil
 }
 
-// Update modifies the details of an existing appointment.
+// Update changes the notification settings and add-ons of a requested or
+// confirmed appointment and prices it again. Its time, service, variant and
+// staff member are fixed once booked; values left out are kept, and different
+// ones fail with ErrBookingFixed, as the appointment has to be rescheduled to
+// move. The status is left alone; it changes through ChangeStatus.
 func (a *appointmentServiceImpl) Update(appointment *models.Appointment) (*models.Appointment, error) {
 	if appointment.AppointmentID == 0 {
 		return nil, errors.New(ErrorAppointmentIDNotSet)
 	}
 
 	const query = `
-		UPDATE appointments SET user_id=$1, salon_id=$2, service_id=$3, date_time=$4, status=$5, notification_settings=$6
-		WHERE appointment_id=$7
+		UPDATE appointments SET notification_settings=$1, total_price=$2, total_duration=$3
+		WHERE appointment_id=$4
 	`
 
-	_, err := a.db.Exec(query, appointment.UserID, appointment.SalonID, appointment.ServiceID, appointment.DateTime, appointment.Status, appointment.NotificationSettings, appointment.AppointmentID)
+	existing, err := a.GetByID(appointment.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOpen(existing.Status) {
+		return nil, ErrAppointmentClosed
+	}
+	if err := a.keepBooking(appointment, existing); err != nil {
+		return nil, err
+	}
+
+	span, err := a.quote(appointment)
+	if err != nil {
+		return nil, err
+	}
+	// Add-ons can make the appointment longer than its slot.
+	if existing.AvailabilityID != nil {
+		slot := *existing
+		slotLength, err := a.fillFromSlot(&slot)
+		if err != nil {
+			return nil, err
+		}
+		if span.length > slotLength {
+			return nil, ErrSlotTooShort
+		}
+	}
+	if err := a.checkHours(appointment.SalonID, &appointment.DateTime, span); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.Begin()
 	if err != nil {
 		log.Printf("%s: %v", ErrorAppointmentUpdate, err)
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return appointment, nil
+	_, err = tx.Exec(query, appointment.NotificationSettings, appointment.TotalPrice, appointment.TotalDuration, appointment.AppointmentID)
+	if err != nil {
+		log.Printf("%s: %v", ErrorAppointmentUpdate, err)
+		return nil, err
+	}
+
+	if err := setAddOns(tx, appointment.AppointmentID, appointment.AddOnIDs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorAppointmentUpdate, err)
+		return nil, err
+	}
+
+	return a.GetByID(appointment.AppointmentID)
 }
 
-// Delete removes an appointment based on the given appointment ID.
+// keepBooking fills the customer, salon, service, variant, staff member and
+// time of an update from the existing appointment, and fails with
+// ErrBookingFixed when the update asks for different ones.
+func (a *appointmentServiceImpl) keepBooking(appointment, existing *models.Appointment) error {
+	if (appointment.UserID != 0 && appointment.UserID != existing.UserID) ||
+		(appointment.SalonID != 0 && appointment.SalonID != existing.SalonID) ||
+		(appointment.ServiceID != 0 && appointment.ServiceID != existing.ServiceID) ||
+		!sameID(appointment.VariantID, existing.VariantID) ||
+		!sameID(appointment.StaffID, existing.StaffID) {
+		return ErrBookingFixed
+	}
+
+	// Appointments are read back with their wall time marked as UTC.
+	booked, err := schedule.ParseTime(existing.DateTime, time.UTC)
+	if err != nil {
+		return err
+	}
+	bookedAt := booked.Format(schedule.WallLayout)
+	if appointment.DateTime != "" {
+		moved, err := schedule.WallTime(a.db, existing.SalonID, appointment.DateTime)
+		if err != nil {
+			return err
+		}
+		if moved != bookedAt {
+			return ErrBookingFixed
+		}
+	}
+
+	appointment.UserID = existing.UserID
+	appointment.SalonID = existing.SalonID
+	appointment.ServiceID = existing.ServiceID
+	appointment.VariantID = existing.VariantID
+	appointment.StaffID = existing.StaffID
+	appointment.AvailabilityID = existing.AvailabilityID
+	appointment.DateTime = bookedAt
+	return nil
+}
+
+// sameID reports whether an optional ID given in an update, if any, matches
+// the current one.
+func sameID(given, current *int) bool {
+	return given == nil || (current != nil && *given == *current)
+}
+
+// Delete removes an appointment based on the given appointment ID and
+// releases the slot it was booked into.
 func (a *appointmentServiceImpl) Delete(appointmentID int) error {
 	const query = `DELETE FROM appointments WHERE appointment_id=$1`
 
-	_, err := a.db.Exec(query, appointmentID)
+	tx, err := a.db.Begin()
 	if err != nil {
 		log.Printf("%s: %v", ErrorAppointmentDelete, err)
 		return err
 	}
+	defer tx.Rollback()
 
+	if err := releaseSlot(tx, appointmentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, appointmentID); err != nil {
+		log.Printf("%s: %v", ErrorAppointmentDelete, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorAppointmentDelete, err)
+		return err
+	}
 	return nil
 }
 
-// ListByUserID retrieves all appointments of a specific user.
-func (a *appointmentServiceImpl) ListByUserID(userID int) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE user_id=$1
-	`
-	return a.listByQuery(query, userID)
+// ListByUserID retrieves a page of the appointments of a specific user.
+func (a *appointmentServiceImpl) ListByUserID(userID int, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "user_id=$1", userID)
 }
 
-// ListBySalonID retrieves all appointments of a specific salon.
-func (a *appointmentServiceImpl) ListBySalonID(salonID int) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE salon_id=$1
-	`
-	return a.listByQuery(query, salonID)
+// ListBySalonID retrieves a page of the appointments of a specific salon.
+func (a *appointmentServiceImpl) ListBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "salon_id=$1", salonID)
 }
 
-// ListByServiceID retrieves all appointments for a specific service.
-func (a *appointmentServiceImpl) ListByServiceID(serviceID int) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE service_id=$1
-	`
-	return a.listByQuery(query, serviceID)
+// ListByServiceID retrieves a page of the appointments for a specific service.
+func (a *appointmentServiceImpl) ListByServiceID(serviceID int, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "service_id=$1", serviceID)
 }
 
-// ListByStatus retrieves all appointments with a specific status.
-func (a *appointmentServiceImpl) ListByStatus(status string) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE status=$1
-	`
-	return a.listByQuery(query, status)
+// ListByStatus retrieves a page of the appointments with a specific status.
+func (a *appointmentServiceImpl) ListByStatus(status models.AppointmentStatus, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	if !status.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+	return a.listByQuery(page, "status=$1", status)
 }
 
 // SetNotification updates the notification settings of an appointment.
 func (a *appointmentServiceImpl) SetNotification(appointmentID int, notificationSetting string) (*models.Appointment, error) {
-	const query = `
-		UPDATE appointments SET notification_settings=$1 WHERE appointment_id=$2 RETURNING appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-	`
+	const query = `UPDATE appointments SET notification_settings=$1 WHERE appointment_id=$2`
 
-	appointment := &models.Appointment{}
-	err := a.db.QueryRow(query, notificationSetting, appointmentID).Scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings)
+	result, err := a.db.Exec(query, notificationSetting, appointmentID)
 	if err != nil {
 		return nil, err
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrAppointmentNotFound
+	}
 
-	return appointment, nil
+	return a.GetByID(appointmentID)
 }
 
-// ListUpcoming retrieves all upcoming appointments for the current day and beyond.
-func (a *appointmentServiceImpl) ListUpcoming() ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE date_time >= $1
-	`
-	return a.listByQuery(query, time.Now())
+// ListUpcoming retrieves a page of the upcoming appointments for the current day and beyond.
+func (a *appointmentServiceImpl) ListUpcoming(page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "date_time >= $1", time.Now())
 }
 
-// ListPast retrieves all past appointments.
-func (a *appointmentServiceImpl) ListPast() ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE date_time < $1
-	`
-	return a.listByQuery(query, time.Now())
+// ListPast retrieves a page of the past appointments.
+func (a *appointmentServiceImpl) ListPast(page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "date_time < $1", time.Now())
 }
 
-// ListByDateRange retrieves all appointments between the specified start and end dates.
-func (a *appointmentServiceImpl) ListByDateRange(startDate, endDate time.Time) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE date_time BETWEEN $1 AND $2
-	`
-	return a.listByQueryWithRange(query, startDate, endDate)
+// ListByDateRange retrieves a page of the appointments between the specified start and end dates.
+func (a *appointmentServiceImpl) ListByDateRange(startDate, endDate time.Time, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "date_time BETWEEN $1 AND $2", startDate, endDate)
 }
 
-// listByQueryWithRange is a helper function that executes a given query with a date range and returns a list of appointments.
-func (a *appointmentServiceImpl) listByQueryWithRange(query string, startDate, endDate time.Time) ([]*models.Appointment, error) {
-	rows, err := a.db.Query(query, startDate, endDate)
+// listByQuery is a helper function that retrieves a page of the appointments
+// matching a condition with the given parameters.
+func (a *appointmentServiceImpl) listByQuery(page pagination.Query, where string, params ...interface{}) (*pagination.Page[*models.Appointment], error) {
+	const selectFrom = `SELECT ` + appointmentColumns + ` FROM appointments`
+
+	query, args := page.SQL(selectFrom, where, params...)
+	rows, err := a.db.Query(query, args...)
 	if err != nil {
+		log.Printf("Error listing appointments: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var appointments []*models.Appointment
 	for rows.Next() {
-		appointment := &models.Appointment{}
-		if err := rows.Scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings); err != nil {
+		appointment, err := scanAppointment(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 		appointments = append(appointments, appointment)
@@ -200,76 +379,313 @@ func (a *appointmentServiceImpl) listByQueryWithRange(query string, startDate, e
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return appointments, nil
+	return pagination.NewPage(appointments, page)
+}
+
+// ListByStaffID retrieves a page of the appointments booked with a specific staff member.
+func (a *appointmentServiceImpl) ListByStaffID(staffID int, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "staff_id=$1", staffID)
+}
+
+// ListByNotificationSetting retrieves a page of the appointments with a specific notification setting (e.g., "Email" or "SMS").
+func (a *appointmentServiceImpl) ListByNotificationSetting(setting string, page pagination.Query) (*pagination.Page[*models.Appointment], error) {
+	return a.listByQuery(page, "notification_settings=$1", setting)
+}
+
+// checkStaff verifies that the staff member of an appointment, if any, is an
+// active member of its salon who performs its service.
+func (a *appointmentServiceImpl) checkStaff(appointment *models.Appointment) error {
+	if appointment.StaffID == nil {
+		return nil
+	}
+
+	const query = `
+		SELECT EXISTS(
+			SELECT 1 FROM staff s JOIN staff_services ss ON ss.staff_id = s.staff_id
+			WHERE s.staff_id=$1 AND s.salon_id=$2 AND ss.service_id=$3 AND s.active)
+	`
+
+	var ok bool
+	if err := a.db.QueryRow(query, *appointment.StaffID, appointment.SalonID, appointment.ServiceID).Scan(&ok); err != nil {
+		log.Printf("Error checking staff of appointment: %v", err)
+		return err
+	}
+	if !ok {
+		return ErrInvalidStaff
+	}
+	return nil
+}
+
+// span is how long an appointment keeps the salon busy: the service, or the
+// chosen variant of it, with its add-ons, and the buffers of the service
+// before and after it.
+type span struct {
+	before, length, after time.Duration
 }
 
-// listByQuery is a helper function that executes a given query and parameter, and returns a list of appointments.
-func (a *appointmentServiceImpl) listByQuery(query string, param interface{}) ([]*models.Appointment, error) {
-	rows, err := a.db.Query(query, param)
+// quote works out the total price and duration of an appointment from its
+// service, or the chosen variant of it, and its add-ons. It checks that they
+// all belong to the service at the salon.
+func (a *appointmentServiceImpl) quote(appointment *models.Appointment) (span, error) {
+	const serviceQuery = `
+		SELECT sv.price, sv.duration, sv.buffer_before, sv.buffer_after,
+			EXISTS(SELECT 1 FROM service_variants WHERE service_id=$1), s.status
+		FROM services sv JOIN salons s ON s.salon_id = sv.salon_id
+		WHERE sv.service_id=$1 AND sv.salon_id=$2
+	`
+	const variantQuery = `
+		SELECT price, duration
+		FROM service_variants WHERE variant_id=$1 AND service_id=$2
+	`
+	const addOnQuery = `
+		SELECT COUNT(*), COALESCE(SUM(price), 0), COALESCE(SUM(duration), INTERVAL '0')
+		FROM service_addons WHERE service_id=$1 AND addon_id = ANY($2)
+	`
+
+	var price float64
+	var duration, before, after models.Duration
+	var hasVariants bool
+	var salonStatus string
+	err := a.db.QueryRow(serviceQuery, appointment.ServiceID, appointment.SalonID).Scan(&price, &duration, &before, &after, &hasVariants, &salonStatus)
 	if err != nil {
-		return nil, err
+		if err == sql.ErrNoRows {
+			return span{}, ErrServiceNotFound
+		}
+		log.Printf("Error retrieving service price: %v", err)
+		return span{}, err
+	}
+	if salonStatus != models.SalonStatusApproved {
+		return span{}, ErrSalonNotBookable
 	}
-	defer rows.Close()
 
-	var appointments []*models.Appointment
-	for rows.Next() {
-		appointment := &models.Appointment{}
-		if err := rows.Scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings); err != nil {
-			return nil, err
+	if appointment.VariantID != nil {
+		if err := a.db.QueryRow(variantQuery, *appointment.VariantID, appointment.ServiceID).Scan(&price, &duration); err != nil {
+			if err == sql.ErrNoRows {
+				return span{}, ErrInvalidVariant
+			}
+			log.Printf("Error retrieving variant price: %v", err)
+			return span{}, err
 		}
-		appointments = append(appointments, appointment)
+	} else if hasVariants {
+		return span{}, ErrVariantRequired
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	appointment.AddOnIDs = uniqueIDs(appointment.AddOnIDs)
+	if len(appointment.AddOnIDs) > 0 {
+		ids := pq.Int64Array{}
+		for _, id := range appointment.AddOnIDs {
+			ids = append(ids, int64(id))
+		}
+
+		var count int
+		var addOnPrice float64
+		var addOnDuration models.Duration
+		if err := a.db.QueryRow(addOnQuery, appointment.ServiceID, ids).Scan(&count, &addOnPrice, &addOnDuration); err != nil {
+			log.Printf("Error retrieving add-on prices: %v", err)
+			return span{}, err
+		}
+		if count != len(ids) {
+			return span{}, ErrInvalidAddOn
+		}
+		price += addOnPrice
+		duration += addOnDuration
 	}
-	return appointments, nil
+
+	appointment.TotalPrice = price
+	appointment.TotalDuration = duration
+	return span{before: before.Std(), length: duration.Std(), after: after.Std()}, nil
 }
 
-// Cancel cancels an appointment and updates its status to "Cancelled".
-func (a *appointmentServiceImpl) Cancel(appointmentID int) error {
-	const query = `UPDATE appointments SET status='Cancelled' WHERE appointment_id=$1`
+// bookedSpan returns the span an appointment was booked for. Appointments
+// booked before totals were kept fall back to the duration of their service.
+func (a *appointmentServiceImpl) bookedSpan(appointment *models.Appointment) (span, error) {
+	const query = `
+		SELECT COALESCE(a.total_duration, s.duration), COALESCE(s.buffer_before, INTERVAL '0'), COALESCE(s.buffer_after, INTERVAL '0')
+		FROM appointments a LEFT JOIN services s ON s.service_id = a.service_id
+		WHERE a.appointment_id=$1
+	`
+
+	var duration, before, after models.Duration
+	if err := a.db.QueryRow(query, appointment.AppointmentID).Scan(&duration, &before, &after); err != nil {
+		if err == sql.ErrNoRows {
+			return span{}, ErrAppointmentNotFound
+		}
+		log.Printf("Error retrieving appointment duration: %v", err)
+		return span{}, err
+	}
+
+	return span{before: before.Std(), length: duration.Std(), after: after.Std()}, nil
+}
 
-	_, err := a.db.Exec(query, appointmentID)
+// checkHours verifies that the salon is open for the whole span of an
+// appointment starting at dateTime, buffers included, and rewrites dateTime
+// as the salon's wall time, which is how it is stored.
+func (a *appointmentServiceImpl) checkHours(salonID int, dateTime *string, span span) error {
+	wallTime, err := schedule.WallTime(a.db, salonID, *dateTime)
 	if err != nil {
 		return err
 	}
+	*dateTime = wallTime
 
-	return nil
+	// Without a duration, at least the start has to be within the opening hours.
+	length := span.length
+	if length <= 0 {
+		length = time.Minute
+	}
+	return schedule.CheckOpenFor(a.db, salonID, wallTime, span.before, length, span.after)
+}
+
+// fillFromSlot takes the salon, service, staff member and start of an
+// appointment from the availability slot it is booked into, and returns the
+// length of the slot. Values the client sent have to match the slot.
+func (a *appointmentServiceImpl) fillFromSlot(appointment *models.Appointment) (time.Duration, error) {
+	const query = `
+		SELECT salon_id, service_id, staff_id, start_date_time, end_date_time
+		FROM availabilities WHERE availability_id=$1
+	`
+
+	var salonID, serviceID int
+	var staffID sql.NullInt64
+	var start, end time.Time
+	err := a.db.QueryRow(query, *appointment.AvailabilityID).Scan(&salonID, &serviceID, &staffID, &start, &end)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrSlotNotFound
+		}
+		log.Printf("Error retrieving availability of appointment: %v", err)
+		return 0, err
+	}
+
+	if (appointment.SalonID != 0 && appointment.SalonID != salonID) ||
+		(appointment.ServiceID != 0 && appointment.ServiceID != serviceID) ||
+		(appointment.StaffID != nil && (!staffID.Valid || int64(*appointment.StaffID) != staffID.Int64)) {
+		return 0, ErrSlotMismatch
+	}
+
+	appointment.SalonID = salonID
+	appointment.ServiceID = serviceID
+	if staffID.Valid {
+		id := int(staffID.Int64)
+		appointment.StaffID = &id
+	}
+	// Slots are stored as wall times of the salon, like appointments.
+	appointment.DateTime = start.Format(schedule.WallLayout)
+	return end.Sub(start), nil
 }
 
-// Confirm confirms an appointment and updates its status to "Confirmed".
-func (a *appointmentServiceImpl) Confirm(appointmentID int) error {
-	const query = `UPDATE appointments SET status='Confirmed' WHERE appointment_id=$1`
+// claimSlot marks the slot of an appointment as booked. The update only
+// applies while the slot is available and still as fillFromSlot read it, so
+// a slot taken or changed in the meantime fails with ErrSlotTaken.
+func claimSlot(tx *sql.Tx, appointment *models.Appointment) error {
+	const query = `
+		UPDATE availabilities SET status='booked'
+		WHERE availability_id=$1 AND status='available'
+			AND salon_id=$2 AND service_id=$3 AND staff_id IS NOT DISTINCT FROM $4 AND start_date_time=$5
+	`
 
-	_, err := a.db.Exec(query, appointmentID)
+	result, err := tx.Exec(query, *appointment.AvailabilityID, appointment.SalonID, appointment.ServiceID, appointment.StaffID, appointment.DateTime)
 	if err != nil {
+		log.Printf("Error claiming availability of appointment: %v", err)
 		return err
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSlotTaken
+	}
+	return nil
+}
+
+// releaseSlot makes the slot an appointment was booked into available again.
+func releaseSlot(tx *sql.Tx, appointmentID int) error {
+	const query = `
+		UPDATE availabilities SET status='available'
+		WHERE availability_id=(SELECT availability_id FROM appointments WHERE appointment_id=$1)
+	`
 
+	if _, err := tx.Exec(query, appointmentID); err != nil {
+		log.Printf("Error releasing availability of appointment: %v", err)
+		return err
+	}
 	return nil
 }
 
-// Reschedule changes the date and time of an existing appointment.
-func (a *appointmentServiceImpl) Reschedule(appointmentID int, newDateTime string) (*models.Appointment, error) {
+// reclaimSlot claims an open slot of the appointment's service and staff
+// member starting at dateTime and at least length long, if there is one, and
+// returns its ID.
+func reclaimSlot(tx *sql.Tx, appointment *models.Appointment, dateTime string, length time.Duration) (*int, error) {
 	const query = `
-		UPDATE appointments SET date_time=$1 WHERE appointment_id=$2 RETURNING appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
+		UPDATE availabilities SET status='booked'
+		WHERE availability_id = (
+			SELECT availability_id FROM availabilities
+			WHERE salon_id=$1 AND service_id=$2 AND staff_id IS NOT DISTINCT FROM $3 AND start_date_time=$4 AND status='available'
+				AND end_date_time - start_date_time >= $5::interval
+			ORDER BY availability_id LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING availability_id
 	`
 
+	var slotID int
+	err := tx.QueryRow(query, appointment.SalonID, appointment.ServiceID, appointment.StaffID, dateTime, models.Duration(length)).Scan(&slotID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error claiming availability of appointment: %v", err)
+		return nil, err
+	}
+	return &slotID, nil
+}
+
+// isSlotConflict reports whether err is a violation of the index that keeps
+// a slot from being held by two appointments.
+func isSlotConflict(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_appointments_availability"
+}
+
+// setAddOns replaces the add-ons booked with an appointment.
+func setAddOns(tx *sql.Tx, appointmentID int, addOnIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM appointment_addons WHERE appointment_id=$1`, appointmentID); err != nil {
+		log.Printf("Error clearing add-ons of appointment: %v", err)
+		return err
+	}
+
+	for _, addOnID := range addOnIDs {
+		if _, err := tx.Exec(`INSERT INTO appointment_addons(appointment_id, addon_id) VALUES($1, $2)`, appointmentID, addOnID); err != nil {
+			log.Printf("Error adding add-on to appointment: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanAppointment reads a row selected with appointmentColumns.
+func scanAppointment(scan func(dest ...interface{}) error) (*models.Appointment, error) {
 	appointment := &models.Appointment{}
-	err := a.db.QueryRow(query, newDateTime, appointmentID).Scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings)
+	var addOnIDs pq.Int64Array
+	err := scan(&appointment.AppointmentID, &appointment.UserID, &appointment.SalonID, &appointment.ServiceID, &appointment.StaffID, &appointment.VariantID,
+		&appointment.AvailabilityID, &appointment.DateTime, &appointment.Status, &appointment.NotificationSettings, &appointment.TotalPrice, &appointment.TotalDuration,
+		&appointment.RescheduleCount, &appointment.CancellationFee, &appointment.RefundAmount, &appointment.EndDateTime, &addOnIDs)
 	if err != nil {
 		return nil, err
 	}
 
+	appointment.AddOnIDs = make([]int, len(addOnIDs))
+	for i, id := range addOnIDs {
+		appointment.AddOnIDs[i] = int(id)
+	}
 	return appointment, nil
 }
 
-// ListByNotificationSetting retrieves all appointments with a specific notification setting (e.g., "Email" or "SMS").
-func (a *appointmentServiceImpl) ListByNotificationSetting(setting string) ([]*models.Appointment, error) {
-	const query = `
-		SELECT appointment_id, user_id, salon_id, service_id, date_time, status, notification_settings
-		FROM appointments WHERE notification_settings=$1
-	`
-	return a.listByQuery(query, setting)
+// uniqueIDs drops repeated IDs, keeping the first occurrence of each.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := []int{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
 }
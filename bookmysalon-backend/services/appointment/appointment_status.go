@@ -0,0 +1,142 @@
+package appointment
+
+import (
+	"bookmysalon/models"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("status must be requested, confirmed, checked_in, in_service, completed, cancelled_by_customer, cancelled_by_salon or no_show")
+	ErrIllegalTransition = errors.New("the appointment cannot move to this status from its current one")
+	ErrAppointmentClosed = errors.New("only requested or confirmed appointments can be changed")
+)
+
+// appointmentTransitions lists the statuses each status can move to.
+// Completed, cancelled and no-show appointments are final.
+var appointmentTransitions = map[models.AppointmentStatus][]models.AppointmentStatus{
+	models.AppointmentRequested: {models.AppointmentConfirmed, models.AppointmentCancelledByCustomer, models.AppointmentCancelledBySalon},
+	models.AppointmentConfirmed: {models.AppointmentCheckedIn, models.AppointmentCancelledByCustomer, models.AppointmentCancelledBySalon, models.AppointmentNoShow},
+	models.AppointmentCheckedIn: {models.AppointmentInService, models.AppointmentCancelledBySalon},
+	models.AppointmentInService: {models.AppointmentCompleted},
+}
+
+// canTransition reports whether an appointment may move from one status to another.
+func canTransition(from, to models.AppointmentStatus) bool {
+	for _, next := range appointmentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen reports whether an appointment can still be changed or rescheduled.
+func isOpen(status models.AppointmentStatus) bool {
+	return status == models.AppointmentRequested || status == models.AppointmentConfirmed
+}
+
+// ChangeStatus moves an appointment to a new status and records who did it.
+// Cancelling an appointment releases the slot it was booked into and works
+// out the fee and refund under the salon's policy.
+func (a *appointmentServiceImpl) ChangeStatus(appointmentID int, to models.AppointmentStatus, reason string, actorID int) (*models.Appointment, error) {
+	return a.changeStatus(appointmentID, to, reason, &actorID)
+}
+
+// changeStatus is ChangeStatus with an optional actor; changes made by the
+// system itself have none.
+func (a *appointmentServiceImpl) changeStatus(appointmentID int, to models.AppointmentStatus, reason string, actorID *int) (*models.Appointment, error) {
+	if !to.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+	reason = strings.TrimSpace(reason)
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		log.Printf("Error changing appointment status: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	locked, err := lockAppointment(tx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	from := locked.status
+	if !canTransition(from, to) {
+		return nil, ErrIllegalTransition
+	}
+
+	var fee, refund *float64
+	if to.IsCancelled() {
+		if err := releaseSlot(tx, appointmentID); err != nil {
+			return nil, err
+		}
+		charged := locked.cancellationFee(to)
+		rest := locked.price - charged
+		fee, refund = &charged, &rest
+	}
+
+	// A cancelled appointment lets go of its slot, so it can be booked again.
+	const updateQuery = `
+		UPDATE appointments SET status=$2,
+			availability_id=CASE WHEN $3 THEN NULL ELSE availability_id END,
+			cancellation_fee=$4, refund_amount=$5
+		WHERE appointment_id=$1
+	`
+	const historyQuery = `
+		INSERT INTO appointment_status_history(appointment_id, from_status, to_status, reason, actor_id)
+		VALUES($1, $2, $3, NULLIF($4, ''), $5)
+	`
+
+	if _, err := tx.Exec(updateQuery, appointmentID, to, to.IsCancelled(), fee, refund); err != nil {
+		log.Printf("Error changing appointment status: %v", err)
+		return nil, err
+	}
+	if _, err := tx.Exec(historyQuery, appointmentID, from, to, reason, actorID); err != nil {
+		log.Printf("Error recording appointment status change: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error changing appointment status: %v", err)
+		return nil, err
+	}
+
+	return a.GetByID(appointmentID)
+}
+
+// ListStatusHistory retrieves the status changes of an appointment, oldest first.
+func (a *appointmentServiceImpl) ListStatusHistory(appointmentID int) ([]models.AppointmentStatusChange, error) {
+	const query = `
+		SELECT history_id, appointment_id, from_status, to_status, COALESCE(reason, ''), actor_id,
+			TO_CHAR(changed_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+		FROM appointment_status_history WHERE appointment_id=$1 ORDER BY changed_at, history_id
+	`
+
+	rows, err := a.db.Query(query, appointmentID)
+	if err != nil {
+		log.Printf("Error listing appointment status history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.AppointmentStatusChange{}
+	for rows.Next() {
+		var change models.AppointmentStatusChange
+		var actorID sql.NullInt64
+		if err := rows.Scan(&change.HistoryID, &change.AppointmentID, &change.FromStatus, &change.ToStatus, &change.Reason, &actorID, &change.ChangedAt); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			return nil, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			change.ActorID = &id
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
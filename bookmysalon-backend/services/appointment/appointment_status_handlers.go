@@ -0,0 +1,134 @@
+package appointment
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/middleware"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// statusReason is the optional body of a cancellation.
+type statusReason struct {
+	Reason string `json:"reason"`
+}
+
+// statusChange is the body of a status change.
+type statusChange struct {
+	Status models.AppointmentStatus `json:"status"`
+	Reason string                   `json:"reason"`
+}
+
+// @Summary Change the status of an appointment
+// @Description Move an appointment along its lifecycle: requested -> confirmed -> checked_in -> in_service -> completed, or to cancelled_by_customer, cancelled_by_salon or no_show. The customer may only cancel; the other moves are for the salon's owners and staff.
+// @Accept  json
+// @Produce  json
+// @Param appointmentID path int true "Appointment ID"
+// @Param status body statusChange true "New status and optional reason"
+// @Success 200 {object} models.Appointment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 409 {object} map[string]string "Illegal Transition"
+// @Failure 500 {object} map[string]string
+// @Router /appointment/{appointmentID}/status [put]
+func (h *AppointmentHandler) ChangeAppointmentStatus(w http.ResponseWriter, r *http.Request) {
+	appointmentID, err := strconv.Atoi(mux.Vars(r)["appointmentID"])
+	if err != nil {
+		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		return
+	}
+
+	var body statusChange
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !body.Status.IsValid() {
+		http.Error(w, ErrInvalidStatus.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appointment, err := h.service.GetByID(appointmentID)
+	if err != nil {
+		writeAppointmentError(w, err)
+		return
+	}
+
+	// Customers can call off their own appointments; everything else is
+	// done by the salon.
+	if body.Status == models.AppointmentCancelledByCustomer {
+		err = h.policy.AuthorizeUser(r.Context(), appointment.UserID)
+	} else {
+		err = h.policy.AuthorizeSalon(r.Context(), appointment.SalonID)
+	}
+	if err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	h.changeStatus(w, r, appointmentID, body.Status, body.Reason)
+}
+
+// @Summary Get the status history of an appointment
+// @Description Retrieve the status changes of an appointment, oldest first
+// @Accept  json
+// @Produce  json
+// @Param appointmentID path int true "Appointment ID"
+// @Success 200 {array} models.AppointmentStatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Appointment Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /appointment/{appointmentID}/status-history [get]
+func (h *AppointmentHandler) GetAppointmentStatusHistory(w http.ResponseWriter, r *http.Request) {
+	appointmentID, err := strconv.Atoi(mux.Vars(r)["appointmentID"])
+	if err != nil {
+		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.authorizeAppointment(w, r, appointmentID); !ok {
+		return
+	}
+
+	changes, err := h.service.ListStatusHistory(appointmentID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(changes)
+}
+
+func (h *AppointmentHandler) changeStatus(w http.ResponseWriter, r *http.Request, appointmentID int, status models.AppointmentStatus, reason string) {
+	actorID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	appointment, err := h.service.ChangeStatus(appointmentID, status, reason, actorID)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(appointment)
+}
+
+func writeStatusError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidStatus:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrAppointmentNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrIllegalTransition:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -2,6 +2,9 @@ package availability
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -13,19 +16,21 @@ import (
 
 type AvailabilityHandler struct {
 	service AvailabilityService
+	policy  authz.Policy
 }
 
-func NewAvailabilityHandler(s AvailabilityService) *AvailabilityHandler {
-	return &AvailabilityHandler{service: s}
+func NewAvailabilityHandler(s AvailabilityService, p authz.Policy) *AvailabilityHandler {
+	return &AvailabilityHandler{service: s, policy: p}
 }
 
 // @Summary Create a new availability
-// @Description Create a new availability with the input payload
+// @Description Create a new availability with the input payload. The slot must lie within the salon's opening hours.
 // @Accept  json
 // @Produce  json
 // @Param availability body models.Availability true "Create Availability"
 // @Success 201 {object} models.Availability
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availability [post]
 func (h *AvailabilityHandler) CreateAvailability(w http.ResponseWriter, r *http.Request) {
@@ -36,10 +41,23 @@ func (h *AvailabilityHandler) CreateAvailability(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if err := h.policy.AuthorizeSalon(r.Context(), availability.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
 	newAvailability, err := h.service.CreateAvailability(&availability)
 	if err != nil {
-		log.Println("Failed to create availability:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrInvalidStaff, ErrServiceNotFound, ErrSlotTooShort, ErrInvalidStatus,
+			schedule.ErrInvalidTime, schedule.ErrInvalidPeriod, schedule.ErrOutsideHours:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case schedule.ErrSalonNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			log.Println("Failed to create availability:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -80,12 +98,15 @@ func (h *AvailabilityHandler) GetAvailabilityDetails(w http.ResponseWriter, r *h
 }
 
 // @Summary Update availability details
-// @Description Update details of an existing availability
+// @Description Update details of an existing availability. Slots an appointment was booked into cannot be changed.
 // @Accept  json
 // @Produce  json
 // @Param availability body models.Availability true "Update Availability"
 // @Success 200 {object} models.Availability
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Availability Not Found"
+// @Failure 409 {object} map[string]string "Slot Held By An Appointment"
 // @Failure 500 {object} map[string]string
 // @Router /availability/update [put]
 func (h *AvailabilityHandler) UpdateAvailabilityDetails(w http.ResponseWriter, r *http.Request) {
@@ -96,10 +117,27 @@ func (h *AvailabilityHandler) UpdateAvailabilityDetails(w http.ResponseWriter, r
 		return
 	}
 
+	existing, ok := h.authorizeAvailability(w, r, availability.AvailabilityID)
+	if !ok {
+		return
+	}
+	// An availability cannot be moved to another salon through an update.
+	availability.SalonID = existing.SalonID
+
 	updatedAvailability, err := h.service.UpdateAvailability(&availability)
 	if err != nil {
-		log.Println("Failed to update availability:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrInvalidStaff, ErrServiceNotFound, ErrSlotTooShort, ErrInvalidStatus,
+			schedule.ErrInvalidTime, schedule.ErrInvalidPeriod, schedule.ErrOutsideHours:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case schedule.ErrSalonNotFound, ErrAvailabilityNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrSlotHeld:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Println("Failed to update availability:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -107,13 +145,15 @@ func (h *AvailabilityHandler) UpdateAvailabilityDetails(w http.ResponseWriter, r
 }
 
 // @Summary Delete an availability
-// @Description Delete an availability by its ID
+// @Description Delete an availability by its ID. Slots an appointment was booked into cannot be deleted.
 // @Accept  json
 // @Produce  json
 // @Param availabilityID path int true "Availability ID"
 // @Success 204 "Successfully deleted"
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string "Availability Not Found"
+// @Failure 409 {object} map[string]string "Slot Held By An Appointment"
 // @Failure 500 {object} map[string]string
 // @Router /availability/{availabilityID} [delete]
 func (h *AvailabilityHandler) DeleteAvailability(w http.ResponseWriter, r *http.Request) {
@@ -124,11 +164,17 @@ func (h *AvailabilityHandler) DeleteAvailability(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if _, ok := h.authorizeAvailability(w, r, availabilityID); !ok {
+		return
+	}
+
 	err = h.service.DeleteAvailability(availabilityID)
 	if err != nil {
 		switch err {
 		case ErrAvailabilityNotFound:
 			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrSlotHeld:
+			http.Error(w, err.Error(), http.StatusConflict)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -143,7 +189,10 @@ func (h *AvailabilityHandler) DeleteAvailability(w http.ResponseWriter, r *http.
 // @Accept  json
 // @Produce  json
 // @Param salonID path int true "Salon ID"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/salon/{salonID} [get]
@@ -155,7 +204,13 @@ func (h *AvailabilityHandler) ListAvailabilitiesBySalonID(w http.ResponseWriter,
 		return
 	}
 
-	availabilities, err := h.service.ListAvailabilitiesBySalonID(salonID)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListAvailabilitiesBySalonID(salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -169,7 +224,10 @@ func (h *AvailabilityHandler) ListAvailabilitiesBySalonID(w http.ResponseWriter,
 // @Accept  json
 // @Produce  json
 // @Param serviceID path int true "Service ID"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/service/{serviceID} [get]
@@ -181,7 +239,13 @@ func (h *AvailabilityHandler) ListAvailabilitiesByServiceID(w http.ResponseWrite
 		return
 	}
 
-	availabilities, err := h.service.ListAvailabilitiesByServiceID(serviceID)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListAvailabilitiesByServiceID(serviceID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -195,7 +259,10 @@ func (h *AvailabilityHandler) ListAvailabilitiesByServiceID(w http.ResponseWrite
 // @Accept  json
 // @Produce  json
 // @Param status path string true "Status"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/status/{status} [get]
@@ -203,7 +270,13 @@ func (h *AvailabilityHandler) ListAvailabilitiesByStatus(w http.ResponseWriter,
 	vars := mux.Vars(r)
 	status := vars["status"]
 
-	availabilities, err := h.service.ListAvailabilitiesByStatus(status)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListAvailabilitiesByStatus(status, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -218,7 +291,10 @@ func (h *AvailabilityHandler) ListAvailabilitiesByStatus(w http.ResponseWriter,
 // @Produce  json
 // @Param serviceID path int true "Service ID"
 // @Param salonID path int true "Salon ID"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/open/{serviceID}/{salonID} [get]
@@ -236,7 +312,13 @@ func (h *AvailabilityHandler) ListOpenAvailabilities(w http.ResponseWriter, r *h
 		return
 	}
 
-	availabilities, err := h.service.ListOpenAvailabilities(serviceID, salonID)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListOpenAvailabilities(serviceID, salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -245,13 +327,16 @@ func (h *AvailabilityHandler) ListOpenAvailabilities(w http.ResponseWriter, r *h
 	json.NewEncoder(w).Encode(availabilities)
 }
 
-// @Summary Book an availability
-// @Description Book an available time slot by its ID and update its status to "Booked"
+// @Summary Block an availability
+// @Description Mark an available time slot as booked without an appointment, e.g. for a walk-in. Customers book a slot by creating an appointment with its availability_id.
 // @Accept  json
 // @Produce  json
 // @Param availabilityID path int true "Availability ID"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Availability Not Found"
+// @Failure 409 {object} map[string]string "Slot Already Booked"
 // @Failure 500 {object} map[string]string
 // @Router /availability/{availabilityID}/book [put]
 func (h *AvailabilityHandler) BookAvailability(w http.ResponseWriter, r *http.Request) {
@@ -262,9 +347,13 @@ func (h *AvailabilityHandler) BookAvailability(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if _, ok := h.authorizeAvailability(w, r, availabilityID); !ok {
+		return
+	}
+
 	err = h.service.BookAvailability(availabilityID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeBookingError(w, err)
 		return
 	}
 
@@ -272,12 +361,15 @@ func (h *AvailabilityHandler) BookAvailability(w http.ResponseWriter, r *http.Re
 }
 
 // @Summary Cancel booking
-// @Description Cancel a booked time slot by its ID and update its status to "Open"
+// @Description Mark a booked time slot as available again. Slots an appointment was booked into are released by cancelling the appointment.
 // @Accept  json
 // @Produce  json
 // @Param availabilityID path int true "Availability ID"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Availability Not Found"
+// @Failure 409 {object} map[string]string "Slot Not Booked Or Held By An Appointment"
 // @Failure 500 {object} map[string]string
 // @Router /availability/{availabilityID}/cancel [put]
 func (h *AvailabilityHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
@@ -288,9 +380,13 @@ func (h *AvailabilityHandler) CancelBooking(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if _, ok := h.authorizeAvailability(w, r, availabilityID); !ok {
+		return
+	}
+
 	err = h.service.CancelBooking(availabilityID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeBookingError(w, err)
 		return
 	}
 
@@ -303,7 +399,10 @@ func (h *AvailabilityHandler) CancelBooking(w http.ResponseWriter, r *http.Reque
 // @Produce  json
 // @Param serviceID path int true "Service ID"
 // @Param salonID path int true "Salon ID"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/booked/{serviceID}/{salonID} [get]
@@ -321,7 +420,13 @@ func (h *AvailabilityHandler) ListBookedAvailabilities(w http.ResponseWriter, r
 		return
 	}
 
-	availabilities, err := h.service.ListBookedAvailabilities(serviceID, salonID)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListBookedAvailabilities(serviceID, salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -336,7 +441,10 @@ func (h *AvailabilityHandler) ListBookedAvailabilities(w http.ResponseWriter, r
 // @Produce  json
 // @Param startDate query string true "Start Date (RFC3339 format)"
 // @Param endDate query string true "End Date (RFC3339 format)"
-// @Success 200 {array} models.Availability
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /availabilities/range [get]
@@ -358,7 +466,13 @@ func (h *AvailabilityHandler) ListAvailabilitiesByDateRange(w http.ResponseWrite
 	}
 
 	// Now that we've validated the date formats, you can pass startDateStr and endDateStr as strings to the service method.
-	availabilities, err := h.service.ListAvailabilitiesByDateRange(startDateStr, endDateStr)
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListAvailabilitiesByDateRange(startDateStr, endDateStr, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -366,3 +480,71 @@ func (h *AvailabilityHandler) ListAvailabilitiesByDateRange(w http.ResponseWrite
 
 	json.NewEncoder(w).Encode(availabilities)
 }
+
+// @Summary List availabilities by staff ID
+// @Description Retrieve all availabilities of a specific staff member, so customers can book a specific stylist
+// @Accept  json
+// @Produce  json
+// @Param staffID path int true "Staff ID"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date_time or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Availability]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /availabilities/staff/{staffID} [get]
+func (h *AvailabilityHandler) ListAvailabilitiesByStaffID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	staffID, err := strconv.Atoi(vars["staffID"])
+	if err != nil {
+		http.Error(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.Parse(r, availabilityList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availabilities, err := h.service.ListAvailabilitiesByStaffID(staffID, page)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(availabilities)
+}
+
+// authorizeAvailability loads an availability and checks that the caller may manage its salon.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *AvailabilityHandler) authorizeAvailability(w http.ResponseWriter, r *http.Request, availabilityID int) (*models.Availability, bool) {
+	availability, err := h.service.GetAvailabilityByID(availabilityID)
+	if err != nil {
+		switch err {
+		case ErrAvailabilityNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), availability.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return availability, true
+}
+
+func writeBookingError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrAvailabilityNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrSlotTaken, ErrSlotNotBooked, ErrSlotHeld:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
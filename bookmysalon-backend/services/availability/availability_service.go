@@ -1,6 +1,9 @@
 package availability
 
-import "bookmysalon/models"
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
+)
 
 // AvailabilityService defines the methods for managing salon service availabilities.
 type AvailabilityService interface {
@@ -16,27 +19,51 @@ type AvailabilityService interface {
 	// DeleteAvailability deletes an availability entry by its unique ID.
 	DeleteAvailability(availabilityID int) error
 
-	// ListAvailabilitiesBySalonID retrieves all availabilities for a specific salon by its ID.
-	ListAvailabilitiesBySalonID(salonID int) ([]*models.Availability, error)
+	// ListAvailabilitiesBySalonID retrieves a page of the availabilities for a specific salon by its ID.
+	ListAvailabilitiesBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error)
 
-	// ListAvailabilitiesByServiceID retrieves all availabilities for a specific service by its ID.
-	ListAvailabilitiesByServiceID(serviceID int) ([]*models.Availability, error)
+	// ListAvailabilitiesByServiceID retrieves a page of the availabilities for a specific service by its ID.
+	ListAvailabilitiesByServiceID(serviceID int, page pagination.Query) (*pagination.Page[*models.Availability], error)
 
-	// ListAvailabilitiesByStatus retrieves all availabilities with a specific status.
-	ListAvailabilitiesByStatus(status string) ([]*models.Availability, error)
+	// ListAvailabilitiesByStatus retrieves a page of the availabilities with a specific status.
+	ListAvailabilitiesByStatus(status string, page pagination.Query) (*pagination.Page[*models.Availability], error)
 
-	// ListOpenAvailabilities retrieves all open (available) time slots for a specific service and salon.
-	ListOpenAvailabilities(serviceID, salonID int) ([]*models.Availability, error)
+	// ListOpenAvailabilities retrieves a page of the open (available) time slots for a specific service and salon.
+	ListOpenAvailabilities(serviceID, salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error)
 
-	// BookAvailability books an available time slot, updating its status to "Booked."
+	// BookAvailability marks an available time slot as booked, failing with
+	// ErrSlotTaken if it is booked already.
 	BookAvailability(availabilityID int) error
 
-	// CancelBooking cancels a booked time slot, updating its status to "Open."
+	// CancelBooking marks a booked time slot as available again, unless an
+	// appointment holds it.
 	CancelBooking(availabilityID int) error
 
-	// ListBookedAvailabilities retrieves all booked time slots for a specific service and salon.
-	ListBookedAvailabilities(serviceID, salonID int) ([]*models.Availability, error)
+	// ListBookedAvailabilities retrieves a page of the booked time slots for a specific service and salon.
+	ListBookedAvailabilities(serviceID, salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error)
 
-	// ListAvailabilitiesByDateRange retrieves all availabilities between the specified start and end dates.
-	ListAvailabilitiesByDateRange(startDate, endDate string) ([]*models.Availability, error)
+	// ListAvailabilitiesByDateRange retrieves a page of the availabilities between the specified start and end dates.
+	ListAvailabilitiesByDateRange(startDate, endDate string, page pagination.Query) (*pagination.Page[*models.Availability], error)
+
+	// ListAvailabilitiesByStaffID retrieves a page of the availabilities of a specific staff member.
+	ListAvailabilitiesByStaffID(staffID int, page pagination.Query) (*pagination.Page[*models.Availability], error)
+
+	// CreateSlotTemplate adds a weekly window in which a salon offers a service.
+	CreateSlotTemplate(template *models.SlotTemplate) (*models.SlotTemplate, error)
+
+	// GetSlotTemplateByID retrieves a slot template by its ID.
+	GetSlotTemplateByID(templateID int) (*models.SlotTemplate, error)
+
+	// ListSlotTemplates retrieves the slot templates of a salon.
+	ListSlotTemplates(salonID int) ([]models.SlotTemplate, error)
+
+	// DeleteSlotTemplate deletes a slot template and its slots that are still available.
+	DeleteSlotTemplate(templateID int) error
+
+	// GenerateSlots fills the slot templates of a salon with slots for the
+	// given number of weeks ahead and returns how many it created.
+	GenerateSlots(salonID, weeks int) (int, error)
+
+	// GenerateAllSlots runs GenerateSlots for every salon with slot templates.
+	GenerateAllSlots(weeks int) (int, error)
 }
@@ -3,12 +3,24 @@ package availability
 import (
 	"bookmysalon/models"
 	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
 	"database/sql"
 	"errors"
 	"log"
+	"time"
 )
 
-var ErrAvailabilityNotFound = errors.New("availability not found")
+var (
+	ErrAvailabilityNotFound = errors.New("availability not found")
+	ErrInvalidStaff         = errors.New("staff member does not perform this service at this salon")
+	ErrServiceNotFound      = errors.New("service not found at this salon")
+	ErrSlotTooShort         = errors.New("slot is shorter than the service")
+	ErrInvalidStatus        = errors.New("status must be available or booked")
+	ErrSlotTaken            = errors.New("slot is already booked")
+	ErrSlotNotBooked        = errors.New("slot is not booked")
+	ErrSlotHeld             = errors.New("slot is held by an appointment; change the appointment instead")
+)
 
 // Constants for error messages.
 const (
@@ -16,6 +28,23 @@ const (
 	ErrorAvailabilityUpdate = "Error updating availability"
 )
 
+// availabilityList describes how lists of availabilities can be sorted and filtered.
+var availabilityList = pagination.Spec{
+	ID: pagination.Field{Column: "availability_id", JSON: "availability_id"},
+	Sorts: map[string]pagination.Field{
+		"id":              {Column: "availability_id", JSON: "availability_id"},
+		"start_date_time": {Column: "start_date_time", JSON: "start_date_time"},
+	},
+	DefaultSort: "start_date_time",
+	Filters: map[string]pagination.Filter{
+		"status":     {Column: "status", Type: pagination.String},
+		"service_id": {Column: "service_id", Type: pagination.Int},
+		"staff_id":   {Column: "staff_id", Type: pagination.Int},
+		"from":       {Column: "start_date_time", Op: ">=", Type: pagination.Time},
+		"to":         {Column: "start_date_time", Op: "<", Type: pagination.Time},
+	},
+}
+
 // availabilityServiceImpl is the implementation of the AvailabilityService interface.
 type availabilityServiceImpl struct {
 	db *sql.DB
@@ -32,15 +61,26 @@ func NewAvailabilityService() (AvailabilityService, error) {
 	}, nil
 }
 
-// CreateAvailability creates a new availability entry.
+// CreateAvailability creates a new availability entry. Without an end, the
+// slot lasts as long as its service.
 func (s *availabilityServiceImpl) CreateAvailability(availability *models.Availability) (*models.Availability, error) {
+	if err := checkStatus(availability); err != nil {
+		return nil, err
+	}
+	if err := s.checkStaff(availability); err != nil {
+		return nil, err
+	}
+	if err := s.fitService(availability); err != nil {
+		return nil, err
+	}
+
 	const query = `
-		INSERT INTO availability(salon_id, service_id, start_date_time, end_date_time, status) 
-		VALUES($1, $2, $3, $4, $5) RETURNING availability_id
+		INSERT INTO availabilities(salon_id, service_id, staff_id, start_date_time, end_date_time, status) 
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING availability_id
 	`
 
 	var availabilityID int
-	err := s.db.QueryRow(query, availability.SalonID, availability.ServiceID, availability.StartDateTime, availability.EndDateTime, availability.Status).Scan(&availabilityID)
+	err := s.db.QueryRow(query, availability.SalonID, availability.ServiceID, availability.StaffID, availability.StartDateTime, availability.EndDateTime, availability.Status).Scan(&availabilityID)
 	if err != nil {
 		log.Printf("%s: %v", ErrorAvailabilityInsert, err)
 		return nil, err
@@ -53,12 +93,12 @@ func (s *availabilityServiceImpl) CreateAvailability(availability *models.Availa
 // GetAvailabilityByID retrieves an availability entry by its unique ID.
 func (s *availabilityServiceImpl) GetAvailabilityByID(availabilityID int) (*models.Availability, error) {
 	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE availability_id=$1
+		SELECT availability_id, salon_id, service_id, staff_id, start_date_time, end_date_time, status
+		FROM availabilities WHERE availability_id=$1
 	`
 
 	var availability models.Availability
-	err := s.db.QueryRow(query, availabilityID).Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status)
+	err := s.db.QueryRow(query, availabilityID).Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StaffID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrAvailabilityNotFound
@@ -76,124 +116,154 @@ func (s *availabilityServiceImpl) UpdateAvailability(availability *models.Availa
 		return nil, errors.New("availability ID must be provided for update")
 	}
 
+	if err := checkStatus(availability); err != nil {
+		return nil, err
+	}
+	if err := s.checkNotHeld(availability.AvailabilityID); err != nil {
+		return nil, err
+	}
+	if err := s.checkStaff(availability); err != nil {
+		return nil, err
+	}
+	if err := s.fitService(availability); err != nil {
+		return nil, err
+	}
+
 	const query = `
-		UPDATE availability SET salon_id=$1, service_id=$2, start_date_time=$3, end_date_time=$4, status=$5 
-		WHERE availability_id=$6
+		UPDATE availabilities SET salon_id=$1, service_id=$2, staff_id=$3, start_date_time=$4, end_date_time=$5, status=$6 
+		WHERE availability_id=$7
 	`
 
-	_, err := s.db.Exec(query, availability.SalonID, availability.ServiceID, availability.StartDateTime, availability.EndDateTime, availability.Status, availability.AvailabilityID)
+	result, err := s.db.Exec(query, availability.SalonID, availability.ServiceID, availability.StaffID, availability.StartDateTime, availability.EndDateTime, availability.Status, availability.AvailabilityID)
 	if err != nil {
 		log.Printf("%s: %v", ErrorAvailabilityUpdate, err)
 		return nil, err
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrAvailabilityNotFound
+	}
 
 	return availability, nil
 }
 
-// DeleteAvailability deletes an availability entry by its unique ID.
+// DeleteAvailability deletes an availability entry by its unique ID. Slots
+// held by an appointment are kept.
 func (s *availabilityServiceImpl) DeleteAvailability(availabilityID int) error {
-	const query = `DELETE FROM availability WHERE availability_id=$1`
+	const query = `
+		DELETE FROM availabilities
+		WHERE availability_id=$1 AND NOT EXISTS (SELECT 1 FROM appointments WHERE availability_id=$1)
+	`
 
-	_, err := s.db.Exec(query, availabilityID)
+	result, err := s.db.Exec(query, availabilityID)
 	if err != nil {
 		log.Printf("Error deleting availability: %v", err)
 		return err
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if err := s.checkNotHeld(availabilityID); err != nil {
+			return err
+		}
+		return ErrAvailabilityNotFound
+	}
 
 	return nil
 }
 
-// ListAvailabilitiesBySalonID retrieves all availabilities for a specific salon by its ID.
-func (s *availabilityServiceImpl) ListAvailabilitiesBySalonID(salonID int) ([]*models.Availability, error) {
-	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE salon_id=$1
-	`
+// ListAvailabilitiesBySalonID retrieves a page of the availabilities for a specific salon by its ID.
+func (s *availabilityServiceImpl) ListAvailabilitiesBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "availabilities by salon", "salon_id=$1", salonID)
+}
 
-	rows, err := s.db.Query(query, salonID)
-	if err != nil {
-		log.Printf("Error listing availabilities by salon: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
+// ListAvailabilitiesByServiceID retrieves a page of the availabilities for a specific service by its ID.
+func (s *availabilityServiceImpl) ListAvailabilitiesByServiceID(serviceID int, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "availabilities by service", "service_id=$1", serviceID)
+}
 
-	var availabilities []*models.Availability
-	for rows.Next() {
-		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
-			log.Printf("Error scanning availability row: %v", err)
-			return nil, err
-		}
-		availabilities = append(availabilities, &availability)
-	}
+// ListAvailabilitiesByStatus retrieves a page of the availabilities with a specific status.
+func (s *availabilityServiceImpl) ListAvailabilitiesByStatus(status string, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "availabilities by status", "status=$1", status)
+}
 
-	return availabilities, nil
+// ListOpenAvailabilities retrieves a page of the open (available) time slots for a specific service and salon.
+func (s *availabilityServiceImpl) ListOpenAvailabilities(serviceID, salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "open availabilities", "salon_id=$1 AND service_id=$2 AND status='available'", salonID, serviceID)
 }
 
-// ListAvailabilitiesByServiceID retrieves all availabilities for a specific service by its ID.
-func (s *availabilityServiceImpl) ListAvailabilitiesByServiceID(serviceID int) ([]*models.Availability, error) {
-	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE service_id=$1
-	`
+// BookAvailability marks an available time slot as booked. The update only
+// applies to an available slot, so of two concurrent calls one fails with
+// ErrSlotTaken.
+func (s *availabilityServiceImpl) BookAvailability(availabilityID int) error {
+	const query = `UPDATE availabilities SET status='booked' WHERE availability_id=$1 AND status='available'`
 
-	rows, err := s.db.Query(query, serviceID)
+	result, err := s.db.Exec(query, availabilityID)
 	if err != nil {
-		log.Printf("Error listing availabilities by service: %v", err)
-		return nil, err
+		log.Printf("Error booking availability: %v", err)
+		return err
 	}
-	defer rows.Close()
-
-	var availabilities []*models.Availability
-	for rows.Next() {
-		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
-			log.Printf("Error scanning availability row: %v", err)
-			return nil, err
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if _, err := s.GetAvailabilityByID(availabilityID); err != nil {
+			return err
 		}
-		availabilities = append(availabilities, &availability)
+		return ErrSlotTaken
 	}
 
-	return availabilities, nil
+	return nil
 }
 
-// ListAvailabilitiesByStatus retrieves all availabilities with a specific status.
-func (s *availabilityServiceImpl) ListAvailabilitiesByStatus(status string) ([]*models.Availability, error) {
+// CancelBooking marks a booked time slot as available again. Slots held by an
+// appointment are released by cancelling the appointment.
+func (s *availabilityServiceImpl) CancelBooking(availabilityID int) error {
 	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE status=$1
+		UPDATE availabilities SET status='available'
+		WHERE availability_id=$1 AND status='booked'
+			AND NOT EXISTS (SELECT 1 FROM appointments WHERE availability_id=$1)
 	`
 
-	rows, err := s.db.Query(query, status)
+	result, err := s.db.Exec(query, availabilityID)
 	if err != nil {
-		log.Printf("Error listing availabilities by status: %v", err)
-		return nil, err
+		log.Printf("Error canceling booking: %v", err)
+		return err
 	}
-	defer rows.Close()
-
-	var availabilities []*models.Availability
-	for rows.Next() {
-		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
-			log.Printf("Error scanning availability row: %v", err)
-			return nil, err
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if err := s.checkNotHeld(availabilityID); err != nil {
+			return err
 		}
-		availabilities = append(availabilities, &availability)
+		if _, err := s.GetAvailabilityByID(availabilityID); err != nil {
+			return err
+		}
+		return ErrSlotNotBooked
 	}
 
-	return availabilities, nil
+	return nil
 }
 
-// ListOpenAvailabilities retrieves all open (available) time slots for a specific service and salon.
-func (s *availabilityServiceImpl) ListOpenAvailabilities(serviceID, salonID int) ([]*models.Availability, error) {
-	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE salon_id=$1 AND service_id=$2 AND status='Open'
+// ListBookedAvailabilities retrieves a page of the booked time slots for a specific service and salon.
+func (s *availabilityServiceImpl) ListBookedAvailabilities(serviceID, salonID int, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "booked availabilities", "salon_id=$1 AND service_id=$2 AND status='booked'", salonID, serviceID)
+}
+
+// ListAvailabilitiesByDateRange retrieves a page of the availabilities between the specified start and end dates.
+func (s *availabilityServiceImpl) ListAvailabilitiesByDateRange(startDate, endDate string, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "availabilities by date range", "start_date_time >= $1 AND end_date_time <= $2", startDate, endDate)
+}
+
+// ListAvailabilitiesByStaffID retrieves a page of the availabilities of a specific staff member.
+func (s *availabilityServiceImpl) ListAvailabilitiesByStaffID(staffID int, page pagination.Query) (*pagination.Page[*models.Availability], error) {
+	return s.listAvailabilities(page, "availabilities by staff", "staff_id=$1", staffID)
+}
+
+// listAvailabilities retrieves a page of the availabilities matching a
+// condition with the given parameters. what describes them in the log.
+func (s *availabilityServiceImpl) listAvailabilities(page pagination.Query, what, where string, params ...interface{}) (*pagination.Page[*models.Availability], error) {
+	const selectFrom = `
+		SELECT availability_id, salon_id, service_id, staff_id, start_date_time, end_date_time, status
+		FROM availabilities
 	`
 
-	rows, err := s.db.Query(query, salonID, serviceID)
+	query, args := page.SQL(selectFrom, where, params...)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		log.Printf("Error listing open availabilities: %v", err)
+		log.Printf("Error listing %s: %v", what, err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -201,92 +271,115 @@ func (s *availabilityServiceImpl) ListOpenAvailabilities(serviceID, salonID int)
 	var availabilities []*models.Availability
 	for rows.Next() {
 		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
+		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StaffID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
 			log.Printf("Error scanning availability row: %v", err)
 			return nil, err
 		}
 		availabilities = append(availabilities, &availability)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return availabilities, nil
+	return pagination.NewPage(availabilities, page)
 }
 
-// BookAvailability books an available time slot, updating its status to "Booked."
-func (s *availabilityServiceImpl) BookAvailability(availabilityID int) error {
-	const query = `UPDATE availability SET status='Booked' WHERE availability_id=$1`
+// fitService checks that an availability is long enough for its service and
+// that the salon is open for the whole of it, the buffers of the service
+// included. An availability without an end is given the length of the service.
+func (s *availabilityServiceImpl) fitService(availability *models.Availability) error {
+	const query = `SELECT duration, buffer_before, buffer_after FROM services WHERE service_id=$1 AND salon_id=$2`
 
-	_, err := s.db.Exec(query, availabilityID)
-	if err != nil {
-		log.Printf("Error booking availability: %v", err)
+	var duration, before, after models.Duration
+	if err := s.db.QueryRow(query, availability.ServiceID, availability.SalonID).Scan(&duration, &before, &after); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrServiceNotFound
+		}
+		log.Printf("Error retrieving service duration: %v", err)
 		return err
 	}
 
-	return nil
-}
-
-// CancelBooking cancels a booked time slot, updating its status to "Open."
-func (s *availabilityServiceImpl) CancelBooking(availabilityID int) error {
-	const query = `UPDATE availability SET status='Open' WHERE availability_id=$1`
-
-	_, err := s.db.Exec(query, availabilityID)
+	loc, err := schedule.Location(s.db, availability.SalonID)
 	if err != nil {
-		log.Printf("Error canceling booking: %v", err)
 		return err
 	}
-
-	return nil
-}
-
-// ListBookedAvailabilities retrieves all booked time slots for a specific service and salon.
-func (s *availabilityServiceImpl) ListBookedAvailabilities(serviceID, salonID int) ([]*models.Availability, error) {
-	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE salon_id=$1 AND service_id=$2 AND status='Booked'
-	`
-
-	rows, err := s.db.Query(query, salonID, serviceID)
+	start, err := schedule.ParseTime(availability.StartDateTime, loc)
 	if err != nil {
-		log.Printf("Error listing booked availabilities: %v", err)
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var availabilities []*models.Availability
-	for rows.Next() {
-		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
-			log.Printf("Error scanning availability row: %v", err)
-			return nil, err
+	// Both ends are stored as wall times of the salon; an offset given with
+	// them would be dropped by the database.
+	availability.StartDateTime = start.In(loc).Format(schedule.WallLayout)
+
+	var length time.Duration
+	if availability.EndDateTime == "" {
+		length = duration.Std()
+		availability.EndDateTime = start.Add(length).In(loc).Format(schedule.WallLayout)
+	} else {
+		end, err := schedule.ParseTime(availability.EndDateTime, loc)
+		if err != nil {
+			return err
 		}
-		availabilities = append(availabilities, &availability)
+		length = end.Sub(start)
+		if length <= 0 {
+			return schedule.ErrInvalidPeriod
+		}
+		if length < duration.Std() {
+			return ErrSlotTooShort
+		}
+		availability.EndDateTime = end.In(loc).Format(schedule.WallLayout)
 	}
 
-	return availabilities, nil
+	return schedule.CheckOpenFor(s.db, availability.SalonID, availability.StartDateTime, before.Std(), length, after.Std())
 }
 
-// ListAvailabilitiesByDateRange retrieves all availabilities between the specified start and end dates.
-func (s *availabilityServiceImpl) ListAvailabilitiesByDateRange(startDate, endDate string) ([]*models.Availability, error) {
-	const query = `
-		SELECT availability_id, salon_id, service_id, start_date_time, end_date_time, status
-		FROM availability WHERE start_date_time >= $1 AND end_date_time <= $2
-	`
+// checkStatus defaults the status of an availability to available and
+// rejects unknown ones.
+func checkStatus(availability *models.Availability) error {
+	switch availability.Status {
+	case "":
+		availability.Status = models.AvailabilityAvailable
+	case models.AvailabilityAvailable, models.AvailabilityBooked:
+	default:
+		return ErrInvalidStatus
+	}
+	return nil
+}
 
-	rows, err := s.db.Query(query, startDate, endDate)
-	if err != nil {
-		log.Printf("Error listing availabilities by date range: %v", err)
-		return nil, err
+// checkNotHeld returns ErrSlotHeld if an appointment was booked into the slot.
+func (s *availabilityServiceImpl) checkNotHeld(availabilityID int) error {
+	var held bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM appointments WHERE availability_id=$1)`, availabilityID).Scan(&held); err != nil {
+		log.Printf("Error checking appointment of availability: %v", err)
+		return err
 	}
-	defer rows.Close()
+	if held {
+		return ErrSlotHeld
+	}
+	return nil
+}
 
-	var availabilities []*models.Availability
-	for rows.Next() {
-		var availability models.Availability
-		if err := rows.Scan(&availability.AvailabilityID, &availability.SalonID, &availability.ServiceID, &availability.StartDateTime, &availability.EndDateTime, &availability.Status); err != nil {
-			log.Printf("Error scanning availability row: %v", err)
-			return nil, err
-		}
-		availabilities = append(availabilities, &availability)
+// checkStaff verifies that the staff member of an availability, if any, is an
+// active member of its salon who performs its service.
+func (s *availabilityServiceImpl) checkStaff(availability *models.Availability) error {
+	if availability.StaffID == nil {
+		return nil
 	}
 
-	return availabilities, nil
+	const query = `
+		SELECT EXISTS(
+			SELECT 1 FROM staff s JOIN staff_services ss ON ss.staff_id = s.staff_id
+			WHERE s.staff_id=$1 AND s.salon_id=$2 AND ss.service_id=$3 AND s.active)
+	`
+
+	var ok bool
+	if err := s.db.QueryRow(query, *availability.StaffID, availability.SalonID, availability.ServiceID).Scan(&ok); err != nil {
+		log.Printf("Error checking staff of availability: %v", err)
+		return err
+	}
+	if !ok {
+		return ErrInvalidStaff
+	}
+	return nil
 }
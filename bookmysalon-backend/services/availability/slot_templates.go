@@ -0,0 +1,341 @@
+package availability
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/schedule"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultSlotWeeks is how many weeks ahead slots are generated unless told otherwise.
+	DefaultSlotWeeks = 8
+
+	// MaxSlotWeeks bounds how far ahead slots are generated.
+	MaxSlotWeeks = 26
+)
+
+var (
+	ErrInvalidWeekday       = errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	ErrSlotTemplateNotFound = errors.New("slot template not found")
+	ErrWindowTooShort       = errors.New("window is shorter than the service with its buffers")
+	ErrInvalidWeeks         = errors.New("weeks must be between 1 and 26")
+)
+
+// slotTemplate is a template with the length and buffers of its service.
+type slotTemplate struct {
+	models.SlotTemplate
+	window                schedule.Interval
+	before, length, after time.Duration
+}
+
+// CreateSlotTemplate adds a weekly window in which a salon offers a service.
+// The window has to hold at least one slot of the service with its buffers.
+func (s *availabilityServiceImpl) CreateSlotTemplate(template *models.SlotTemplate) (*models.SlotTemplate, error) {
+	if template.Weekday < 0 || template.Weekday > 6 {
+		return nil, ErrInvalidWeekday
+	}
+	window, err := schedule.NewInterval(template.StartsAt, template.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	const serviceQuery = `SELECT duration, buffer_before, buffer_after FROM services WHERE service_id=$1 AND salon_id=$2`
+
+	var duration, before, after models.Duration
+	if err := s.db.QueryRow(serviceQuery, template.ServiceID, template.SalonID).Scan(&duration, &before, &after); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrServiceNotFound
+		}
+		log.Printf("Error retrieving service duration: %v", err)
+		return nil, err
+	}
+	if err := s.checkStaff(&models.Availability{SalonID: template.SalonID, ServiceID: template.ServiceID, StaffID: template.StaffID}); err != nil {
+		return nil, err
+	}
+	if time.Duration(window.Closes-window.Opens)*time.Minute < before.Std()+duration.Std()+after.Std() {
+		return nil, ErrWindowTooShort
+	}
+
+	const query = `
+		INSERT INTO slot_templates(salon_id, service_id, staff_id, weekday, starts_at, ends_at)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING template_id
+	`
+
+	template.StartsAt = schedule.FormatClock(window.Opens)
+	template.EndsAt = schedule.FormatClock(window.Closes)
+	err = s.db.QueryRow(query, template.SalonID, template.ServiceID, template.StaffID, template.Weekday, template.StartsAt, template.EndsAt).Scan(&template.TemplateID)
+	if err != nil {
+		log.Printf("Error inserting slot template: %v", err)
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// GetSlotTemplateByID retrieves a slot template by its ID.
+func (s *availabilityServiceImpl) GetSlotTemplateByID(templateID int) (*models.SlotTemplate, error) {
+	const query = `
+		SELECT template_id, salon_id, service_id, staff_id, weekday, TO_CHAR(starts_at, 'HH24:MI'), TO_CHAR(ends_at, 'HH24:MI')
+		FROM slot_templates WHERE template_id=$1
+	`
+
+	var template models.SlotTemplate
+	err := s.db.QueryRow(query, templateID).Scan(&template.TemplateID, &template.SalonID, &template.ServiceID, &template.StaffID,
+		&template.Weekday, &template.StartsAt, &template.EndsAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSlotTemplateNotFound
+		}
+		log.Printf("Error retrieving slot template: %v", err)
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// ListSlotTemplates retrieves the slot templates of a salon by weekday and start.
+func (s *availabilityServiceImpl) ListSlotTemplates(salonID int) ([]models.SlotTemplate, error) {
+	const query = `
+		SELECT template_id, salon_id, service_id, staff_id, weekday, TO_CHAR(starts_at, 'HH24:MI'), TO_CHAR(ends_at, 'HH24:MI')
+		FROM slot_templates WHERE salon_id=$1
+		ORDER BY weekday, starts_at, template_id
+	`
+
+	rows, err := s.db.Query(query, salonID)
+	if err != nil {
+		log.Printf("Error listing slot templates: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.SlotTemplate{}
+	for rows.Next() {
+		var template models.SlotTemplate
+		if err := rows.Scan(&template.TemplateID, &template.SalonID, &template.ServiceID, &template.StaffID,
+			&template.Weekday, &template.StartsAt, &template.EndsAt); err != nil {
+			log.Printf("Error scanning slot template row: %v", err)
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+// DeleteSlotTemplate deletes a slot template along with the slots generated
+// from it that are still available. Booked slots are kept.
+func (s *availabilityServiceImpl) DeleteSlotTemplate(templateID int) error {
+	const slotsQuery = `
+		DELETE FROM availabilities
+		WHERE template_id=$1 AND status='available'
+			AND NOT EXISTS (SELECT 1 FROM appointments ap WHERE ap.availability_id = availabilities.availability_id)
+	`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error deleting slot template: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(slotsQuery, templateID); err != nil {
+		log.Printf("Error deleting slots of template: %v", err)
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM slot_templates WHERE template_id=$1`, templateID)
+	if err != nil {
+		log.Printf("Error deleting slot template: %v", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSlotTemplateNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error deleting slot template: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GenerateSlots fills the slot templates of a salon with available slots
+// from today until the given number of weeks ahead, and returns how many
+// slots it created. Slots that would start in the past, fall outside the
+// salon's hours or exceptions, or already exist are left out, so running it
+// again only adds what is missing.
+func (s *availabilityServiceImpl) GenerateSlots(salonID, weeks int) (int, error) {
+	if weeks < 1 || weeks > MaxSlotWeeks {
+		return 0, ErrInvalidWeeks
+	}
+
+	loc, err := schedule.Location(s.db, salonID)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 7*weeks)
+
+	cal, err := schedule.Load(s.db, salonID, from, to)
+	if err != nil {
+		log.Printf("Error loading salon hours for slot generation: %v", err)
+		return 0, err
+	}
+	templates, err := s.loadSlotTemplates(salonID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Slots are written as wall times of the salon, like the ones created by
+	// hand. A slot of the same service and staff member at the same time,
+	// generated or not, is not duplicated.
+	const query = `
+		INSERT INTO availabilities(salon_id, service_id, staff_id, start_date_time, end_date_time, status, template_id)
+		SELECT $1::int, $2::int, $3::int, $4::timestamp, $5::timestamp, 'available', $6::int
+		WHERE NOT EXISTS (
+			SELECT 1 FROM availabilities
+			WHERE salon_id=$1::int AND service_id=$2::int AND staff_id IS NOT DISTINCT FROM $3::int AND start_date_time=$4::timestamp)
+		ON CONFLICT (template_id, start_date_time) WHERE template_id IS NOT NULL DO NOTHING
+	`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error generating slots: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	created := 0
+	for _, template := range templates {
+		for _, start := range templateStarts(cal, template, from, to, now) {
+			result, err := tx.Exec(query, salonID, template.ServiceID, template.StaffID,
+				start.Format(schedule.WallLayout), start.Add(template.length).Format(schedule.WallLayout), template.TemplateID)
+			if err != nil {
+				log.Printf("Error generating slots: %v", err)
+				return 0, err
+			}
+			if affected, err := result.RowsAffected(); err == nil {
+				created += int(affected)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error generating slots: %v", err)
+		return 0, err
+	}
+	return created, nil
+}
+
+// GenerateAllSlots runs GenerateSlots for every salon with slot templates and
+// returns how many slots it created in total. A salon that fails is logged
+// and skipped; the last error is returned after the others are done.
+func (s *availabilityServiceImpl) GenerateAllSlots(weeks int) (int, error) {
+	if weeks < 1 || weeks > MaxSlotWeeks {
+		return 0, ErrInvalidWeeks
+	}
+
+	rows, err := s.db.Query(`SELECT DISTINCT salon_id FROM slot_templates ORDER BY salon_id`)
+	if err != nil {
+		log.Printf("Error listing salons with slot templates: %v", err)
+		return 0, err
+	}
+	var salonIDs []int
+	for rows.Next() {
+		var salonID int
+		if err := rows.Scan(&salonID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		salonIDs = append(salonIDs, salonID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var lastErr error
+	for _, salonID := range salonIDs {
+		n, err := s.GenerateSlots(salonID, weeks)
+		if err != nil {
+			log.Printf("Error generating slots for salon %d: %v", salonID, err)
+			lastErr = err
+			continue
+		}
+		total += n
+	}
+	return total, lastErr
+}
+
+// loadSlotTemplates reads the templates of a salon with the length and
+// buffers of their services. Templates of staff members who are no longer
+// active or no longer perform the service are left out.
+func (s *availabilityServiceImpl) loadSlotTemplates(salonID int) ([]slotTemplate, error) {
+	const query = `
+		SELECT t.template_id, t.salon_id, t.service_id, t.staff_id, t.weekday,
+			TO_CHAR(t.starts_at, 'HH24:MI'), TO_CHAR(t.ends_at, 'HH24:MI'),
+			sv.duration, sv.buffer_before, sv.buffer_after
+		FROM slot_templates t JOIN services sv ON sv.service_id = t.service_id
+		WHERE t.salon_id=$1 AND (t.staff_id IS NULL OR EXISTS (
+			SELECT 1 FROM staff st JOIN staff_services ss ON ss.staff_id = st.staff_id
+			WHERE st.staff_id = t.staff_id AND ss.service_id = t.service_id AND st.active))
+		ORDER BY t.template_id
+	`
+
+	rows, err := s.db.Query(query, salonID)
+	if err != nil {
+		log.Printf("Error loading slot templates: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []slotTemplate
+	for rows.Next() {
+		var t slotTemplate
+		var duration, before, after models.Duration
+		if err := rows.Scan(&t.TemplateID, &t.SalonID, &t.ServiceID, &t.StaffID, &t.Weekday, &t.StartsAt, &t.EndsAt,
+			&duration, &before, &after); err != nil {
+			log.Printf("Error scanning slot template row: %v", err)
+			return nil, err
+		}
+		if t.window, err = schedule.NewInterval(t.StartsAt, t.EndsAt); err != nil {
+			return nil, err
+		}
+		t.before, t.length, t.after = before.Std(), duration.Std(), after.Std()
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+// templateStarts lists the starts of the slots a template gives on the dates
+// from up to to. Slots are laid back to back through the window, each with
+// the buffers of the service around it. Slots starting before now and slots
+// the salon is not open for, buffers included, are left out.
+func templateStarts(cal *schedule.Calendar, t slotTemplate, from, to, now time.Time) []time.Time {
+	if t.length <= 0 {
+		return nil
+	}
+	step := t.before + t.length + t.after
+
+	var starts []time.Time
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Weekday(t.Weekday) {
+			continue
+		}
+		opens := time.Date(day.Year(), day.Month(), day.Day(), 0, t.window.Opens, 0, 0, cal.Location)
+		closes := time.Date(day.Year(), day.Month(), day.Day(), 0, t.window.Closes, 0, 0, cal.Location)
+
+		for start := opens.Add(t.before); !start.Add(t.length + t.after).After(closes); start = start.Add(step) {
+			if start.Before(now) || !cal.IsOpen(start.Add(-t.before), start.Add(t.length+t.after)) {
+				continue
+			}
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
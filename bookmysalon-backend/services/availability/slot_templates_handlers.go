@@ -0,0 +1,175 @@
+package availability
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/schedule"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Create a slot template
+// @Description Add a weekly window in which the salon offers a service, optionally with a specific staff member. Slot generation fills it with slots of the service's length, buffers included.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param template body models.SlotTemplate true "Create Slot Template"
+// @Success 201 {object} models.SlotTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/slot-templates [post]
+func (h *AvailabilityHandler) CreateSlotTemplate(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var template models.SlotTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	template.SalonID = salonID
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	created, err := h.service.CreateSlotTemplate(&template)
+	if err != nil {
+		writeSlotTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// @Summary List the slot templates of a salon
+// @Description Retrieve the weekly slot templates of a salon, by weekday and start
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {array} models.SlotTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/slot-templates [get]
+func (h *AvailabilityHandler) ListSlotTemplates(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	templates, err := h.service.ListSlotTemplates(salonID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(templates)
+}
+
+// @Summary Delete a slot template
+// @Description Delete a slot template and the slots generated from it that are still available. Booked slots are kept.
+// @Accept  json
+// @Produce  json
+// @Param templateID path int true "Slot Template ID"
+// @Success 204 "Successfully deleted"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Slot Template Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /slot-template/{templateID} [delete]
+func (h *AvailabilityHandler) DeleteSlotTemplate(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(mux.Vars(r)["templateID"])
+	if err != nil {
+		http.Error(w, "Invalid slot template ID", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.service.GetSlotTemplateByID(templateID)
+	if err != nil {
+		writeSlotTemplateError(w, err)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), template.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.DeleteSlotTemplate(templateID); err != nil {
+		writeSlotTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Generate slots
+// @Description Fill the slot templates of a salon with available slots from today until the given number of weeks ahead. Slots outside the salon's hours or on its closures are left out, and slots that already exist are not created again, so it is safe to run repeatedly.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param weeks query int false "Weeks ahead (default 8, at most 26)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/slots/generate [post]
+func (h *AvailabilityHandler) GenerateSlots(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	weeks := DefaultSlotWeeks
+	if value := r.URL.Query().Get("weeks"); value != "" {
+		if weeks, err = strconv.Atoi(value); err != nil {
+			http.Error(w, ErrInvalidWeeks.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	created, err := h.service.GenerateSlots(salonID, weeks)
+	if err != nil {
+		writeSlotTemplateError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"created": created})
+}
+
+func writeSlotTemplateError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidWeekday, ErrWindowTooShort, ErrInvalidWeeks, ErrInvalidStaff, ErrServiceNotFound,
+		schedule.ErrInvalidClock, schedule.ErrInvalidInterval:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrSlotTemplateNotFound, schedule.ErrSalonNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Println("Failed to handle slot templates:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
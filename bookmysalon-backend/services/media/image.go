@@ -0,0 +1,124 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// Decoders of the accepted formats besides JPEG, used by image.Decode.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Limits of the uploaded images.
+const (
+	MaxUploadBytes = 10 << 20
+	MaxPixels      = 40_000_000
+	ThumbnailSize  = 320
+)
+
+// extensions maps the accepted content types to the extension of their blobs.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// upload is a checked image, ready to be stored.
+type upload struct {
+	data        []byte
+	contentType string
+	width       int
+	height      int
+	thumbnail   []byte
+}
+
+// checkImage validates an uploaded file and renders its thumbnail. The content
+// type is sniffed from the data; whatever the client claims is ignored.
+func checkImage(data []byte) (*upload, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyFile
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	// The dimensions are checked before decoding, so a small file cannot make
+	// us allocate a huge image.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, thumbnailOf(img), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return &upload{
+		data:        data,
+		contentType: contentType,
+		width:       config.Width,
+		height:      config.Height,
+		thumbnail:   thumbnail.Bytes(),
+	}, nil
+}
+
+// thumbnailOf scales an image down to fit ThumbnailSize, keeping its aspect
+// ratio. Each pixel of the thumbnail averages the pixels it covers, and
+// transparent areas are put on white since JPEG has no alpha channel.
+func thumbnailOf(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if width > ThumbnailSize || height > ThumbnailSize {
+		scale = float64(ThumbnailSize) / float64(max(width, height))
+	}
+	thumbWidth := max(1, int(float64(width)*scale+0.5))
+	thumbHeight := max(1, int(float64(height)*scale+0.5))
+
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for ty := 0; ty < thumbHeight; ty++ {
+		y0 := bounds.Min.Y + ty*height/thumbHeight
+		y1 := max(y0+1, bounds.Min.Y+(ty+1)*height/thumbHeight)
+		for tx := 0; tx < thumbWidth; tx++ {
+			x0 := bounds.Min.X + tx*width/thumbWidth
+			x1 := max(x0+1, bounds.Min.X+(tx+1)*width/thumbWidth)
+
+			var r, g, b, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := img.At(x, y).RGBA()
+					// Premultiplied colour over white.
+					r += uint64(pr + 0xffff - pa)
+					g += uint64(pg + 0xffff - pa)
+					b += uint64(pb + 0xffff - pa)
+					n++
+				}
+			}
+			thumb.Set(tx, ty, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: 0xffff,
+			})
+		}
+	}
+	return thumb
+}
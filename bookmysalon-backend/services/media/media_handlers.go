@@ -0,0 +1,426 @@
+package media
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/blobstore"
+	"bookmysalon/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// MediaHandler represents the HTTP handler for uploading and serving photos.
+type MediaHandler struct {
+	service MediaService
+	policy  authz.Policy
+}
+
+// NewMediaHandler initializes and returns an instance of MediaHandler.
+func NewMediaHandler(s MediaService, p authz.Policy) *MediaHandler {
+	return &MediaHandler{service: s, policy: p}
+}
+
+// photoOrder is the body of a reorder request.
+type photoOrder struct {
+	PhotoIDs []int `json:"photo_ids"`
+}
+
+// @Summary Upload a salon photo
+// @Description Upload a JPEG, PNG or GIF image of at most 10 MB as the last photo of a salon. A thumbnail is generated. The first photo is the salon's cover.
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param file formData file true "Image"
+// @Success 201 {object} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 413 {object} map[string]string "File Too Large"
+// @Failure 415 {object} map[string]string "Unsupported Image Type"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/photos [post]
+func (h *MediaHandler) UploadSalonPhoto(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	photo, err := h.service.AddSalonPhoto(salonID, data)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(photo)
+}
+
+// @Summary List salon photos
+// @Description Retrieve the photos of a salon in order
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {array} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/photos [get]
+func (h *MediaHandler) ListSalonPhotos(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	photos, err := h.service.ListSalonPhotos(salonID)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// @Summary Reorder salon photos
+// @Description Put the photos of a salon in a new order. The list must name each of the salon's photos once.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param order body photoOrder true "Photo IDs in the new order"
+// @Success 200 {array} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/photos/order [put]
+func (h *MediaHandler) ReorderSalonPhotos(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var order photoOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	photos, err := h.service.ReorderSalonPhotos(salonID, order.PhotoIDs)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// @Summary Upload a service photo
+// @Description Upload a JPEG, PNG or GIF image of at most 10 MB as the last photo of a service. A thumbnail is generated.
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Param file formData file true "Image"
+// @Success 201 {object} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
+// @Failure 413 {object} map[string]string "File Too Large"
+// @Failure 415 {object} map[string]string "Unsupported Image Type"
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/photos [post]
+func (h *MediaHandler) UploadServicePhoto(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := strconv.Atoi(mux.Vars(r)["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeService(r.Context(), serviceID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	photo, err := h.service.AddServicePhoto(serviceID, data)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(photo)
+}
+
+// @Summary List service photos
+// @Description Retrieve the photos of a service in order
+// @Accept  json
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Success 200 {array} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/photos [get]
+func (h *MediaHandler) ListServicePhotos(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := strconv.Atoi(mux.Vars(r)["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	photos, err := h.service.ListServicePhotos(serviceID)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// @Summary Reorder service photos
+// @Description Put the photos of a service in a new order. The list must name each of the service's photos once.
+// @Accept  json
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Param order body photoOrder true "Photo IDs in the new order"
+// @Success 200 {array} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/photos/order [put]
+func (h *MediaHandler) ReorderServicePhotos(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := strconv.Atoi(mux.Vars(r)["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	var order photoOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeService(r.Context(), serviceID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	photos, err := h.service.ReorderServicePhotos(serviceID, order.PhotoIDs)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// @Summary Delete a photo
+// @Description Delete a salon, service or profile photo along with its files
+// @Accept  json
+// @Produce  json
+// @Param photoID path int true "Photo ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Photo Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /photo/{photoID} [delete]
+func (h *MediaHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	photoID, err := strconv.Atoi(mux.Vars(r)["photoID"])
+	if err != nil {
+		http.Error(w, "Invalid photo ID", http.StatusBadRequest)
+		return
+	}
+
+	photo, err := h.service.GetPhotoByID(photoID)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	if err := h.authorizePhoto(r, photo); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.DeletePhoto(photoID); err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Upload a profile image
+// @Description Upload a JPEG, PNG or GIF image of at most 10 MB as the caller's profile image, replacing the previous one
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param file formData file true "Image"
+// @Success 201 {object} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 413 {object} map[string]string "File Too Large"
+// @Failure 415 {object} map[string]string "Unsupported Image Type"
+// @Failure 500 {object} map[string]string
+// @Router /profile/image [post]
+func (h *MediaHandler) UploadProfileImage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	photo, err := h.service.SetProfileImage(userID, data)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(photo)
+}
+
+// @Summary Delete the profile image
+// @Description Remove the caller's profile image
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /profile/image [delete]
+func (h *MediaHandler) DeleteProfileImage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteProfileImage(userID); err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Serve a media file
+// @Description Serve an uploaded image or thumbnail. The URLs of photos point here unless another base URL is configured.
+// @Produce  image/jpeg,image/png,image/gif
+// @Param key path string true "Blob key"
+// @Success 200
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /media/{key} [get]
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	blob, info, err := h.service.OpenBlob(r.Context(), mux.Vars(r)["key"])
+	if err != nil {
+		if err == blobstore.ErrNotFound || err == blobstore.ErrInvalidKey {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error opening media file: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// Keys are random and never rewritten, so the files can be cached for good.
+	w.Header().Set("Content-Type", info.ContentType)
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	io.Copy(w, blob)
+}
+
+// authorizePhoto checks the caller may change a photo of its owner.
+func (h *MediaHandler) authorizePhoto(r *http.Request, photo *models.Photo) error {
+	switch {
+	case photo.SalonID != nil:
+		return h.policy.AuthorizeSalon(r.Context(), *photo.SalonID)
+	case photo.ServiceID != nil:
+		return h.policy.AuthorizeService(r.Context(), *photo.ServiceID)
+	case photo.UserID != nil:
+		return h.policy.AuthorizeUser(r.Context(), *photo.UserID)
+	}
+	return authz.ErrForbidden
+}
+
+// readUpload reads the "file" field of a multipart request. It writes the
+// error response and returns false when there is no usable file.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	// Leave room for the rest of the form around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadBytes+1<<20)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, ErrFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "A multipart form with an image in the file field is required", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadBytes+1))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(data) > MaxUploadBytes {
+		http.Error(w, ErrFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	return data, true
+}
+
+func writeMediaError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrEmptyFile, ErrInvalidImage, ErrImageTooLarge, ErrTooManyPhotos, ErrInvalidOrder:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrFileTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case ErrUnsupportedType:
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case ErrPhotoNotFound, ErrSalonNotFound, ErrServiceNotFound, ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,50 @@
+package media
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/blobstore"
+	"context"
+	"io"
+)
+
+// MediaService represents the interface for managing uploaded photos.
+type MediaService interface {
+	// AddSalonPhoto stores an image as the last photo of a salon.
+	AddSalonPhoto(salonID int, data []byte) (*models.Photo, error)
+
+	// ListSalonPhotos retrieves the photos of a salon in order.
+	ListSalonPhotos(salonID int) ([]models.Photo, error)
+
+	// ReorderSalonPhotos puts the photos of a salon in the given order. The
+	// IDs must be exactly the salon's photos.
+	ReorderSalonPhotos(salonID int, photoIDs []int) ([]models.Photo, error)
+
+	// AddServicePhoto stores an image as the last photo of a service.
+	AddServicePhoto(serviceID int, data []byte) (*models.Photo, error)
+
+	// ListServicePhotos retrieves the photos of a service in order.
+	ListServicePhotos(serviceID int) ([]models.Photo, error)
+
+	// ReorderServicePhotos puts the photos of a service in the given order.
+	// The IDs must be exactly the service's photos.
+	ReorderServicePhotos(serviceID int, photoIDs []int) ([]models.Photo, error)
+
+	// GetPhotoByID retrieves a photo using its ID.
+	GetPhotoByID(photoID int) (*models.Photo, error)
+
+	// DeletePhoto removes a photo and its files.
+	DeletePhoto(photoID int) error
+
+	// SetProfileImage stores an image as the profile image of a user,
+	// replacing the previous one.
+	SetProfileImage(userID int, data []byte) (*models.Photo, error)
+
+	// DeleteProfileImage removes the profile image of a user.
+	DeleteProfileImage(userID int) error
+
+	// OpenBlob opens a stored file for serving. The caller closes it.
+	OpenBlob(ctx context.Context, key string) (io.ReadCloser, blobstore.Info, error)
+
+	// CollectGarbage removes the files of deleted photos from the blob store.
+	CollectGarbage() (int, error)
+}
@@ -0,0 +1,578 @@
+package media
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/blobstore"
+	"bookmysalon/pkg/database"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxPhotosPerOwner is the number of photos a salon or service can have.
+const MaxPhotosPerOwner = 20
+
+// DefaultBaseURL is where the photos are served when no base URL is configured.
+const DefaultBaseURL = "/media"
+
+// storeTimeout bounds the blob store calls of a request.
+const storeTimeout = 30 * time.Second
+
+var (
+	ErrEmptyFile       = errors.New("the file is empty")
+	ErrFileTooLarge    = fmt.Errorf("the file must not be larger than %d MB", MaxUploadBytes>>20)
+	ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images are accepted")
+	ErrInvalidImage    = errors.New("the file is not a valid image")
+	ErrImageTooLarge   = errors.New("the image has too many pixels")
+	ErrTooManyPhotos   = fmt.Errorf("at most %d photos are allowed", MaxPhotosPerOwner)
+	ErrInvalidOrder    = errors.New("the order must list each photo exactly once")
+	ErrPhotoNotFound   = errors.New("photo not found")
+	ErrSalonNotFound   = errors.New("salon not found")
+	ErrServiceNotFound = errors.New("service not found")
+	ErrUserNotFound    = errors.New("user not found")
+)
+
+// photoColumns selects a photo in the order scanPhoto expects.
+const photoColumns = `
+	photo_id, salon_id, service_id, user_id, blob_key, thumbnail_key, content_type, size_bytes,
+	width, height, position, TO_CHAR(created_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+`
+
+// owner is the salon, service or user photos are attached to.
+type owner struct {
+	// column is the column of photos referencing the owner.
+	column string
+	id     int
+
+	// lockQuery locks the owner row, so concurrent uploads count the photos
+	// one after the other.
+	lockQuery string
+	notFound  error
+
+	// keyPrefix is the start of the blob keys of the owner.
+	keyPrefix string
+}
+
+func salonOwner(salonID int) owner {
+	return owner{
+		column:    "salon_id",
+		id:        salonID,
+		lockQuery: `SELECT 1 FROM salons WHERE salon_id=$1 FOR UPDATE`,
+		notFound:  ErrSalonNotFound,
+		keyPrefix: fmt.Sprintf("salons/%d", salonID),
+	}
+}
+
+func serviceOwner(serviceID int) owner {
+	return owner{
+		column:    "service_id",
+		id:        serviceID,
+		lockQuery: `SELECT 1 FROM services WHERE service_id=$1 FOR UPDATE`,
+		notFound:  ErrServiceNotFound,
+		keyPrefix: fmt.Sprintf("services/%d", serviceID),
+	}
+}
+
+func userOwner(userID int) owner {
+	return owner{
+		column:    "user_id",
+		id:        userID,
+		lockQuery: `SELECT 1 FROM users WHERE id=$1 AND anonymized_at IS NULL FOR UPDATE`,
+		notFound:  ErrUserNotFound,
+		keyPrefix: fmt.Sprintf("users/%d", userID),
+	}
+}
+
+// mediaServiceImpl is the implementation of the MediaService interface.
+type mediaServiceImpl struct {
+	db      *sql.DB
+	store   blobstore.Store
+	baseURL string
+}
+
+// NewMediaService initializes and returns an instance of MediaService. Photos
+// are served under baseURL, DefaultBaseURL if it is empty.
+func NewMediaService(store blobstore.Store, baseURL string) (MediaService, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &mediaServiceImpl{
+		db:      db,
+		store:   store,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// AddSalonPhoto stores an image as the last photo of a salon.
+func (s *mediaServiceImpl) AddSalonPhoto(salonID int, data []byte) (*models.Photo, error) {
+	return s.addPhoto(salonOwner(salonID), data)
+}
+
+// ListSalonPhotos retrieves the photos of a salon in order.
+func (s *mediaServiceImpl) ListSalonPhotos(salonID int) ([]models.Photo, error) {
+	return s.listPhotos(s.db, salonOwner(salonID))
+}
+
+// ReorderSalonPhotos puts the photos of a salon in the given order.
+func (s *mediaServiceImpl) ReorderSalonPhotos(salonID int, photoIDs []int) ([]models.Photo, error) {
+	return s.reorderPhotos(salonOwner(salonID), photoIDs)
+}
+
+// AddServicePhoto stores an image as the last photo of a service.
+func (s *mediaServiceImpl) AddServicePhoto(serviceID int, data []byte) (*models.Photo, error) {
+	return s.addPhoto(serviceOwner(serviceID), data)
+}
+
+// ListServicePhotos retrieves the photos of a service in order.
+func (s *mediaServiceImpl) ListServicePhotos(serviceID int) ([]models.Photo, error) {
+	return s.listPhotos(s.db, serviceOwner(serviceID))
+}
+
+// ReorderServicePhotos puts the photos of a service in the given order.
+func (s *mediaServiceImpl) ReorderServicePhotos(serviceID int, photoIDs []int) ([]models.Photo, error) {
+	return s.reorderPhotos(serviceOwner(serviceID), photoIDs)
+}
+
+// GetPhotoByID retrieves a photo using its ID.
+func (s *mediaServiceImpl) GetPhotoByID(photoID int) (*models.Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM photos WHERE photo_id=$1`
+
+	photo, err := s.scanPhoto(s.db.QueryRow(query, photoID).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPhotoNotFound
+		}
+		log.Printf("Error retrieving photo by ID: %v", err)
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+// DeletePhoto removes a photo. Its files are queued by the database and
+// removed right away, or by the garbage collector if that fails.
+func (s *mediaServiceImpl) DeletePhoto(photoID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error deleting photo: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var salonID, userID sql.NullInt64
+	var blobKey, thumbnailKey string
+	err = tx.QueryRow(`DELETE FROM photos WHERE photo_id=$1 RETURNING salon_id, user_id, blob_key, thumbnail_key`, photoID).
+		Scan(&salonID, &userID, &blobKey, &thumbnailKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPhotoNotFound
+		}
+		log.Printf("Error deleting photo: %v", err)
+		return err
+	}
+
+	if salonID.Valid {
+		err = s.syncSalonCover(tx, int(salonID.Int64))
+	} else if userID.Valid {
+		err = s.syncProfileImage(tx, int(userID.Int64))
+	}
+	if err != nil {
+		log.Printf("Error deleting photo: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error deleting photo: %v", err)
+		return err
+	}
+
+	s.removeBlobs([]string{blobKey, thumbnailKey})
+	return nil
+}
+
+// SetProfileImage stores an image as the profile image of a user, replacing
+// the previous one.
+func (s *mediaServiceImpl) SetProfileImage(userID int, data []byte) (*models.Photo, error) {
+	return s.addPhoto(userOwner(userID), data)
+}
+
+// DeleteProfileImage removes the profile image of a user.
+func (s *mediaServiceImpl) DeleteProfileImage(userID int) error {
+	photos, err := s.listPhotos(s.db, userOwner(userID))
+	if err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		if err := s.DeletePhoto(photo.PhotoID); err != nil && err != ErrPhotoNotFound {
+			return err
+		}
+	}
+
+	// Profile images set before uploads existed have no photo.
+	if _, err := s.db.Exec(`UPDATE users SET profile_image=NULL WHERE id=$1`, userID); err != nil {
+		log.Printf("Error clearing profile image: %v", err)
+		return err
+	}
+	return nil
+}
+
+// OpenBlob opens a stored file for serving.
+func (s *mediaServiceImpl) OpenBlob(ctx context.Context, key string) (io.ReadCloser, blobstore.Info, error) {
+	return s.store.Get(ctx, key)
+}
+
+// CollectGarbage removes the queued files of deleted photos from the blob store.
+func (s *mediaServiceImpl) CollectGarbage() (int, error) {
+	const query = `SELECT blob_key FROM media_garbage ORDER BY queued_at LIMIT 500`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		log.Printf("Error listing media garbage: %v", err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			log.Printf("Error scanning media garbage: %v", err)
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return s.removeBlobs(keys), nil
+}
+
+// addPhoto checks and stores an image and appends it to the photos of the
+// owner. Users have a single photo, which the new one replaces.
+func (s *mediaServiceImpl) addPhoto(o owner, data []byte) (*models.Photo, error) {
+	img, err := checkImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	blobKey := o.keyPrefix + "/" + name + extensions[img.contentType]
+	thumbnailKey := o.keyPrefix + "/" + name + "_thumb.jpg"
+
+	// The files go first: a photo row never points at missing files, and the
+	// files of a failed insert are removed below.
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := s.store.Put(ctx, blobKey, img.data, img.contentType); err != nil {
+		log.Printf("Error storing photo: %v", err)
+		return nil, err
+	}
+	if err := s.store.Put(ctx, thumbnailKey, img.thumbnail, "image/jpeg"); err != nil {
+		log.Printf("Error storing thumbnail: %v", err)
+		s.removeBlobs([]string{blobKey})
+		return nil, err
+	}
+
+	photoID, replaced, err := s.insertPhoto(o, blobKey, thumbnailKey, img)
+	if err != nil {
+		s.removeBlobs([]string{blobKey, thumbnailKey})
+		return nil, err
+	}
+	s.removeBlobs(replaced)
+
+	return s.GetPhotoByID(photoID)
+}
+
+// insertPhoto records a stored image and returns its ID and the keys of the
+// files it replaced.
+func (s *mediaServiceImpl) insertPhoto(o owner, blobKey, thumbnailKey string, img *upload) (int, []string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error inserting photo: %v", err)
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(o.lockQuery, o.id).Scan(new(int)); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil, o.notFound
+		}
+		log.Printf("Error locking photo owner: %v", err)
+		return 0, nil, err
+	}
+
+	var replaced []string
+	if o.column == "user_id" {
+		rows, err := tx.Query(`DELETE FROM photos WHERE user_id=$1 RETURNING blob_key, thumbnail_key`, o.id)
+		if err != nil {
+			log.Printf("Error replacing profile image: %v", err)
+			return 0, nil, err
+		}
+		for rows.Next() {
+			var key, thumbKey string
+			if err := rows.Scan(&key, &thumbKey); err != nil {
+				rows.Close()
+				return 0, nil, err
+			}
+			replaced = append(replaced, key, thumbKey)
+		}
+		rows.Close()
+	} else {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM photos WHERE `+o.column+`=$1`, o.id).Scan(&count); err != nil {
+			log.Printf("Error counting photos: %v", err)
+			return 0, nil, err
+		}
+		if count >= MaxPhotosPerOwner {
+			return 0, nil, ErrTooManyPhotos
+		}
+	}
+
+	query := `
+		INSERT INTO photos(` + o.column + `, blob_key, thumbnail_key, content_type, size_bytes, width, height, position)
+		SELECT $1, $2, $3, $4, $5, $6, $7, COALESCE(MAX(position) + 1, 0) FROM photos WHERE ` + o.column + `=$1
+		RETURNING photo_id
+	`
+
+	var photoID int
+	err = tx.QueryRow(query, o.id, blobKey, thumbnailKey, img.contentType, len(img.data), img.width, img.height).Scan(&photoID)
+	if err != nil {
+		log.Printf("Error inserting photo: %v", err)
+		return 0, nil, err
+	}
+
+	switch o.column {
+	case "salon_id":
+		err = s.syncSalonCover(tx, o.id)
+	case "user_id":
+		err = s.syncProfileImage(tx, o.id)
+	}
+	if err != nil {
+		log.Printf("Error inserting photo: %v", err)
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error inserting photo: %v", err)
+		return 0, nil, err
+	}
+
+	return photoID, replaced, nil
+}
+
+// listPhotos retrieves the photos of an owner in order.
+func (s *mediaServiceImpl) listPhotos(q queryer, o owner) ([]models.Photo, error) {
+	query := `SELECT ` + photoColumns + ` FROM photos WHERE ` + o.column + `=$1 ORDER BY position, photo_id`
+
+	rows, err := q.Query(query, o.id)
+	if err != nil {
+		log.Printf("Error listing photos: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := []models.Photo{}
+	for rows.Next() {
+		photo, err := s.scanPhoto(rows.Scan)
+		if err != nil {
+			log.Printf("Error scanning photo: %v", err)
+			return nil, err
+		}
+		photos = append(photos, *photo)
+	}
+
+	return photos, rows.Err()
+}
+
+// reorderPhotos sets the positions of the photos of an owner to their place
+// in photoIDs.
+func (s *mediaServiceImpl) reorderPhotos(o owner, photoIDs []int) ([]models.Photo, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error reordering photos: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(o.lockQuery, o.id).Scan(new(int)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, o.notFound
+		}
+		log.Printf("Error locking photo owner: %v", err)
+		return nil, err
+	}
+
+	current, err := s.listPhotos(tx, o)
+	if err != nil {
+		return nil, err
+	}
+	if !isPermutation(current, photoIDs) {
+		return nil, ErrInvalidOrder
+	}
+
+	const query = `
+		UPDATE photos SET position = ord.n - 1
+		FROM unnest($1::int[]) WITH ORDINALITY AS ord(photo_id, n)
+		WHERE photos.photo_id = ord.photo_id
+	`
+	ids := make(pq.Int64Array, len(photoIDs))
+	for i, id := range photoIDs {
+		ids[i] = int64(id)
+	}
+	if _, err := tx.Exec(query, ids); err != nil {
+		log.Printf("Error reordering photos: %v", err)
+		return nil, err
+	}
+
+	if o.column == "salon_id" {
+		if err := s.syncSalonCover(tx, o.id); err != nil {
+			log.Printf("Error reordering photos: %v", err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error reordering photos: %v", err)
+		return nil, err
+	}
+
+	return s.listPhotos(s.db, o)
+}
+
+// syncSalonCover points the photos column of a salon at its first photo, so
+// clients reading the single URL keep working.
+func (s *mediaServiceImpl) syncSalonCover(tx *sql.Tx, salonID int) error {
+	const query = `
+		UPDATE salons SET photos = (
+			SELECT $2 || '/' || blob_key FROM photos WHERE salon_id=$1 ORDER BY position, photo_id LIMIT 1
+		) WHERE salon_id=$1
+	`
+	_, err := tx.Exec(query, salonID, s.baseURL)
+	return err
+}
+
+// syncProfileImage points the profile image of a user at their photo.
+func (s *mediaServiceImpl) syncProfileImage(tx *sql.Tx, userID int) error {
+	const query = `
+		UPDATE users SET profile_image = (
+			SELECT $2 || '/' || blob_key FROM photos WHERE user_id=$1 ORDER BY photo_id DESC LIMIT 1
+		) WHERE id=$1
+	`
+	_, err := tx.Exec(query, userID, s.baseURL)
+	return err
+}
+
+// removeBlobs deletes files from the blob store and from the garbage queue,
+// and returns how many were removed. Files that cannot be deleted stay queued
+// for the garbage collector.
+func (s *mediaServiceImpl) removeBlobs(keys []string) int {
+	removed := 0
+	for _, key := range keys {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		err := s.store.Delete(ctx, key)
+		cancel()
+		if err != nil && err != blobstore.ErrInvalidKey {
+			log.Printf("Error deleting blob %s: %v", key, err)
+			continue
+		}
+		if _, err := s.db.Exec(`DELETE FROM media_garbage WHERE blob_key=$1`, key); err != nil {
+			log.Printf("Error dequeuing blob %s: %v", key, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+func (s *mediaServiceImpl) scanPhoto(scan func(dest ...interface{}) error) (*models.Photo, error) {
+	var photo models.Photo
+	var salonID, serviceID, userID sql.NullInt64
+	var blobKey, thumbnailKey string
+	err := scan(&photo.PhotoID, &salonID, &serviceID, &userID, &blobKey, &thumbnailKey, &photo.ContentType, &photo.Size,
+		&photo.Width, &photo.Height, &photo.Position, &photo.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	photo.SalonID = nullableID(salonID)
+	photo.ServiceID = nullableID(serviceID)
+	photo.UserID = nullableID(userID)
+	photo.URL = s.baseURL + "/" + blobKey
+	photo.ThumbnailURL = s.baseURL + "/" + thumbnailKey
+	return &photo, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func nullableID(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	value := int(id.Int64)
+	return &value
+}
+
+// isPermutation reports whether ids lists each of the photos exactly once.
+func isPermutation(photos []models.Photo, ids []int) bool {
+	if len(photos) != len(ids) {
+		return false
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, photo := range photos {
+		seen[photo.PhotoID] = false
+	}
+	for _, id := range ids {
+		listed, ok := seen[id]
+		if !ok || listed {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+// randomName returns an unguessable file name, so the URLs of photos cannot
+// be enumerated.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// StartGarbageCollector runs CollectGarbage in the background at the given interval.
+func StartGarbageCollector(service MediaService, interval time.Duration) {
+	go func() {
+		for {
+			n, err := service.CollectGarbage()
+			if err != nil {
+				log.Printf("Error collecting media garbage: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Removed %d unused media files", n)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -2,6 +2,7 @@ package review
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
 )
 
 // ReviewService represents the interface for managing user reviews.
@@ -18,12 +19,12 @@ type ReviewService interface {
 	// DeleteReview deletes a review by its unique ID.
 	DeleteReview(reviewID int) error
 
-	// ListReviewsBySalonID retrieves all reviews for a specific salon by salon ID.
-	ListReviewsBySalonID(salonID int) ([]*models.Review, error)
+	// ListReviewsBySalonID retrieves a page of the reviews for a specific salon by salon ID.
+	ListReviewsBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Review], error)
 
-	// ListReviewsByUserID retrieves all reviews posted by a specific user by user ID.
-	ListReviewsByUserID(userID int) ([]*models.Review, error)
+	// ListReviewsByUserID retrieves a page of the reviews posted by a specific user by user ID.
+	ListReviewsByUserID(userID int, page pagination.Query) (*pagination.Page[*models.Review], error)
 
-	// ListReviewsByRating retrieves all reviews with a specific rating.
-	ListReviewsByRating(rating int) ([]*models.Review, error)
+	// ListReviewsByRating retrieves a page of the reviews with a specific rating.
+	ListReviewsByRating(rating int, page pagination.Query) (*pagination.Page[*models.Review], error)
 }
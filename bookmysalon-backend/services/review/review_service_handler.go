@@ -2,6 +2,9 @@ package review
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/pagination"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -12,14 +15,15 @@ import (
 // ReviewHandler represents the HTTP handler for managing user reviews.
 type ReviewHandler struct {
 	service ReviewService
+	policy  authz.Policy
 }
 
 // NewReviewHandler initializes and returns an instance of ReviewHandler.
-func NewReviewHandler(s ReviewService) *ReviewHandler {
-	return &ReviewHandler{service: s}
+func NewReviewHandler(s ReviewService, p authz.Policy) *ReviewHandler {
+	return &ReviewHandler{service: s, policy: p}
 }
 
-// CreateReview creates a new review.
+// CreateReview creates a new review on behalf of the authenticated user.
 func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
 	var review models.Review
 
@@ -28,6 +32,13 @@ func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	review.UserID = userID
+
 	createdReview, err := h.service.CreateReview(&review)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -60,8 +71,15 @@ func (h *ReviewHandler) GetReviewByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(review)
 }
 
-// UpdateReview updates the details of an existing review.
+// UpdateReview updates the details of an existing review. Only its author may update it.
 func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reviewID, err := strconv.Atoi(vars["reviewID"])
+	if err != nil {
+		http.Error(w, "Invalid review ID", http.StatusBadRequest)
+		return
+	}
+
 	var review models.Review
 
 	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
@@ -69,6 +87,15 @@ func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, ok := h.authorizeReview(w, r, reviewID)
+	if !ok {
+		return
+	}
+	// The path identifies the review; its author and salon cannot be changed.
+	review.ReviewID = existing.ReviewID
+	review.UserID = existing.UserID
+	review.SalonID = existing.SalonID
+
 	updatedReview, err := h.service.UpdateReview(&review)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -78,7 +105,7 @@ func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updatedReview)
 }
 
-// DeleteReview deletes a review by its unique ID.
+// DeleteReview deletes a review by its unique ID. Only its author may delete it.
 func (h *ReviewHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	reviewID, err := strconv.Atoi(vars["reviewID"])
@@ -87,6 +114,10 @@ func (h *ReviewHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := h.authorizeReview(w, r, reviewID); !ok {
+		return
+	}
+
 	if err := h.service.DeleteReview(reviewID); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -95,7 +126,7 @@ func (h *ReviewHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// ListReviewsBySalonID retrieves all reviews for a specific salon by salon ID.
+// ListReviewsBySalonID retrieves a page of the reviews for a specific salon by salon ID.
 func (h *ReviewHandler) ListReviewsBySalonID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	salonID, err := strconv.Atoi(vars["salonID"])
@@ -104,7 +135,13 @@ func (h *ReviewHandler) ListReviewsBySalonID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	reviews, err := h.service.ListReviewsBySalonID(salonID)
+	page, err := pagination.Parse(r, reviewList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reviews, err := h.service.ListReviewsBySalonID(salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -113,7 +150,7 @@ func (h *ReviewHandler) ListReviewsBySalonID(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(reviews)
 }
 
-// ListReviewsByUserID retrieves all reviews posted by a specific user by user ID.
+// ListReviewsByUserID retrieves a page of the reviews posted by a specific user by user ID.
 func (h *ReviewHandler) ListReviewsByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["userID"])
@@ -122,7 +159,13 @@ func (h *ReviewHandler) ListReviewsByUserID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	reviews, err := h.service.ListReviewsByUserID(userID)
+	page, err := pagination.Parse(r, reviewList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reviews, err := h.service.ListReviewsByUserID(userID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -131,7 +174,7 @@ func (h *ReviewHandler) ListReviewsByUserID(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(reviews)
 }
 
-// ListReviewsByRating retrieves all reviews with a specific rating.
+// ListReviewsByRating retrieves a page of the reviews with a specific rating.
 func (h *ReviewHandler) ListReviewsByRating(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	rating, err := strconv.Atoi(vars["rating"])
@@ -140,7 +183,13 @@ func (h *ReviewHandler) ListReviewsByRating(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	reviews, err := h.service.ListReviewsByRating(rating)
+	page, err := pagination.Parse(r, reviewList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reviews, err := h.service.ListReviewsByRating(rating, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -148,3 +197,25 @@ func (h *ReviewHandler) ListReviewsByRating(w http.ResponseWriter, r *http.Reque
 
 	json.NewEncoder(w).Encode(reviews)
 }
+
+// authorizeReview loads a review and checks that the caller may modify it.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *ReviewHandler) authorizeReview(w http.ResponseWriter, r *http.Request, reviewID int) (*models.Review, bool) {
+	review, err := h.service.GetReviewByID(reviewID)
+	if err != nil {
+		switch err {
+		case ErrReviewNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeReview(r.Context(), review); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return review, true
+}
@@ -3,9 +3,12 @@ package review
 import (
 	"bookmysalon/models"
 	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/pagination"
 	"database/sql"
 	"errors"
 	"log"
+
+	"github.com/lib/pq"
 )
 
 var (
@@ -19,6 +22,21 @@ var (
 	ErrorReviewListRating = "Error listing reviews by rating"
 )
 
+// reviewList describes how lists of reviews can be sorted and filtered.
+var reviewList = pagination.Spec{
+	ID: pagination.Field{Column: "review_id", JSON: "review_id"},
+	Sorts: map[string]pagination.Field{
+		"id":          {Column: "review_id", JSON: "review_id"},
+		"date_posted": {Column: "date_posted", JSON: "date_posted"},
+		"rating":      {Column: "rating", JSON: "rating"},
+	},
+	DefaultSort: "-date_posted",
+	Filters: map[string]pagination.Filter{
+		"min_rating": {Column: "rating", Op: ">=", Type: pagination.Int},
+		"max_rating": {Column: "rating", Op: "<=", Type: pagination.Int},
+	},
+}
+
 type reviewServiceImpl struct {
 	db *sql.DB
 }
@@ -48,6 +66,9 @@ func (s *reviewServiceImpl) CreateReview(review *models.Review) (*models.Review,
 	}
 
 	review.ReviewID = reviewID
+	if err := s.refreshRatings(review.SalonID); err != nil {
+		return nil, err
+	}
 	return review, nil
 }
 
@@ -82,92 +103,82 @@ func (s *reviewServiceImpl) UpdateReview(review *models.Review) (*models.Review,
 		WHERE review_id=$6
 	`
 
-	_, err := s.db.Exec(query, review.UserID, review.SalonID, review.Rating, review.Comment, review.DatePosted, review.ReviewID)
+	existing, err := s.GetReviewByID(review.ReviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(query, review.UserID, review.SalonID, review.Rating, review.Comment, review.DatePosted, review.ReviewID)
 	if err != nil {
 		log.Printf("%s: %v", ErrorReviewUpdate, err)
 		return nil, err
 	}
 
+	// The review may have moved to another salon.
+	if err := s.refreshRatings(existing.SalonID, review.SalonID); err != nil {
+		return nil, err
+	}
 	return review, nil
 }
 
 func (s *reviewServiceImpl) DeleteReview(reviewID int) error {
-	const query = `DELETE FROM reviews WHERE review_id=$1`
+	const query = `DELETE FROM reviews WHERE review_id=$1 RETURNING salon_id`
 
-	_, err := s.db.Exec(query, reviewID)
-	if err != nil {
+	var salonID int
+	if err := s.db.QueryRow(query, reviewID).Scan(&salonID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
 		log.Printf("%s: %v", ErrorReviewDelete, err)
 		return err
 	}
 
-	return nil
+	return s.refreshRatings(salonID)
 }
 
-func (s *reviewServiceImpl) ListReviewsBySalonID(salonID int) ([]*models.Review, error) {
+// refreshRatings works out the average rating of salons again from their
+// reviews. Salons without reviews are rated 0.
+func (s *reviewServiceImpl) refreshRatings(salonIDs ...int) error {
 	const query = `
-		SELECT review_id, user_id, salon_id, rating, comment, date_posted
-		FROM reviews WHERE salon_id=$1
+		UPDATE salons s SET average_rating = COALESCE((SELECT AVG(r.rating) FROM reviews r WHERE r.salon_id = s.salon_id), 0)
+		WHERE s.salon_id = ANY($1)
 	`
 
-	rows, err := s.db.Query(query, salonID)
-	if err != nil {
-		log.Printf("%s: %v", ErrorReviewListSalon, err)
-		return nil, err
+	ids := pq.Int64Array{}
+	for _, id := range salonIDs {
+		ids = append(ids, int64(id))
 	}
-	defer rows.Close()
-
-	var reviews []*models.Review
-	for rows.Next() {
-		var review models.Review
-		if err := rows.Scan(
-			&review.ReviewID, &review.UserID, &review.SalonID, &review.Rating, &review.Comment, &review.DatePosted,
-		); err != nil {
-			log.Printf("Error scanning review row: %v", err)
-			return nil, err
-		}
-		reviews = append(reviews, &review)
+	if _, err := s.db.Exec(query, ids); err != nil {
+		log.Printf("Error updating salon rating: %v", err)
+		return err
 	}
-
-	return reviews, nil
+	return nil
 }
 
-func (s *reviewServiceImpl) ListReviewsByUserID(userID int) ([]*models.Review, error) {
-	const query = `
-		SELECT review_id, user_id, salon_id, rating, comment, date_posted
-		FROM reviews WHERE user_id=$1
-	`
-
-	rows, err := s.db.Query(query, userID)
-	if err != nil {
-		log.Printf("%s: %v", ErrorReviewListUser, err)
-		return nil, err
-	}
-	defer rows.Close()
+func (s *reviewServiceImpl) ListReviewsBySalonID(salonID int, page pagination.Query) (*pagination.Page[*models.Review], error) {
+	return s.listReviews(page, ErrorReviewListSalon, "salon_id=$1", salonID)
+}
 
-	var reviews []*models.Review
-	for rows.Next() {
-		var review models.Review
-		if err := rows.Scan(
-			&review.ReviewID, &review.UserID, &review.SalonID, &review.Rating, &review.Comment, &review.DatePosted,
-		); err != nil {
-			log.Printf("Error scanning review row: %v", err)
-			return nil, err
-		}
-		reviews = append(reviews, &review)
-	}
+func (s *reviewServiceImpl) ListReviewsByUserID(userID int, page pagination.Query) (*pagination.Page[*models.Review], error) {
+	return s.listReviews(page, ErrorReviewListUser, "user_id=$1", userID)
+}
 
-	return reviews, nil
+func (s *reviewServiceImpl) ListReviewsByRating(rating int, page pagination.Query) (*pagination.Page[*models.Review], error) {
+	return s.listReviews(page, ErrorReviewListRating, "rating=$1", rating)
 }
 
-func (s *reviewServiceImpl) ListReviewsByRating(rating int) ([]*models.Review, error) {
-	const query = `
+// listReviews retrieves a page of the reviews matching a condition with the
+// given parameters, logging failures with errorMessage.
+func (s *reviewServiceImpl) listReviews(page pagination.Query, errorMessage, where string, params ...interface{}) (*pagination.Page[*models.Review], error) {
+	const selectFrom = `
 		SELECT review_id, user_id, salon_id, rating, comment, date_posted
-		FROM reviews WHERE rating=$1
+		FROM reviews
 	`
 
-	rows, err := s.db.Query(query, rating)
+	query, args := page.SQL(selectFrom, where, params...)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		log.Printf("%s: %v", ErrorReviewListRating, err)
+		log.Printf("%s: %v", errorMessage, err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -183,6 +194,9 @@ func (s *reviewServiceImpl) ListReviewsByRating(rating int) ([]*models.Review, e
 		}
 		reviews = append(reviews, &review)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return reviews, nil
+	return pagination.NewPage(reviews, page)
 }
@@ -10,7 +10,10 @@ package salon
 
 import (
 	"bookmysalon/models"
-	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/pagination"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -20,19 +23,21 @@ import (
 
 type SalonHandler struct {
 	service SalonService
+	policy  authz.Policy
 }
 
-func NewSalonHandler(s SalonService) *SalonHandler {
-	return &SalonHandler{service: s}
+func NewSalonHandler(s SalonService, p authz.Policy) *SalonHandler {
+	return &SalonHandler{service: s, policy: p}
 }
 
 // @Summary Create a new salon
-// @Description Create a new salon with the input payload
+// @Description Create a new salon with the input payload. The caller becomes its owner. The salon starts as a draft and goes live once submitted and approved.
 // @Accept  json
 // @Produce  json
 // @Param salon body models.Salon true "Create salon"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /salon [post]
 func (h *SalonHandler) CreateSalon(w http.ResponseWriter, r *http.Request) {
@@ -43,9 +48,20 @@ func (h *SalonHandler) CreateSalon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	salonID, err := h.service.AddSalon(salon)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	salonID, err := h.service.AddSalon(salon, userID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrInvalidTimezone, ErrInvalidCoordinates:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -53,12 +69,15 @@ func (h *SalonHandler) CreateSalon(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update salon details
-// @Description Update details of an existing salon
+// @Description Update details of an existing salon. Salons under review cannot be changed by their owners.
 // @Accept  json
 // @Produce  json
 // @Param salon body models.Salon true "Update Salon"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Salon Under Review"
 // @Failure 500 {object} map[string]string
 // @Router /salon/update [put]
 func (h *SalonHandler) UpdateSalonDetails(w http.ResponseWriter, r *http.Request) {
@@ -69,27 +88,33 @@ func (h *SalonHandler) UpdateSalonDetails(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := h.service.UpdateSalon(salon); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := h.policy.AuthorizeSalon(r.Context(), salon.SalonID); err != nil {
+		authz.WriteError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
-
-func Authenticate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		tokenString := authHeader[len("Bearer "):]
+	// Admins review a submitted salon as it was submitted.
+	existing, err := h.service.GetSalonByID(salon.SalonID)
+	if err != nil {
+		writeSalonStatusError(w, err)
+		return
+	}
+	if existing.Status == models.SalonStatusSubmitted && !middleware.HasRole(r.Context(), models.RoleAdmin) {
+		writeSalonStatusError(w, ErrSalonUnderReview)
+		return
+	}
 
-		_, err := jwt.VerifyToken(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+	if err := h.service.UpdateSalon(salon); err != nil {
+		switch err {
+		case ErrInvalidTimezone, ErrInvalidCoordinates:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
+		return
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	w.WriteHeader(http.StatusOK)
 }
 
 // @Summary Delete a salon
@@ -99,6 +124,7 @@ func Authenticate(next http.Handler) http.Handler {
 // @Param salonID path int true "Salon ID"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string "Salon Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /salon/{salonID} [delete]
@@ -110,6 +136,11 @@ func (h *SalonHandler) DeleteSalon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
 	if err := h.service.DeleteSalon(salonID); err != nil {
 		// Depending on the nature of the error, you might want to differentiate
 		// between a "Salon Not Found" error and other types of errors.
@@ -121,7 +152,7 @@ func (h *SalonHandler) DeleteSalon(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get salon details
-// @Description Get details of a salon by ID
+// @Description Get details of a salon by ID. Salons that are not approved are only shown to their owners, staff and admins.
 // @Accept  json
 // @Produce  json
 // @Param salonID path int true "Salon ID"
@@ -148,20 +179,34 @@ func (h *SalonHandler) GetSalonDetails(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if !h.visibleSalon(r, salon) {
+		http.Error(w, ErrSalonNotFound.Error(), http.StatusNotFound)
+		return
+	}
 
 	// Send the retrieved salon details as JSON
 	json.NewEncoder(w).Encode(salon)
 }
 
 // @Summary List all salons
-// @Description Retrieve a list of all salons
+// @Description Retrieve a list of all approved salons
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Salon
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name, average_rating or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Salon]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /salons [get]
 func (h *SalonHandler) ListAllSalons(w http.ResponseWriter, r *http.Request) {
-	salons, err := h.service.ListSalons()
+	page, err := pagination.Parse(r, salonList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	salons, err := h.service.ListSalons(page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -171,6 +216,62 @@ func (h *SalonHandler) ListAllSalons(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(salons)
 }
 
+// @Summary Search salons near a location
+// @Description Retrieve the salons within a radius of a location, nearest first. Salons without coordinates are not included.
+// @Accept  json
+// @Produce  json
+// @Param lat query number true "Latitude"
+// @Param lng query number true "Longitude"
+// @Param radius_km query number false "Radius in kilometers, default 10, at most 100"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "distance, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.NearbySalon]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salons/nearby [get]
+func (h *SalonHandler) ListNearbySalons(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		http.Error(w, "Invalid latitude", http.StatusBadRequest)
+		return
+	}
+	lng, err := strconv.ParseFloat(query.Get("lng"), 64)
+	if err != nil {
+		http.Error(w, "Invalid longitude", http.StatusBadRequest)
+		return
+	}
+
+	radiusKm := DefaultNearbyRadiusKm
+	if value := query.Get("radius_km"); value != "" {
+		if radiusKm, err = strconv.ParseFloat(value, 64); err != nil {
+			http.Error(w, "Invalid radius", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := pagination.Parse(r, nearbySalonList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	salons, err := h.service.ListSalonsNearby(geo.Point{Lat: lat, Lng: lng}, radiusKm, page)
+	if err != nil {
+		switch err {
+		case ErrInvalidCoordinates, ErrInvalidRadius:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(salons)
+}
+
 // @Summary Add a new service
 // @Description Create a new service with the input payload
 // @Accept  json
@@ -178,6 +279,7 @@ func (h *SalonHandler) ListAllSalons(w http.ResponseWriter, r *http.Request) {
 // @Param service body models.Service true "Create Service"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /service [post]
 func (h *SalonHandler) AddService(w http.ResponseWriter, r *http.Request) {
@@ -188,9 +290,14 @@ func (h *SalonHandler) AddService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.policy.AuthorizeSalon(r.Context(), service.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
 	serviceID, err := h.service.AddService(service)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeCatalogError(w, err)
 		return
 	}
 
@@ -204,6 +311,8 @@ func (h *SalonHandler) AddService(w http.ResponseWriter, r *http.Request) {
 // @Param service body models.Service true "Update Service"
 // @Success 200
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /service/update [put]
 func (h *SalonHandler) UpdateServiceDetails(w http.ResponseWriter, r *http.Request) {
@@ -214,8 +323,15 @@ func (h *SalonHandler) UpdateServiceDetails(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	existing, ok := h.authorizeService(w, r, service.ServiceID)
+	if !ok {
+		return
+	}
+	// A service cannot be moved to another salon through an update.
+	service.SalonID = existing.SalonID
+
 	if err := h.service.UpdateService(service); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeCatalogError(w, err)
 		return
 	}
 
@@ -229,6 +345,8 @@ func (h *SalonHandler) UpdateServiceDetails(w http.ResponseWriter, r *http.Reque
 // @Param serviceID path int true "Service ID"
 // @Success 200
 // @Failure 400 {object} map[string]string "Invalid Service ID"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /service/{serviceID} [delete]
 func (h *SalonHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
@@ -239,6 +357,10 @@ func (h *SalonHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := h.authorizeService(w, r, serviceID); !ok {
+		return
+	}
+
 	if err := h.service.DeleteService(serviceID); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -254,6 +376,7 @@ func (h *SalonHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
 // @Param serviceID path int true "Service ID"
 // @Success 200 {object} models.Service
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /service/{serviceID} [get]
 func (h *SalonHandler) GetServiceDetails(w http.ResponseWriter, r *http.Request) {
@@ -266,7 +389,15 @@ func (h *SalonHandler) GetServiceDetails(w http.ResponseWriter, r *http.Request)
 
 	service, err := h.service.GetServiceByID(serviceID)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		switch err {
+		case ErrServiceNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !h.showCatalog(w, r, service.SalonID) {
 		return
 	}
 
@@ -278,8 +409,12 @@ func (h *SalonHandler) GetServiceDetails(w http.ResponseWriter, r *http.Request)
 // @Accept  json
 // @Produce  json
 // @Param salonID path int true "Salon ID"
-// @Success 200 {array} models.Service
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name, price or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Service]
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
 // @Failure 500 {object} map[string]string
 // @Router /salon/{salonID}/services [get]
 func (h *SalonHandler) GetServicesBySalon(w http.ResponseWriter, r *http.Request) {
@@ -290,7 +425,16 @@ func (h *SalonHandler) GetServicesBySalon(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	services, err := h.service.ListServicesBySalon(salonID)
+	page, err := pagination.Parse(r, serviceList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.showCatalog(w, r, salonID) {
+		return
+	}
+
+	services, err := h.service.ListServicesBySalon(salonID, page)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -324,3 +468,118 @@ func (h *SalonHandler) GetSalonAverageRating(w http.ResponseWriter, r *http.Requ
 
 	json.NewEncoder(w).Encode(map[string]float64{"average_rating": avgRating})
 }
+
+// authorizeService loads a service and checks that the caller may manage its salon.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *SalonHandler) authorizeService(w http.ResponseWriter, r *http.Request, serviceID int) (*models.Service, bool) {
+	service, err := h.service.GetServiceByID(serviceID)
+	if err != nil {
+		switch err {
+		case ErrServiceNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), service.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return service, true
+}
+
+// @Summary Add a salon member
+// @Description Add a user to a salon as an owner or staff member
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param member body map[string]interface{} true "User ID and role (owner or staff)"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Or User Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/members [post]
+func (h *SalonHandler) AddSalonMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var member struct {
+		UserID int    `json:"user_id"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.AddSalonMember(salonID, member.UserID, member.Role); err != nil {
+		switch err {
+		case ErrInvalidMemberRole:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrSalonNotFound, ErrUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Remove a salon member
+// @Description Remove a user from the owners and staff of a salon
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param userID path int true "User ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Member Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/members/{userID} [delete]
+func (h *SalonHandler) RemoveSalonMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.RemoveSalonMember(salonID, userID); err != nil {
+		switch err {
+		case ErrSalonMemberNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,272 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Get the opening hours of a salon
+// @Description Retrieve the weekly opening hours of a salon, in its timezone
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {array} models.OpeningHours
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours [get]
+func (h *SalonHandler) GetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	hours, err := h.service.GetOpeningHours(salonID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(hours)
+}
+
+// @Summary Set the opening hours of a salon
+// @Description Replace the weekly opening hours of a salon. A day can have several intervals; days without interval are closed.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param hours body []models.OpeningHours true "Weekly opening hours"
+// @Success 200 {array} models.OpeningHours
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours [put]
+func (h *SalonHandler) SetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var hours []models.OpeningHours
+	if err := json.NewDecoder(r.Body).Decode(&hours); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.SetOpeningHours(salonID, hours); err != nil {
+		writeHoursError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetOpeningHours(salonID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Add an hours exception
+// @Description Add a holiday, special hours or a temporary closure. Leave out the times to close the salon on the dates.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param exception body models.HoursException true "Create Hours Exception"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours/exceptions [post]
+func (h *SalonHandler) AddHoursException(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var exception models.HoursException
+	if err := json.NewDecoder(r.Body).Decode(&exception); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	exception.SalonID = salonID
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	exceptionID, err := h.service.AddHoursException(exception)
+	if err != nil {
+		writeHoursError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"exception_id": exceptionID})
+}
+
+// @Summary Delete an hours exception
+// @Description Delete a holiday, special hours or a temporary closure
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param exceptionID path int true "Exception ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Exception Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours/exceptions/{exceptionID} [delete]
+func (h *SalonHandler) DeleteHoursException(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	exceptionID, err := strconv.Atoi(vars["exceptionID"])
+	if err != nil {
+		http.Error(w, "Invalid exception ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	exception, err := h.service.GetHoursExceptionByID(exceptionID)
+	if err != nil {
+		writeHoursError(w, err)
+		return
+	}
+	if exception.SalonID != salonID {
+		writeHoursError(w, ErrHoursExceptionNotFound)
+		return
+	}
+
+	if err := h.service.DeleteHoursException(exceptionID); err != nil {
+		writeHoursError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary List the hours exceptions of a salon
+// @Description Retrieve the holidays, special hours and closures touching a date range. Defaults to the coming year.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param from query string false "First date (YYYY-MM-DD)"
+// @Param to query string false "Last date (YYYY-MM-DD)"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "start_date, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.HoursException]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours/exceptions [get]
+func (h *SalonHandler) ListHoursExceptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	from, to := dateRangeParams(r, 365)
+	page, err := pagination.Parse(r, hoursExceptionList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exceptions, err := h.service.ListHoursExceptions(salonID, from, to, page)
+	if err != nil {
+		writeHoursError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(exceptions)
+}
+
+// @Summary Get the effective hours of a salon
+// @Description Work out the opening hours on each date of a range, taking holidays and closures into account. Defaults to the coming week.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param from query string false "First date (YYYY-MM-DD)"
+// @Param to query string false "Last date (YYYY-MM-DD)"
+// @Success 200 {array} models.DayHours
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/hours/effective [get]
+func (h *SalonHandler) GetEffectiveHours(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	from, to := dateRangeParams(r, 6)
+	days, err := h.service.GetEffectiveHours(salonID, from, to)
+	if err != nil {
+		writeHoursError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(days)
+}
+
+// dateRangeParams reads the from and to query parameters. from defaults to
+// today and to to the given number of days after from.
+func dateRangeParams(r *http.Request, defaultDays int) (string, string) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+
+	if from == "" {
+		from = time.Now().Format(schedule.DateLayout)
+	}
+	if to == "" {
+		if start, err := time.Parse(schedule.DateLayout, from); err == nil {
+			to = start.AddDate(0, 0, defaultDays).Format(schedule.DateLayout)
+		}
+	}
+	return from, to
+}
+
+// writeHoursError writes the HTTP response matching an error of the opening hours methods.
+func writeHoursError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidWeekday, ErrInvalidDate, ErrInvalidDateRange, ErrDateRangeTooLong, ErrIncompleteHours,
+		schedule.ErrInvalidClock, schedule.ErrInvalidInterval:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrSalonNotFound, ErrHoursExceptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
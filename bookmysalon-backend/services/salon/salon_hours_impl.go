@@ -0,0 +1,282 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
+	"bookmysalon/pkg/schedule"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// defaultTimezone is the timezone of salons that did not set one.
+const defaultTimezone = "UTC"
+
+// MaxEffectiveHoursDays bounds the date range of GetEffectiveHours.
+const MaxEffectiveHoursDays = 92
+
+// hoursExceptionList describes how lists of hours exceptions can be sorted.
+var hoursExceptionList = pagination.Spec{
+	ID: pagination.Field{Column: "exception_id", JSON: "exception_id"},
+	Sorts: map[string]pagination.Field{
+		"start_date": {Column: "start_date", JSON: "start_date"},
+	},
+	DefaultSort: "start_date",
+}
+
+var (
+	ErrInvalidWeekday         = errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	ErrInvalidDate            = errors.New("dates must be given as YYYY-MM-DD")
+	ErrInvalidDateRange       = errors.New("end date must not be before start date")
+	ErrDateRangeTooLong       = errors.New("date range is too long")
+	ErrIncompleteHours        = errors.New("opening and closing time must be given together")
+	ErrHoursExceptionNotFound = errors.New("hours exception not found")
+)
+
+// GetOpeningHours retrieves the weekly opening hours of a salon.
+func (s *salonServiceImpl) GetOpeningHours(salonID int) ([]models.OpeningHours, error) {
+	const query = `
+		SELECT weekday, TO_CHAR(opens_at, 'HH24:MI'), TO_CHAR(closes_at, 'HH24:MI')
+		FROM salon_opening_hours WHERE salon_id=$1
+		ORDER BY weekday, opens_at
+	`
+
+	rows, err := s.db.Query(query, salonID)
+	if err != nil {
+		log.Printf("Error retrieving opening hours: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []models.OpeningHours{}
+	for rows.Next() {
+		var h models.OpeningHours
+		if err := rows.Scan(&h.Weekday, &h.OpensAt, &h.ClosesAt); err != nil {
+			log.Printf("Error scanning opening hours row: %v", err)
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+
+	return hours, rows.Err()
+}
+
+// SetOpeningHours replaces the weekly opening hours of a salon.
+func (s *salonServiceImpl) SetOpeningHours(salonID int, hours []models.OpeningHours) error {
+	for _, h := range hours {
+		if h.Weekday < 0 || h.Weekday > 6 {
+			return ErrInvalidWeekday
+		}
+		if _, err := schedule.NewInterval(h.OpensAt, h.ClosesAt); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error setting opening hours: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM salon_opening_hours WHERE salon_id=$1`, salonID); err != nil {
+		log.Printf("Error clearing opening hours: %v", err)
+		return err
+	}
+
+	const query = `INSERT INTO salon_opening_hours(salon_id, weekday, opens_at, closes_at) VALUES($1, $2, $3, $4)`
+	for _, h := range hours {
+		if _, err := tx.Exec(query, salonID, h.Weekday, h.OpensAt, h.ClosesAt); err != nil {
+			log.Printf("Error inserting opening hours: %v", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error setting opening hours: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AddHoursException adds a holiday, special hours or a closure and returns its ID.
+func (s *salonServiceImpl) AddHoursException(exception models.HoursException) (int, error) {
+	if exception.EndDate == "" {
+		exception.EndDate = exception.StartDate
+	}
+	if err := validateHoursException(exception); err != nil {
+		return 0, err
+	}
+
+	const query = `
+		INSERT INTO salon_hours_exceptions(salon_id, start_date, end_date, opens_at, closes_at, reason)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING exception_id
+	`
+
+	var exceptionID int
+	err := s.db.QueryRow(query, exception.SalonID, exception.StartDate, exception.EndDate, exception.OpensAt, exception.ClosesAt, exception.Reason).Scan(&exceptionID)
+	if err != nil {
+		log.Printf("Error inserting hours exception: %v", err)
+		return 0, err
+	}
+
+	return exceptionID, nil
+}
+
+// GetHoursExceptionByID retrieves an hours exception by its ID.
+func (s *salonServiceImpl) GetHoursExceptionByID(exceptionID int) (*models.HoursException, error) {
+	const query = `
+		SELECT exception_id, salon_id, TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'),
+			TO_CHAR(opens_at, 'HH24:MI'), TO_CHAR(closes_at, 'HH24:MI'), COALESCE(reason, '')
+		FROM salon_hours_exceptions WHERE exception_id=$1
+	`
+
+	var e models.HoursException
+	err := s.db.QueryRow(query, exceptionID).Scan(&e.ExceptionID, &e.SalonID, &e.StartDate, &e.EndDate, &e.OpensAt, &e.ClosesAt, &e.Reason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHoursExceptionNotFound
+		}
+		log.Printf("Error retrieving hours exception by ID: %v", err)
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// DeleteHoursException deletes an hours exception by its ID.
+func (s *salonServiceImpl) DeleteHoursException(exceptionID int) error {
+	const query = `DELETE FROM salon_hours_exceptions WHERE exception_id=$1`
+
+	result, err := s.db.Exec(query, exceptionID)
+	if err != nil {
+		log.Printf("Error deleting hours exception: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrHoursExceptionNotFound
+	}
+
+	return nil
+}
+
+// ListHoursExceptions retrieves a page of the hours exceptions of a salon touching the dates from..to.
+func (s *salonServiceImpl) ListHoursExceptions(salonID int, from, to string, page pagination.Query) (*pagination.Page[models.HoursException], error) {
+	if _, _, err := parseDateRange(from, to); err != nil {
+		return nil, err
+	}
+
+	const selectFrom = `
+		SELECT exception_id, salon_id, TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'),
+			TO_CHAR(opens_at, 'HH24:MI'), TO_CHAR(closes_at, 'HH24:MI'), COALESCE(reason, '')
+		FROM salon_hours_exceptions
+	`
+
+	query, args := page.SQL(selectFrom, "salon_id=$1 AND end_date >= $2 AND start_date <= $3", salonID, from, to)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error listing hours exceptions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exceptions []models.HoursException
+	for rows.Next() {
+		var e models.HoursException
+		if err := rows.Scan(&e.ExceptionID, &e.SalonID, &e.StartDate, &e.EndDate, &e.OpensAt, &e.ClosesAt, &e.Reason); err != nil {
+			log.Printf("Error scanning hours exception row: %v", err)
+			return nil, err
+		}
+		exceptions = append(exceptions, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(exceptions, page)
+}
+
+// GetEffectiveHours works out the hours a salon is open on each date from..to,
+// taking exceptions into account.
+func (s *salonServiceImpl) GetEffectiveHours(salonID int, from, to string) ([]models.DayHours, error) {
+	loc, err := schedule.Location(s.db, salonID)
+	if err != nil {
+		if err == schedule.ErrSalonNotFound {
+			return nil, ErrSalonNotFound
+		}
+		log.Printf("Error retrieving salon timezone: %v", err)
+		return nil, err
+	}
+
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if end.Sub(start) >= MaxEffectiveHoursDays*24*time.Hour {
+		return nil, ErrDateRangeTooLong
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+
+	cal, err := schedule.Load(s.db, salonID, start, end)
+	if err != nil {
+		log.Printf("Error loading salon calendar: %v", err)
+		return nil, err
+	}
+
+	days := []models.DayHours{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		hours := []models.OpeningHours{}
+		for _, interval := range cal.HoursOn(day) {
+			hours = append(hours, models.OpeningHours{
+				Weekday:  int(day.Weekday()),
+				OpensAt:  schedule.FormatClock(interval.Opens),
+				ClosesAt: schedule.FormatClock(interval.Closes),
+			})
+		}
+		days = append(days, models.DayHours{Date: day.Format(schedule.DateLayout), Hours: hours})
+	}
+
+	return days, nil
+}
+
+// validateHoursException checks the dates and times of an exception.
+func validateHoursException(e models.HoursException) error {
+	if _, _, err := parseDateRange(e.StartDate, e.EndDate); err != nil {
+		return err
+	}
+
+	if (e.OpensAt == nil) != (e.ClosesAt == nil) {
+		return ErrIncompleteHours
+	}
+	if e.OpensAt != nil {
+		if _, err := schedule.NewInterval(*e.OpensAt, *e.ClosesAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDateRange parses two inclusive dates and checks their order.
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse(schedule.DateLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidDate
+	}
+	end, err := time.Parse(schedule.DateLayout, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidDate
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, ErrInvalidDateRange
+	}
+	return start, end, nil
+}
+
+func isValidTimezone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil && name != "Local"
+}
@@ -0,0 +1,122 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/pagination"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Limits of the nearby search.
+const (
+	DefaultNearbyRadiusKm = 10.0
+	MaxNearbyRadiusKm     = 100.0
+)
+
+// nearbySalonList describes how the results of the nearby search can be sorted and filtered.
+var nearbySalonList = pagination.Spec{
+	ID: pagination.Field{Column: "salon_id", JSON: "salon_id"},
+	Sorts: map[string]pagination.Field{
+		"distance": {Column: "distance_km", JSON: "distance_km"},
+	},
+	DefaultSort: "distance",
+	Filters: map[string]pagination.Filter{
+		"min_rating": {Column: "average_rating", Op: ">=", Type: pagination.Float},
+	},
+}
+
+var (
+	ErrInvalidCoordinates = errors.New("latitude and longitude must be given together and lie within their ranges")
+	ErrInvalidRadius      = errors.New("radius must be greater than 0 and at most 100 km")
+)
+
+// ListSalonsNearby retrieves a page of the salons within radiusKm of center, nearest first.
+func (s *salonServiceImpl) ListSalonsNearby(center geo.Point, radiusKm float64, page pagination.Query) (*pagination.Page[models.NearbySalon], error) {
+	if !center.Valid() {
+		return nil, ErrInvalidCoordinates
+	}
+	if !(radiusKm > 0 && radiusKm <= MaxNearbyRadiusKm) {
+		return nil, ErrInvalidRadius
+	}
+
+	// The bounding box lets the index narrow down the candidates; the
+	// haversine distance then decides.
+	selectFrom := `
+		SELECT * FROM (
+			SELECT ` + salonColumns + `, ` + geo.DistanceSQL("latitude", "longitude", "$1", "$2") + ` AS distance_km
+			FROM salons
+			WHERE latitude BETWEEN $3 AND $4 AND longitude BETWEEN $5 AND $6 AND status = '` + models.SalonStatusApproved + `'
+		) nearby
+	`
+
+	box := geo.BoundingBox(center, radiusKm)
+	query, args := page.SQL(selectFrom, "distance_km <= $7", center.Lat, center.Lng, box.MinLat, box.MaxLat, box.MinLng, box.MaxLng, radiusKm)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error listing nearby salons: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var salons []models.NearbySalon
+	for rows.Next() {
+		var salon models.NearbySalon
+		if err := rows.Scan(append(salonFields(&salon.Salon), &salon.DistanceKm)...); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			return nil, err
+		}
+		salons = append(salons, salon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(salons, page)
+}
+
+// locate validates the coordinates of a salon, or fills them in from its
+// structured address. A salon whose address cannot be geocoded is saved
+// without coordinates, so it is only left out of the nearby search.
+func (s *salonServiceImpl) locate(salon *models.Salon) error {
+	if salon.Latitude != nil || salon.Longitude != nil {
+		if salon.Latitude == nil || salon.Longitude == nil {
+			return ErrInvalidCoordinates
+		}
+		if !(geo.Point{Lat: *salon.Latitude, Lng: *salon.Longitude}).Valid() {
+			return ErrInvalidCoordinates
+		}
+		return nil
+	}
+
+	address := geo.Address{Street: salon.Street, City: salon.City, PostalCode: salon.PostalCode, Country: salon.Country}
+	if s.geocoder == nil || address.IsEmpty() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	point, err := s.geocoder.Geocode(ctx, address)
+	if err != nil {
+		if err != geo.ErrNoGeocoder {
+			log.Printf("Error geocoding salon address %q: %v", address.String(), err)
+		}
+		return nil
+	}
+
+	salon.Latitude = &point.Lat
+	salon.Longitude = &point.Lng
+	return nil
+}
+
+// salonFields returns the scan destinations matching salonColumns.
+func salonFields(salon *models.Salon) []interface{} {
+	return []interface{}{
+		&salon.SalonID, &salon.Name, &salon.Address, &salon.Street, &salon.City, &salon.PostalCode, &salon.Country,
+		&salon.Latitude, &salon.Longitude, &salon.ContactDetails, &salon.Photos, &salon.AverageRating, &salon.Timezone,
+		&salon.Status, &salon.StatusReason,
+	}
+}
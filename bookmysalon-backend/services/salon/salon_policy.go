@@ -0,0 +1,70 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+var ErrInvalidPolicy = errors.New("notice and confirmation window must not be negative, the late fee must be between 0 and 100 percent and the reschedule limit must not be negative")
+
+// GetSalonPolicy retrieves the cancellation and rescheduling policy of a
+// salon. Salons that never set one get the zero policy.
+func (s *salonServiceImpl) GetSalonPolicy(salonID int) (*models.SalonPolicy, error) {
+	const query = `
+		SELECT s.salon_id, COALESCE(p.free_cancellation_notice, INTERVAL '0'), COALESCE(p.late_cancellation_fee_percent, 0),
+			p.max_reschedules, COALESCE(p.confirmation_window, INTERVAL '0')
+		FROM salons s LEFT JOIN salon_policies p ON p.salon_id = s.salon_id
+		WHERE s.salon_id=$1
+	`
+
+	var policy models.SalonPolicy
+	var maxReschedules sql.NullInt64
+	err := s.db.QueryRow(query, salonID).Scan(&policy.SalonID, &policy.FreeCancellationNotice, &policy.LateCancellationFeePercent,
+		&maxReschedules, &policy.ConfirmationWindow)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSalonNotFound
+		}
+		log.Printf("Error retrieving salon policy: %v", err)
+		return nil, err
+	}
+	if maxReschedules.Valid {
+		n := int(maxReschedules.Int64)
+		policy.MaxReschedules = &n
+	}
+
+	return &policy, nil
+}
+
+// SetSalonPolicy replaces the cancellation and rescheduling policy of a salon.
+func (s *salonServiceImpl) SetSalonPolicy(policy models.SalonPolicy) error {
+	if policy.FreeCancellationNotice < 0 || policy.ConfirmationWindow < 0 ||
+		policy.LateCancellationFeePercent < 0 || policy.LateCancellationFeePercent > 100 ||
+		(policy.MaxReschedules != nil && *policy.MaxReschedules < 0) {
+		return ErrInvalidPolicy
+	}
+
+	const query = `
+		INSERT INTO salon_policies(salon_id, free_cancellation_notice, late_cancellation_fee_percent, max_reschedules, confirmation_window)
+		SELECT salon_id, $2, $3, $4, $5 FROM salons WHERE salon_id=$1
+		ON CONFLICT (salon_id) DO UPDATE SET
+			free_cancellation_notice=EXCLUDED.free_cancellation_notice,
+			late_cancellation_fee_percent=EXCLUDED.late_cancellation_fee_percent,
+			max_reschedules=EXCLUDED.max_reschedules,
+			confirmation_window=EXCLUDED.confirmation_window,
+			updated_at=NOW()
+	`
+
+	result, err := s.db.Exec(query, policy.SalonID, policy.FreeCancellationNotice, policy.LateCancellationFeePercent, policy.MaxReschedules, policy.ConfirmationWindow)
+	if err != nil {
+		log.Printf("Error setting salon policy: %v", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSalonNotFound
+	}
+
+	return nil
+}
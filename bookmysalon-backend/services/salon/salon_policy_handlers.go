@@ -0,0 +1,93 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Get the policy of a salon
+// @Description Retrieve the cancellation and rescheduling policy of a salon
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {object} models.SalonPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/policy [get]
+func (h *SalonHandler) GetSalonPolicy(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.service.GetSalonPolicy(salonID)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(policy)
+}
+
+// @Summary Set the policy of a salon
+// @Description Replace the cancellation and rescheduling policy of a salon: the free cancellation notice, the late cancellation fee, the reschedule limit and the confirmation window. It applies to existing appointments too.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param policy body models.SalonPolicy true "Salon Policy"
+// @Success 200 {object} models.SalonPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/policy [put]
+func (h *SalonHandler) SetSalonPolicy(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var policy models.SalonPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	policy.SalonID = salonID
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.SetSalonPolicy(policy); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetSalonPolicy(salonID)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+func writePolicyError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidPolicy:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrSalonNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,15 @@
 package salon
 
-import "bookmysalon/models"
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/pagination"
+)
 
 // SalonService represents the interface for managing salons
 type SalonService interface {
-	// Add a new salon and return its ID or an error.
-	AddSalon(salon models.Salon) (int, error)
+	// Add a new salon owned by the given user and return its ID or an error.
+	AddSalon(salon models.Salon, ownerID int) (int, error)
 
 	// Update the details of an existing salon or return an error.
 	UpdateSalon(salon models.Salon) error
@@ -16,8 +20,20 @@ type SalonService interface {
 	// Retrieve a salon by its ID.
 	GetSalonByID(salonID int) (*models.Salon, error)
 
-	// List all salons in the system.
-	ListSalons() ([]models.Salon, error)
+	// List a page of the approved salons in the system.
+	ListSalons(page pagination.Query) (*pagination.Page[models.Salon], error)
+
+	// List a page of the salons in a lifecycle status.
+	ListSalonsByStatus(status string, page pagination.Query) (*pagination.Page[models.Salon], error)
+
+	// Move a salon to a new lifecycle status, recording the user making the change and their reason.
+	ChangeSalonStatus(salonID int, status, reason string, actorID int) (*models.Salon, error)
+
+	// List the status changes of a salon, oldest first.
+	ListSalonStatusHistory(salonID int) ([]models.SalonStatusChange, error)
+
+	// List a page of the salons within radiusKm of a location, nearest first.
+	ListSalonsNearby(center geo.Point, radiusKm float64, page pagination.Query) (*pagination.Page[models.NearbySalon], error)
 
 	// Add a new service for a salon and return its ID or an error.
 	AddService(service models.Service) (int, error)
@@ -31,9 +47,99 @@ type SalonService interface {
 	// Retrieve a service by its ID.
 	GetServiceByID(serviceID int) (*models.Service, error)
 
-	// List all services offered by a specific salon.
-	ListServicesBySalon(salonID int) ([]models.Service, error)
+	// List a page of the services offered by a specific salon.
+	ListServicesBySalon(salonID int, page pagination.Query) (*pagination.Page[models.Service], error)
+
+	// Add a category to the menu of a salon and return its ID.
+	AddCategory(category models.ServiceCategory) (int, error)
+
+	// Update a category; renaming it renames the category of its services too.
+	UpdateCategory(category models.ServiceCategory) error
+
+	// Delete a category, leaving its services without a category.
+	DeleteCategory(categoryID int) error
+
+	// Retrieve a category by its ID.
+	GetCategoryByID(categoryID int) (*models.ServiceCategory, error)
+
+	// List the categories of a salon in menu order.
+	ListCategoriesBySalon(salonID int) ([]models.ServiceCategory, error)
+
+	// Add a variant to a service and return its ID.
+	AddVariant(variant models.ServiceVariant) (int, error)
+
+	// Update the details of a variant.
+	UpdateVariant(variant models.ServiceVariant) error
+
+	// Delete a variant by its ID.
+	DeleteVariant(variantID int) error
+
+	// Retrieve a variant by its ID.
+	GetVariantByID(variantID int) (*models.ServiceVariant, error)
+
+	// Add an add-on to a service and return its ID.
+	AddAddOn(addOn models.ServiceAddOn) (int, error)
+
+	// Update the details of an add-on.
+	UpdateAddOn(addOn models.ServiceAddOn) error
+
+	// Delete an add-on by its ID.
+	DeleteAddOn(addOnID int) error
+
+	// Retrieve an add-on by its ID.
+	GetAddOnByID(addOnID int) (*models.ServiceAddOn, error)
 
 	// Get the average rating of a salon.
 	GetAverageRating(salonID int) (float64, error)
+
+	// Add a user to a salon as an owner or staff member.
+	AddSalonMember(salonID, userID int, role string) error
+
+	// Remove a user from the owners and staff of a salon.
+	RemoveSalonMember(salonID, userID int) error
+
+	// Retrieve the weekly opening hours of a salon.
+	GetOpeningHours(salonID int) ([]models.OpeningHours, error)
+
+	// Replace the weekly opening hours of a salon.
+	SetOpeningHours(salonID int, hours []models.OpeningHours) error
+
+	// Add a holiday, special hours or a temporary closure and return its ID.
+	AddHoursException(exception models.HoursException) (int, error)
+
+	// Retrieve an hours exception by its ID.
+	GetHoursExceptionByID(exceptionID int) (*models.HoursException, error)
+
+	// Delete an hours exception by its ID.
+	DeleteHoursException(exceptionID int) error
+
+	// List a page of the hours exceptions of a salon touching the dates from..to.
+	ListHoursExceptions(salonID int, from, to string, page pagination.Query) (*pagination.Page[models.HoursException], error)
+
+	// Work out the opening hours of a salon on each date from..to.
+	GetEffectiveHours(salonID int, from, to string) ([]models.DayHours, error)
+
+	// Retrieve the cancellation and rescheduling policy of a salon.
+	GetSalonPolicy(salonID int) (*models.SalonPolicy, error)
+
+	// Replace the cancellation and rescheduling policy of a salon.
+	SetSalonPolicy(policy models.SalonPolicy) error
+
+	// Add a staff member to a salon, along with the services they perform, and return its ID.
+	AddStaff(staff models.Staff) (int, error)
+
+	// Update the details and services of a staff member.
+	UpdateStaff(staff models.Staff) error
+
+	// Delete a staff member by its ID.
+	DeleteStaff(staffID int) error
+
+	// Retrieve a staff member by its ID.
+	GetStaffByID(staffID int) (*models.Staff, error)
+
+	// List a page of the staff members of a specific salon.
+	ListStaffBySalon(salonID int, page pagination.Query) (*pagination.Page[models.Staff], error)
+
+	// List a page of the active staff members who can perform a specific service.
+	ListStaffByService(serviceID int, page pagination.Query) (*pagination.Page[models.Staff], error)
 }
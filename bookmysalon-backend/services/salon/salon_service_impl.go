@@ -2,13 +2,24 @@ package salon
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
 	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/pagination"
 	"database/sql"
 	"errors"
 	"log"
+	"strings"
 )
 
-var ErrSalonNotFound = errors.New("salon not found")
+var (
+	ErrSalonNotFound       = errors.New("salon not found")
+	ErrServiceNotFound     = errors.New("service not found")
+	ErrInvalidMemberRole   = errors.New("member role must be owner or staff")
+	ErrSalonMemberNotFound = errors.New("salon member not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidTimezone     = errors.New("timezone must be an IANA name such as Europe/Berlin")
+)
 
 // Constants for error messages.
 const (
@@ -17,37 +28,107 @@ const (
 	ErrorSalonIDNotSet = "salon ID must be provided for update"
 )
 
+// salonColumns selects a salon in the order scanSalon expects.
+const salonColumns = `
+	salon_id, name, address, COALESCE(street, ''), COALESCE(city, ''), COALESCE(postal_code, ''), COALESCE(country, ''),
+	latitude, longitude, contact_details, photos, average_rating, timezone, status, COALESCE(status_reason, '')
+`
+
+// salonList describes how lists of salons can be sorted and filtered.
+var salonList = pagination.Spec{
+	ID: pagination.Field{Column: "salon_id", JSON: "salon_id"},
+	Sorts: map[string]pagination.Field{
+		"id":             {Column: "salon_id", JSON: "salon_id"},
+		"name":           {Column: "name", JSON: "name"},
+		"average_rating": {Column: "average_rating", JSON: "average_rating"},
+	},
+	DefaultSort: "name",
+	Filters: map[string]pagination.Filter{
+		"city":       {Column: "city", Type: pagination.String},
+		"country":    {Column: "country", Type: pagination.String},
+		"min_rating": {Column: "average_rating", Op: ">=", Type: pagination.Float},
+	},
+}
+
+// serviceList describes how lists of services can be sorted and filtered.
+var serviceList = pagination.Spec{
+	ID: pagination.Field{Column: "service_id", JSON: "service_id"},
+	Sorts: map[string]pagination.Field{
+		"id":    {Column: "service_id", JSON: "service_id"},
+		"name":  {Column: "name", JSON: "name"},
+		"price": {Column: "price", JSON: "price"},
+	},
+	DefaultSort: "name",
+	Filters: map[string]pagination.Filter{
+		"category":  {Column: "category", Type: pagination.String},
+		"min_price": {Column: "price", Op: ">=", Type: pagination.Float},
+		"max_price": {Column: "price", Op: "<=", Type: pagination.Float},
+	},
+}
+
 // salonServiceImpl is the implementation of the SalonService interface.
 type salonServiceImpl struct {
-	db *sql.DB
+	db       *sql.DB
+	geocoder geo.Geocoder
 }
 
-// NewSalonService initializes and returns an instance of SalonService.
-func NewSalonService() (SalonService, error) {
+// NewSalonService initializes and returns an instance of SalonService. The
+// geocoder fills in the coordinates of salons that are saved without them.
+func NewSalonService(geocoder geo.Geocoder) (SalonService, error) {
 	db, err := database.Connect()
 	if err != nil {
 		return nil, err
 	}
 	return &salonServiceImpl{
-		db: db,
+		db:       db,
+		geocoder: geocoder,
 	}, nil
 }
 
-// AddSalon adds a new salon to the database and returns its ID.
+// AddSalon adds a new salon to the database, records its owner and returns its ID.
 // swagger:model
-func (s *salonServiceImpl) AddSalon(salon models.Salon) (int, error) {
+func (s *salonServiceImpl) AddSalon(salon models.Salon, ownerID int) (int, error) {
+	if salon.Timezone == "" {
+		salon.Timezone = defaultTimezone
+	}
+	if !isValidTimezone(salon.Timezone) {
+		return 0, ErrInvalidTimezone
+	}
+	if err := s.locate(&salon); err != nil {
+		return 0, err
+	}
+
 	const query = `
-		INSERT INTO salons(name, address, contact_details, photos, average_rating) 
-		VALUES($1, $2, $3, $4, $5) RETURNING salon_id
+		INSERT INTO salons(name, address, street, city, postal_code, country, latitude, longitude, contact_details, timezone) 
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING salon_id
 	`
+	const memberQuery = `INSERT INTO salon_members(salon_id, user_id, role) VALUES($1, $2, $3)`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("%s: %v", ErrorSalonInsert, err)
+		return 0, err
+	}
+	defer tx.Rollback()
 
 	var salonID int
-	err := s.db.QueryRow(query, salon.Name, salon.Address, salon.ContactDetails, salon.Photos, salon.AverageRating).Scan(&salonID)
+	err = tx.QueryRow(query, salon.Name, salon.Address, salon.Street, salon.City, salon.PostalCode, salon.Country, salon.Latitude, salon.Longitude,
+		salon.ContactDetails, salon.Timezone).Scan(&salonID)
 	if err != nil {
 		log.Printf("%s: %v", ErrorSalonInsert, err)
 		return 0, err
 	}
 
+	if _, err := tx.Exec(memberQuery, salonID, ownerID, authz.SalonRoleOwner); err != nil {
+		log.Printf("Error recording salon owner: %v", err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorSalonInsert, err)
+		return 0, err
+	}
+
 	return salonID, nil
 }
 
@@ -57,13 +138,23 @@ func (s *salonServiceImpl) UpdateSalon(salon models.Salon) error {
 	if salon.SalonID == 0 {
 		return errors.New(ErrorSalonIDNotSet)
 	}
+	if salon.Timezone != "" && !isValidTimezone(salon.Timezone) {
+		return ErrInvalidTimezone
+	}
+	if err := s.locate(&salon); err != nil {
+		return err
+	}
 
+	// The timezone is kept when the update leaves it out. The photos are
+	// managed through the photo uploads, and the rating follows the reviews.
 	const query = `
-		UPDATE salons SET name=$1, address=$2, contact_details=$3, photos=$4, average_rating=$5 
-		WHERE salon_id=$6
+		UPDATE salons SET name=$1, address=$2, street=$3, city=$4, postal_code=$5, country=$6, latitude=$7, longitude=$8,
+			contact_details=$9, timezone=COALESCE(NULLIF($10, ''), timezone) 
+		WHERE salon_id=$11
 	`
 
-	_, err := s.db.Exec(query, salon.Name, salon.Address, salon.ContactDetails, salon.Photos, salon.AverageRating, salon.SalonID)
+	_, err := s.db.Exec(query, salon.Name, salon.Address, salon.Street, salon.City, salon.PostalCode, salon.Country, salon.Latitude, salon.Longitude,
+		salon.ContactDetails, salon.Timezone, salon.SalonID)
 	if err != nil {
 		log.Printf("%s: %v", ErrorSalonUpdate, err)
 		return err
@@ -87,13 +178,10 @@ func (s *salonServiceImpl) DeleteSalon(salonID int) error {
 
 // GetSalonByID retrieves a salon by its ID.
 func (s *salonServiceImpl) GetSalonByID(salonID int) (*models.Salon, error) {
-	const query = `
-		SELECT salon_id, name, address, contact_details, photos, average_rating
-		FROM salons WHERE salon_id=$1
-	`
+	query := `SELECT ` + salonColumns + ` FROM salons WHERE salon_id=$1`
 
 	var salon models.Salon
-	err := s.db.QueryRow(query, salonID).Scan(&salon.SalonID, &salon.Name, &salon.Address, &salon.ContactDetails, &salon.Photos, &salon.AverageRating)
+	err := s.db.QueryRow(query, salonID).Scan(salonFields(&salon)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrSalonNotFound
@@ -105,14 +193,16 @@ func (s *salonServiceImpl) GetSalonByID(salonID int) (*models.Salon, error) {
 	return &salon, nil
 }
 
-// ListSalons retrieves all salons from the database.
-func (s *salonServiceImpl) ListSalons() ([]models.Salon, error) {
-	const query = `
-		SELECT salon_id, name, address, contact_details, photos, average_rating
-		FROM salons
-	`
+// ListSalons retrieves a page of the approved salons in the system.
+func (s *salonServiceImpl) ListSalons(page pagination.Query) (*pagination.Page[models.Salon], error) {
+	return s.listSalons("status = '"+models.SalonStatusApproved+"'", page)
+}
+
+// listSalons retrieves a page of the salons matching a condition.
+func (s *salonServiceImpl) listSalons(where string, page pagination.Query, args ...interface{}) (*pagination.Page[models.Salon], error) {
+	query, args := page.SQL(`SELECT `+salonColumns+` FROM salons`, where, args...)
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		log.Printf("Error listing salons: %v", err)
 		return nil, err
@@ -122,26 +212,37 @@ func (s *salonServiceImpl) ListSalons() ([]models.Salon, error) {
 	var salons []models.Salon
 	for rows.Next() {
 		var salon models.Salon
-		if err := rows.Scan(&salon.SalonID, &salon.Name, &salon.Address, &salon.ContactDetails, &salon.Photos, &salon.AverageRating); err != nil {
+		if err := rows.Scan(salonFields(&salon)...); err != nil {
 			log.Printf("Error scanning row: %v", err)
 			return nil, err
 		}
 		salons = append(salons, salon)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return salons, nil
+	return pagination.NewPage(salons, page)
 }
 
 // AddService adds a new service to the database and returns its ID.
 func (s *salonServiceImpl) AddService(service models.Service) (int, error) {
+	if err := checkServiceDurations(service); err != nil {
+		return 0, err
+	}
+
 	const query = `
-		INSERT INTO services(salon_id, name, description, duration, price) 
-		VALUES($1, $2, $3, $4, $5) RETURNING service_id
+		INSERT INTO services(salon_id, name, description, duration, price, category, buffer_before, buffer_after) 
+		VALUES($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8) RETURNING service_id
 	`
 
 	var serviceID int
-	err := s.db.QueryRow(query, service.SalonID, service.Name, service.Description, service.Duration, service.Price).Scan(&serviceID)
+	err := s.db.QueryRow(query, service.SalonID, service.Name, service.Description, service.Duration, service.Price, strings.TrimSpace(service.Category),
+		service.BufferBefore, service.BufferAfter).Scan(&serviceID)
 	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return 0, mapped
+		}
 		log.Printf("Error inserting service: %v", err)
 		return 0, err
 	}
@@ -151,13 +252,21 @@ func (s *salonServiceImpl) AddService(service models.Service) (int, error) {
 
 // UpdateService updates the details of a service in the database.
 func (s *salonServiceImpl) UpdateService(service models.Service) error {
+	if err := checkServiceDurations(service); err != nil {
+		return err
+	}
+
 	const query = `
-		UPDATE services SET salon_id=$1, name=$2, description=$3, duration=$4, price=$5 
-		WHERE service_id=$6
+		UPDATE services SET salon_id=$1, name=$2, description=$3, duration=$4, price=$5, category=NULLIF($6, ''), buffer_before=$7, buffer_after=$8 
+		WHERE service_id=$9
 	`
 
-	_, err := s.db.Exec(query, service.SalonID, service.Name, service.Description, service.Duration, service.Price, service.ServiceID)
+	_, err := s.db.Exec(query, service.SalonID, service.Name, service.Description, service.Duration, service.Price, strings.TrimSpace(service.Category),
+		service.BufferBefore, service.BufferAfter, service.ServiceID)
 	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return mapped
+		}
 		log.Printf("Error updating service: %v", err)
 		return err
 	}
@@ -178,31 +287,41 @@ func (s *salonServiceImpl) DeleteService(serviceID int) error {
 	return nil
 }
 
-// GetServiceByID retrieves a service by its ID.
+// GetServiceByID retrieves a service by its ID, along with its variants and add-ons.
 func (s *salonServiceImpl) GetServiceByID(serviceID int) (*models.Service, error) {
 	const query = `
-		SELECT service_id, salon_id, name, description, duration, price
+		SELECT service_id, salon_id, name, description, duration, price, COALESCE(category, ''), buffer_before, buffer_after
 		FROM services WHERE service_id=$1
 	`
 
 	var service models.Service
-	err := s.db.QueryRow(query, serviceID).Scan(&service.ServiceID, &service.SalonID, &service.Name, &service.Description, &service.Duration, &service.Price)
+	err := s.db.QueryRow(query, serviceID).Scan(&service.ServiceID, &service.SalonID, &service.Name, &service.Description, &service.Duration, &service.Price, &service.Category,
+		&service.BufferBefore, &service.BufferAfter)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrServiceNotFound
+		}
 		log.Printf("Error retrieving service by ID: %v", err)
 		return nil, err
 	}
 
+	if err := s.loadServiceOptions(&service); err != nil {
+		return nil, err
+	}
+
 	return &service, nil
 }
 
-// ListServicesBySalon retrieves all services offered by a specific salon.
-func (s *salonServiceImpl) ListServicesBySalon(salonID int) ([]models.Service, error) {
-	const query = `
-		SELECT service_id, salon_id, name, description, duration, price
-		FROM services WHERE salon_id=$1
+// ListServicesBySalon retrieves a page of the services offered by a specific salon,
+// along with their variants and add-ons.
+func (s *salonServiceImpl) ListServicesBySalon(salonID int, page pagination.Query) (*pagination.Page[models.Service], error) {
+	const selectFrom = `
+		SELECT service_id, salon_id, name, description, duration, price, COALESCE(category, ''), buffer_before, buffer_after
+		FROM services
 	`
 
-	rows, err := s.db.Query(query, salonID)
+	query, args := page.SQL(selectFrom, "salon_id=$1", salonID)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		log.Printf("Error listing services by salon: %v", err)
 		return nil, err
@@ -212,14 +331,26 @@ func (s *salonServiceImpl) ListServicesBySalon(salonID int) ([]models.Service, e
 	var services []models.Service
 	for rows.Next() {
 		var service models.Service
-		if err := rows.Scan(&service.ServiceID, &service.SalonID, &service.Name, &service.Description, &service.Duration, &service.Price); err != nil {
+		if err := rows.Scan(&service.ServiceID, &service.SalonID, &service.Name, &service.Description, &service.Duration, &service.Price, &service.Category,
+			&service.BufferBefore, &service.BufferAfter); err != nil {
 			log.Printf("Error scanning service row: %v", err)
 			return nil, err
 		}
 		services = append(services, service)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return services, nil
+	options := make([]*models.Service, len(services))
+	for i := range services {
+		options[i] = &services[i]
+	}
+	if err := s.loadServiceOptions(options...); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(services, page)
 }
 
 // GetAverageRating retrieves the average rating of a salon.
@@ -238,3 +369,49 @@ func (s *salonServiceImpl) GetAverageRating(salonID int) (float64, error) {
 
 	return avgRating, nil
 }
+
+// AddSalonMember adds a user to a salon as an owner or staff member.
+func (s *salonServiceImpl) AddSalonMember(salonID, userID int, role string) error {
+	if role != authz.SalonRoleOwner && role != authz.SalonRoleStaff {
+		return ErrInvalidMemberRole
+	}
+
+	const query = `
+		INSERT INTO salon_members(salon_id, user_id, role)
+		SELECT s.salon_id, u.id, $3 FROM salons s, users u
+		WHERE s.salon_id=$1 AND u.id=$2 AND u.deleted_at IS NULL
+		ON CONFLICT (salon_id, user_id) DO UPDATE SET role=EXCLUDED.role
+	`
+
+	result, err := s.db.Exec(query, salonID, userID, role)
+	if err != nil {
+		log.Printf("Error adding salon member: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if _, err := s.GetSalonByID(salonID); err != nil {
+			return err
+		}
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RemoveSalonMember removes a user from the owners and staff of a salon.
+func (s *salonServiceImpl) RemoveSalonMember(salonID, userID int) error {
+	const query = `DELETE FROM salon_members WHERE salon_id=$1 AND user_id=$2`
+
+	result, err := s.db.Exec(query, salonID, userID)
+	if err != nil {
+		log.Printf("Error removing salon member: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSalonMemberNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+var (
+	ErrInvalidSalonStatus     = errors.New("status must be draft, submitted, approved, rejected or suspended")
+	ErrIllegalSalonTransition = errors.New("the salon cannot move to this status from its current one")
+	ErrStatusReasonRequired   = errors.New("a reason is required to reject or suspend a salon")
+	ErrSalonUnderReview       = errors.New("the salon is under review and cannot be changed until it is approved or rejected")
+)
+
+// salonTransitions lists the statuses each status can move to. Owners submit
+// drafts and rejected salons; admins decide on the rest.
+var salonTransitions = map[string][]string{
+	models.SalonStatusDraft:     {models.SalonStatusSubmitted},
+	models.SalonStatusSubmitted: {models.SalonStatusApproved, models.SalonStatusRejected},
+	models.SalonStatusRejected:  {models.SalonStatusSubmitted},
+	models.SalonStatusApproved:  {models.SalonStatusSuspended},
+	models.SalonStatusSuspended: {models.SalonStatusApproved},
+}
+
+// canTransition reports whether a salon may move from one status to another.
+func canTransition(from, to string) bool {
+	for _, next := range salonTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeSalonStatus moves a salon to a new status and records who did it and why.
+func (s *salonServiceImpl) ChangeSalonStatus(salonID int, to, reason string, actorID int) (*models.Salon, error) {
+	if !models.IsValidSalonStatus(to) {
+		return nil, ErrInvalidSalonStatus
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" && (to == models.SalonStatusRejected || to == models.SalonStatusSuspended) {
+		return nil, ErrStatusReasonRequired
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error changing salon status: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var from string
+	if err := tx.QueryRow(`SELECT status FROM salons WHERE salon_id=$1 FOR UPDATE`, salonID).Scan(&from); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSalonNotFound
+		}
+		log.Printf("Error changing salon status: %v", err)
+		return nil, err
+	}
+	if !canTransition(from, to) {
+		return nil, ErrIllegalSalonTransition
+	}
+
+	// The reason stays on the salon while it is rejected or suspended, so the
+	// owner sees what to fix.
+	const updateQuery = `
+		UPDATE salons SET status=$2, status_changed_at=NOW(),
+			status_reason=CASE WHEN $2 IN ('rejected', 'suspended') THEN NULLIF($3, '') END
+		WHERE salon_id=$1
+	`
+	const historyQuery = `
+		INSERT INTO salon_status_history(salon_id, from_status, to_status, reason, actor_id)
+		VALUES($1, $2, $3, NULLIF($4, ''), $5)
+	`
+
+	if _, err := tx.Exec(updateQuery, salonID, to, reason); err != nil {
+		log.Printf("Error changing salon status: %v", err)
+		return nil, err
+	}
+	if _, err := tx.Exec(historyQuery, salonID, from, to, reason, actorID); err != nil {
+		log.Printf("Error recording salon status change: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error changing salon status: %v", err)
+		return nil, err
+	}
+
+	return s.GetSalonByID(salonID)
+}
+
+// ListSalonsByStatus retrieves a page of the salons in a status, such as the
+// submitted salons waiting for review.
+func (s *salonServiceImpl) ListSalonsByStatus(status string, page pagination.Query) (*pagination.Page[models.Salon], error) {
+	if !models.IsValidSalonStatus(status) {
+		return nil, ErrInvalidSalonStatus
+	}
+	return s.listSalons("status = $1", page, status)
+}
+
+// ListSalonStatusHistory retrieves the status changes of a salon, oldest first.
+func (s *salonServiceImpl) ListSalonStatusHistory(salonID int) ([]models.SalonStatusChange, error) {
+	const query = `
+		SELECT history_id, salon_id, from_status, to_status, COALESCE(reason, ''), actor_id,
+			TO_CHAR(changed_at, 'YYYY-MM-DD"T"HH24:MI:SS')
+		FROM salon_status_history WHERE salon_id=$1 ORDER BY changed_at, history_id
+	`
+
+	rows, err := s.db.Query(query, salonID)
+	if err != nil {
+		log.Printf("Error listing salon status history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.SalonStatusChange{}
+	for rows.Next() {
+		var change models.SalonStatusChange
+		var actorID sql.NullInt64
+		if err := rows.Scan(&change.HistoryID, &change.SalonID, &change.FromStatus, &change.ToStatus, &change.Reason, &actorID, &change.ChangedAt); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			return nil, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			change.ActorID = &id
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
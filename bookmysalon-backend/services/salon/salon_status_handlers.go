@@ -0,0 +1,233 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/pagination"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// statusReason is the body of a review decision.
+type statusReason struct {
+	Reason string `json:"reason"`
+}
+
+// @Summary Submit a salon for review
+// @Description Submit a draft or rejected salon to the admins. It goes live once approved.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {object} models.Salon
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Salon Cannot Be Submitted"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/submit [put]
+func (h *SalonHandler) SubmitSalon(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	h.changeSalonStatus(w, r, salonID, models.SalonStatusSubmitted, "")
+}
+
+// @Summary Approve a salon
+// @Description Approve a submitted salon, or reinstate a suspended one. Approved salons are listed, searchable and bookable.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param reason body statusReason false "Optional note"
+// @Success 200 {object} models.Salon
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Salon Cannot Be Approved"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/approve [put]
+func (h *SalonHandler) ApproveSalon(w http.ResponseWriter, r *http.Request) {
+	h.reviewSalon(w, r, models.SalonStatusApproved)
+}
+
+// @Summary Reject a salon
+// @Description Reject a submitted salon with a reason. The owner can edit and submit it again.
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param reason body statusReason true "Reason shown to the owner"
+// @Success 200 {object} models.Salon
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Salon Cannot Be Rejected"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/reject [put]
+func (h *SalonHandler) RejectSalon(w http.ResponseWriter, r *http.Request) {
+	h.reviewSalon(w, r, models.SalonStatusRejected)
+}
+
+// @Summary Suspend a salon
+// @Description Take an approved salon offline with a reason, until it is approved again
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param reason body statusReason true "Reason shown to the owner"
+// @Success 200 {object} models.Salon
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Salon Cannot Be Suspended"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/suspend [put]
+func (h *SalonHandler) SuspendSalon(w http.ResponseWriter, r *http.Request) {
+	h.reviewSalon(w, r, models.SalonStatusSuspended)
+}
+
+// @Summary List salons by status
+// @Description Retrieve the salons in a lifecycle status, e.g. the submitted salons waiting for review
+// @Accept  json
+// @Produce  json
+// @Param status path string true "draft, submitted, approved, rejected or suspended"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name, average_rating or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Salon]
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salons/status/{status} [get]
+func (h *SalonHandler) ListSalonsByStatus(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, salonList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	salons, err := h.service.ListSalonsByStatus(mux.Vars(r)["status"], page)
+	if err != nil {
+		writeSalonStatusError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(salons)
+}
+
+// @Summary Get the status history of a salon
+// @Description Retrieve the submissions and review decisions of a salon, oldest first
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {array} models.SalonStatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/status-history [get]
+func (h *SalonHandler) GetSalonStatusHistory(w http.ResponseWriter, r *http.Request) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	changes, err := h.service.ListSalonStatusHistory(salonID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(changes)
+}
+
+// reviewSalon applies an admin decision. The routes are limited to admins.
+func (h *SalonHandler) reviewSalon(w http.ResponseWriter, r *http.Request, status string) {
+	salonID, err := strconv.Atoi(mux.Vars(r)["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional for approvals.
+	var body statusReason
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	h.changeSalonStatus(w, r, salonID, status, body.Reason)
+}
+
+func (h *SalonHandler) changeSalonStatus(w http.ResponseWriter, r *http.Request, salonID int, status, reason string) {
+	actorID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	salon, err := h.service.ChangeSalonStatus(salonID, status, reason, actorID)
+	if err != nil {
+		writeSalonStatusError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(salon)
+}
+
+// visibleSalon reports whether the caller may see a salon. Salons that are
+// not live are only shown to their owners, staff and the admins.
+func (h *SalonHandler) visibleSalon(r *http.Request, salon *models.Salon) bool {
+	if salon.Status == models.SalonStatusApproved {
+		return true
+	}
+	return h.policy.AuthorizeSalon(r.Context(), salon.SalonID) == nil
+}
+
+// showCatalog checks that the caller may see the services of a salon, which
+// are hidden along with the salon until it is approved. It writes the error
+// response itself and reports whether the request may proceed.
+func (h *SalonHandler) showCatalog(w http.ResponseWriter, r *http.Request, salonID int) bool {
+	salon, err := h.service.GetSalonByID(salonID)
+	if err == nil && !h.visibleSalon(r, salon) {
+		err = ErrSalonNotFound
+	}
+	if err != nil {
+		switch err {
+		case ErrSalonNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+func writeSalonStatusError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidSalonStatus, ErrStatusReasonRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrSalonNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrIllegalSalonTransition, ErrSalonUnderReview:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
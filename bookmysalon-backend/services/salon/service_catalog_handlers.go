@@ -0,0 +1,460 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Add a service category
+// @Description Add a category, such as "Hair" or "Nails", to the menu of a salon
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param category body models.ServiceCategory true "Create Category"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 409 {object} map[string]string "Category Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/categories [post]
+func (h *SalonHandler) AddCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var category models.ServiceCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	category.SalonID = salonID
+
+	if err := h.policy.AuthorizeSalon(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	categoryID, err := h.service.AddCategory(category)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"category_id": categoryID})
+}
+
+// @Summary List service categories
+// @Description Retrieve the categories of a salon in menu order
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Success 200 {array} models.ServiceCategory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/categories [get]
+func (h *SalonHandler) ListCategoriesBySalon(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.showCatalog(w, r, salonID) {
+		return
+	}
+
+	categories, err := h.service.ListCategoriesBySalon(salonID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(categories)
+}
+
+// @Summary Update a service category
+// @Description Rename, describe or reorder a category. Renaming it renames the category of its services too.
+// @Accept  json
+// @Produce  json
+// @Param categoryID path int true "Category ID"
+// @Param category body models.ServiceCategory true "Update Category"
+// @Success 200 {object} models.ServiceCategory
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Category Not Found"
+// @Failure 409 {object} map[string]string "Category Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /category/{categoryID} [put]
+func (h *SalonHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	categoryID, err := strconv.Atoi(vars["categoryID"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	var category models.ServiceCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	existing, ok := h.authorizeCategory(w, r, categoryID)
+	if !ok {
+		return
+	}
+	// A category cannot be moved to another salon through an update.
+	category.CategoryID = existing.CategoryID
+	category.SalonID = existing.SalonID
+
+	if err := h.service.UpdateCategory(category); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetCategoryByID(categoryID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Delete a service category
+// @Description Delete a category. Its services stay on the menu without a category.
+// @Accept  json
+// @Produce  json
+// @Param categoryID path int true "Category ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Category Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /category/{categoryID} [delete]
+func (h *SalonHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	categoryID, err := strconv.Atoi(vars["categoryID"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.authorizeCategory(w, r, categoryID); !ok {
+		return
+	}
+
+	if err := h.service.DeleteCategory(categoryID); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Add a service variant
+// @Description Add a version of a service with its own price and duration, such as a haircut for long hair
+// @Accept  json
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Param variant body models.ServiceVariant true "Create Variant"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
+// @Failure 409 {object} map[string]string "Variant Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/variants [post]
+func (h *SalonHandler) AddVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serviceID, err := strconv.Atoi(vars["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	var variant models.ServiceVariant
+	if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	variant.ServiceID = serviceID
+
+	if _, ok := h.authorizeService(w, r, serviceID); !ok {
+		return
+	}
+
+	variantID, err := h.service.AddVariant(variant)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"variant_id": variantID})
+}
+
+// @Summary Update a service variant
+// @Description Update the name, price, duration and position of a variant
+// @Accept  json
+// @Produce  json
+// @Param variantID path int true "Variant ID"
+// @Param variant body models.ServiceVariant true "Update Variant"
+// @Success 200 {object} models.ServiceVariant
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Variant Not Found"
+// @Failure 409 {object} map[string]string "Variant Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /variant/{variantID} [put]
+func (h *SalonHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	variantID, err := strconv.Atoi(vars["variantID"])
+	if err != nil {
+		http.Error(w, "Invalid variant ID", http.StatusBadRequest)
+		return
+	}
+
+	var variant models.ServiceVariant
+	if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.service.GetVariantByID(variantID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	if _, ok := h.authorizeService(w, r, existing.ServiceID); !ok {
+		return
+	}
+	// A variant cannot be moved to another service through an update.
+	variant.VariantID = existing.VariantID
+	variant.ServiceID = existing.ServiceID
+
+	if err := h.service.UpdateVariant(variant); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetVariantByID(variantID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Delete a service variant
+// @Description Delete a variant. Appointments booked with it keep their totals.
+// @Accept  json
+// @Produce  json
+// @Param variantID path int true "Variant ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Variant Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /variant/{variantID} [delete]
+func (h *SalonHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	variantID, err := strconv.Atoi(vars["variantID"])
+	if err != nil {
+		http.Error(w, "Invalid variant ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.service.GetVariantByID(variantID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	if _, ok := h.authorizeService(w, r, existing.ServiceID); !ok {
+		return
+	}
+
+	if err := h.service.DeleteVariant(variantID); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Add a service add-on
+// @Description Add an optional extra, such as a deep conditioning, that can be booked with a service
+// @Accept  json
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Param addon body models.ServiceAddOn true "Create Add-on"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
+// @Failure 409 {object} map[string]string "Add-on Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/addons [post]
+func (h *SalonHandler) AddAddOn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serviceID, err := strconv.Atoi(vars["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	var addOn models.ServiceAddOn
+	if err := json.NewDecoder(r.Body).Decode(&addOn); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	addOn.ServiceID = serviceID
+
+	if _, ok := h.authorizeService(w, r, serviceID); !ok {
+		return
+	}
+
+	addOnID, err := h.service.AddAddOn(addOn)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"addon_id": addOnID})
+}
+
+// @Summary Update a service add-on
+// @Description Update the name, price, duration and position of an add-on
+// @Accept  json
+// @Produce  json
+// @Param addonID path int true "Add-on ID"
+// @Param addon body models.ServiceAddOn true "Update Add-on"
+// @Success 200 {object} models.ServiceAddOn
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Add-on Not Found"
+// @Failure 409 {object} map[string]string "Add-on Already Exists"
+// @Failure 500 {object} map[string]string
+// @Router /addon/{addonID} [put]
+func (h *SalonHandler) UpdateAddOn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	addOnID, err := strconv.Atoi(vars["addonID"])
+	if err != nil {
+		http.Error(w, "Invalid add-on ID", http.StatusBadRequest)
+		return
+	}
+
+	var addOn models.ServiceAddOn
+	if err := json.NewDecoder(r.Body).Decode(&addOn); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.service.GetAddOnByID(addOnID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	if _, ok := h.authorizeService(w, r, existing.ServiceID); !ok {
+		return
+	}
+	// An add-on cannot be moved to another service through an update.
+	addOn.AddOnID = existing.AddOnID
+	addOn.ServiceID = existing.ServiceID
+
+	if err := h.service.UpdateAddOn(addOn); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetAddOnByID(addOnID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Delete a service add-on
+// @Description Delete an add-on. Appointments booked with it keep their totals.
+// @Accept  json
+// @Produce  json
+// @Param addonID path int true "Add-on ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Add-on Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /addon/{addonID} [delete]
+func (h *SalonHandler) DeleteAddOn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	addOnID, err := strconv.Atoi(vars["addonID"])
+	if err != nil {
+		http.Error(w, "Invalid add-on ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.service.GetAddOnByID(addOnID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	if _, ok := h.authorizeService(w, r, existing.ServiceID); !ok {
+		return
+	}
+
+	if err := h.service.DeleteAddOn(addOnID); err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorizeCategory loads a category and checks that the caller may manage its salon.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *SalonHandler) authorizeCategory(w http.ResponseWriter, r *http.Request, categoryID int) (*models.ServiceCategory, bool) {
+	category, err := h.service.GetCategoryByID(categoryID)
+	if err != nil {
+		writeCatalogError(w, err)
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeSalon(r.Context(), category.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return category, true
+}
+
+// writeCatalogError writes the HTTP response matching an error of the service,
+// category, variant and add-on methods.
+func writeCatalogError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrCategoryNameRequired, ErrUnknownCategory, ErrOptionNameRequired, ErrInvalidPrice,
+		ErrInvalidDuration, ErrNegativeDuration:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrCategoryNotFound, ErrVariantNotFound, ErrAddOnNotFound, ErrServiceNotFound, ErrSalonNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrCategoryExists, ErrVariantExists, ErrAddOnExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,472 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrCategoryNameRequired = errors.New("category name is required")
+	ErrCategoryExists       = errors.New("the salon already has a category with this name")
+	ErrUnknownCategory      = errors.New("category is not one of the salon's categories")
+	ErrVariantNotFound      = errors.New("variant not found")
+	ErrVariantExists        = errors.New("the service already has a variant with this name")
+	ErrAddOnNotFound        = errors.New("add-on not found")
+	ErrAddOnExists          = errors.New("the service already has an add-on with this name")
+	ErrOptionNameRequired   = errors.New("name is required")
+	ErrInvalidPrice         = errors.New("price must not be negative")
+	ErrInvalidDuration      = errors.New("duration must be greater than zero")
+	ErrNegativeDuration     = errors.New("add-on durations and buffer times must not be negative")
+)
+
+// AddCategory adds a category to the menu of a salon and returns its ID.
+func (s *salonServiceImpl) AddCategory(category models.ServiceCategory) (int, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return 0, ErrCategoryNameRequired
+	}
+
+	const query = `
+		INSERT INTO service_categories(salon_id, name, description, position)
+		VALUES($1, $2, NULLIF($3, ''), $4) RETURNING category_id
+	`
+
+	var categoryID int
+	err := s.db.QueryRow(query, category.SalonID, category.Name, category.Description, category.Position).Scan(&categoryID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return 0, mapped
+		}
+		log.Printf("Error inserting category: %v", err)
+		return 0, err
+	}
+
+	return categoryID, nil
+}
+
+// UpdateCategory updates a category. Renaming it renames the category of its services too.
+func (s *salonServiceImpl) UpdateCategory(category models.ServiceCategory) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return ErrCategoryNameRequired
+	}
+
+	const query = `
+		UPDATE service_categories SET name=$1, description=NULLIF($2, ''), position=$3
+		WHERE category_id=$4
+	`
+
+	result, err := s.db.Exec(query, category.Name, category.Description, category.Position, category.CategoryID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return mapped
+		}
+		log.Printf("Error updating category: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes a category. Its services stay on the menu without a category.
+func (s *salonServiceImpl) DeleteCategory(categoryID int) error {
+	const clearQuery = `
+		UPDATE services sv SET category=NULL
+		FROM service_categories c
+		WHERE c.category_id=$1 AND sv.salon_id=c.salon_id AND sv.category=c.name
+	`
+	const deleteQuery = `DELETE FROM service_categories WHERE category_id=$1`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error deleting category: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(clearQuery, categoryID); err != nil {
+		log.Printf("Error clearing category of services: %v", err)
+		return err
+	}
+
+	result, err := tx.Exec(deleteQuery, categoryID)
+	if err != nil {
+		log.Printf("Error deleting category: %v", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error deleting category: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetCategoryByID retrieves a category by its ID.
+func (s *salonServiceImpl) GetCategoryByID(categoryID int) (*models.ServiceCategory, error) {
+	const query = `
+		SELECT category_id, salon_id, name, COALESCE(description, ''), position
+		FROM service_categories WHERE category_id=$1
+	`
+
+	var category models.ServiceCategory
+	err := s.db.QueryRow(query, categoryID).Scan(&category.CategoryID, &category.SalonID, &category.Name, &category.Description, &category.Position)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCategoryNotFound
+		}
+		log.Printf("Error retrieving category by ID: %v", err)
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// ListCategoriesBySalon retrieves the categories of a salon in menu order.
+func (s *salonServiceImpl) ListCategoriesBySalon(salonID int) ([]models.ServiceCategory, error) {
+	const query = `
+		SELECT category_id, salon_id, name, COALESCE(description, ''), position
+		FROM service_categories WHERE salon_id=$1
+		ORDER BY position, name
+	`
+
+	rows, err := s.db.Query(query, salonID)
+	if err != nil {
+		log.Printf("Error listing categories by salon: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.ServiceCategory{}
+	for rows.Next() {
+		var category models.ServiceCategory
+		if err := rows.Scan(&category.CategoryID, &category.SalonID, &category.Name, &category.Description, &category.Position); err != nil {
+			log.Printf("Error scanning category row: %v", err)
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// AddVariant adds a variant to a service and returns its ID.
+func (s *salonServiceImpl) AddVariant(variant models.ServiceVariant) (int, error) {
+	if err := checkOption(&variant.Name, variant.Price); err != nil {
+		return 0, err
+	}
+	if variant.Duration <= 0 {
+		return 0, ErrInvalidDuration
+	}
+
+	const query = `
+		INSERT INTO service_variants(service_id, name, price, duration, position)
+		VALUES($1, $2, $3, $4, $5) RETURNING variant_id
+	`
+
+	var variantID int
+	err := s.db.QueryRow(query, variant.ServiceID, variant.Name, variant.Price, variant.Duration, variant.Position).Scan(&variantID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return 0, mapped
+		}
+		log.Printf("Error inserting variant: %v", err)
+		return 0, err
+	}
+
+	return variantID, nil
+}
+
+// UpdateVariant updates the name, price, duration and position of a variant.
+func (s *salonServiceImpl) UpdateVariant(variant models.ServiceVariant) error {
+	if err := checkOption(&variant.Name, variant.Price); err != nil {
+		return err
+	}
+	if variant.Duration <= 0 {
+		return ErrInvalidDuration
+	}
+
+	const query = `
+		UPDATE service_variants SET name=$1, price=$2, duration=$3, position=$4
+		WHERE variant_id=$5
+	`
+
+	result, err := s.db.Exec(query, variant.Name, variant.Price, variant.Duration, variant.Position, variant.VariantID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return mapped
+		}
+		log.Printf("Error updating variant: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrVariantNotFound
+	}
+
+	return nil
+}
+
+// DeleteVariant deletes a variant. Appointments booked with it keep their totals.
+func (s *salonServiceImpl) DeleteVariant(variantID int) error {
+	const query = `DELETE FROM service_variants WHERE variant_id=$1`
+
+	result, err := s.db.Exec(query, variantID)
+	if err != nil {
+		log.Printf("Error deleting variant: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrVariantNotFound
+	}
+
+	return nil
+}
+
+// GetVariantByID retrieves a variant by its ID.
+func (s *salonServiceImpl) GetVariantByID(variantID int) (*models.ServiceVariant, error) {
+	const query = `
+		SELECT variant_id, service_id, name, price, duration, position
+		FROM service_variants WHERE variant_id=$1
+	`
+
+	var variant models.ServiceVariant
+	err := s.db.QueryRow(query, variantID).Scan(&variant.VariantID, &variant.ServiceID, &variant.Name, &variant.Price, &variant.Duration, &variant.Position)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVariantNotFound
+		}
+		log.Printf("Error retrieving variant by ID: %v", err)
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// AddAddOn adds an add-on to a service and returns its ID.
+func (s *salonServiceImpl) AddAddOn(addOn models.ServiceAddOn) (int, error) {
+	if err := checkOption(&addOn.Name, addOn.Price); err != nil {
+		return 0, err
+	}
+	if addOn.Duration < 0 {
+		return 0, ErrNegativeDuration
+	}
+
+	const query = `
+		INSERT INTO service_addons(service_id, name, price, duration, position)
+		VALUES($1, $2, $3, $4, $5) RETURNING addon_id
+	`
+
+	var addOnID int
+	err := s.db.QueryRow(query, addOn.ServiceID, addOn.Name, addOn.Price, addOn.Duration, addOn.Position).Scan(&addOnID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return 0, mapped
+		}
+		log.Printf("Error inserting add-on: %v", err)
+		return 0, err
+	}
+
+	return addOnID, nil
+}
+
+// UpdateAddOn updates the name, price, duration and position of an add-on.
+func (s *salonServiceImpl) UpdateAddOn(addOn models.ServiceAddOn) error {
+	if err := checkOption(&addOn.Name, addOn.Price); err != nil {
+		return err
+	}
+	if addOn.Duration < 0 {
+		return ErrNegativeDuration
+	}
+
+	const query = `
+		UPDATE service_addons SET name=$1, price=$2, duration=$3, position=$4
+		WHERE addon_id=$5
+	`
+
+	result, err := s.db.Exec(query, addOn.Name, addOn.Price, addOn.Duration, addOn.Position, addOn.AddOnID)
+	if err != nil {
+		if mapped := catalogConstraintError(err); mapped != nil {
+			return mapped
+		}
+		log.Printf("Error updating add-on: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrAddOnNotFound
+	}
+
+	return nil
+}
+
+// DeleteAddOn deletes an add-on. It is taken off the appointments it was booked with,
+// whose totals stay as they were.
+func (s *salonServiceImpl) DeleteAddOn(addOnID int) error {
+	const query = `DELETE FROM service_addons WHERE addon_id=$1`
+
+	result, err := s.db.Exec(query, addOnID)
+	if err != nil {
+		log.Printf("Error deleting add-on: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrAddOnNotFound
+	}
+
+	return nil
+}
+
+// GetAddOnByID retrieves an add-on by its ID.
+func (s *salonServiceImpl) GetAddOnByID(addOnID int) (*models.ServiceAddOn, error) {
+	const query = `
+		SELECT addon_id, service_id, name, price, duration, position
+		FROM service_addons WHERE addon_id=$1
+	`
+
+	var addOn models.ServiceAddOn
+	err := s.db.QueryRow(query, addOnID).Scan(&addOn.AddOnID, &addOn.ServiceID, &addOn.Name, &addOn.Price, &addOn.Duration, &addOn.Position)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAddOnNotFound
+		}
+		log.Printf("Error retrieving add-on by ID: %v", err)
+		return nil, err
+	}
+
+	return &addOn, nil
+}
+
+// loadServiceOptions fills in the variants and add-ons of services, in menu order.
+func (s *salonServiceImpl) loadServiceOptions(services ...*models.Service) error {
+	if len(services) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Service, len(services))
+	ids := pq.Int64Array{}
+	for _, service := range services {
+		service.Variants = []models.ServiceVariant{}
+		service.AddOns = []models.ServiceAddOn{}
+		byID[service.ServiceID] = service
+		ids = append(ids, int64(service.ServiceID))
+	}
+
+	const variantQuery = `
+		SELECT variant_id, service_id, name, price, duration, position
+		FROM service_variants WHERE service_id = ANY($1)
+		ORDER BY position, name
+	`
+	rows, err := s.db.Query(variantQuery, ids)
+	if err != nil {
+		log.Printf("Error listing variants of services: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variant models.ServiceVariant
+		if err := rows.Scan(&variant.VariantID, &variant.ServiceID, &variant.Name, &variant.Price, &variant.Duration, &variant.Position); err != nil {
+			log.Printf("Error scanning variant row: %v", err)
+			return err
+		}
+		service := byID[variant.ServiceID]
+		service.Variants = append(service.Variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	const addOnQuery = `
+		SELECT addon_id, service_id, name, price, duration, position
+		FROM service_addons WHERE service_id = ANY($1)
+		ORDER BY position, name
+	`
+	addOnRows, err := s.db.Query(addOnQuery, ids)
+	if err != nil {
+		log.Printf("Error listing add-ons of services: %v", err)
+		return err
+	}
+	defer addOnRows.Close()
+
+	for addOnRows.Next() {
+		var addOn models.ServiceAddOn
+		if err := addOnRows.Scan(&addOn.AddOnID, &addOn.ServiceID, &addOn.Name, &addOn.Price, &addOn.Duration, &addOn.Position); err != nil {
+			log.Printf("Error scanning add-on row: %v", err)
+			return err
+		}
+		service := byID[addOn.ServiceID]
+		service.AddOns = append(service.AddOns, addOn)
+	}
+
+	return addOnRows.Err()
+}
+
+// checkOption validates the name and price of a variant or add-on, trimming
+// the name in place.
+func checkOption(name *string, price float64) error {
+	*name = strings.TrimSpace(*name)
+	if *name == "" {
+		return ErrOptionNameRequired
+	}
+	if price < 0 {
+		return ErrInvalidPrice
+	}
+	return nil
+}
+
+// checkServiceDurations validates the duration and buffer times of a service.
+func checkServiceDurations(service models.Service) error {
+	if service.Duration <= 0 {
+		return ErrInvalidDuration
+	}
+	if service.BufferBefore < 0 || service.BufferAfter < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+// catalogConstraintError maps violations of the catalog table constraints to
+// errors the caller can act on, or returns nil for any other error.
+func catalogConstraintError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case "service_categories_salon_id_fkey":
+		return ErrSalonNotFound
+	case "service_categories_salon_id_name_key", "idx_service_categories_salon_name":
+		return ErrCategoryExists
+	case "services_category_fkey":
+		return ErrUnknownCategory
+	case "service_variants_service_id_fkey", "service_addons_service_id_fkey":
+		return ErrServiceNotFound
+	case "service_variants_service_id_name_key":
+		return ErrVariantExists
+	case "service_addons_service_id_name_key":
+		return ErrAddOnExists
+	case "service_variants_price_check", "service_addons_price_check":
+		return ErrInvalidPrice
+	case "services_buffers_check":
+		return ErrNegativeDuration
+	}
+	return nil
+}
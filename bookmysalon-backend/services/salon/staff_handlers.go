@@ -0,0 +1,280 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/authz"
+	"bookmysalon/pkg/pagination"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Add a staff member
+// @Description Add a stylist or other staff member to a salon, along with the services they perform
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param staff body models.Staff true "Create Staff Member"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Salon or User Not Found"
+// @Failure 409 {object} map[string]string "User Already Staff"
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/staff [post]
+func (h *SalonHandler) AddStaff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	var staff models.Staff
+	// New staff members are bookable unless the payload says otherwise.
+	staff.Active = true
+	if err := json.NewDecoder(r.Body).Decode(&staff); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	staff.SalonID = salonID
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), salonID); err != nil {
+		authz.WriteError(w, err)
+		return
+	}
+
+	staffID, err := h.service.AddStaff(staff)
+	if err != nil {
+		writeStaffError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"staff_id": staffID})
+}
+
+// @Summary Update a staff member
+// @Description Update the details of a staff member and replace the services they perform
+// @Accept  json
+// @Produce  json
+// @Param staffID path int true "Staff ID"
+// @Param staff body models.Staff true "Update Staff Member"
+// @Success 200 {object} models.Staff
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Staff Member Not Found"
+// @Failure 409 {object} map[string]string "User Already Staff"
+// @Failure 500 {object} map[string]string
+// @Router /staff/{staffID} [put]
+func (h *SalonHandler) UpdateStaff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	staffID, err := strconv.Atoi(vars["staffID"])
+	if err != nil {
+		http.Error(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+
+	var staff models.Staff
+	if err := json.NewDecoder(r.Body).Decode(&staff); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	existing, ok := h.authorizeStaff(w, r, staffID)
+	if !ok {
+		return
+	}
+	// A staff member cannot be moved to another salon through an update.
+	staff.StaffID = existing.StaffID
+	staff.SalonID = existing.SalonID
+
+	if err := h.service.UpdateStaff(staff); err != nil {
+		writeStaffError(w, err)
+		return
+	}
+
+	updated, err := h.service.GetStaffByID(staffID)
+	if err != nil {
+		writeStaffError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Delete a staff member
+// @Description Delete a staff member. Their slots and appointments are kept without a staff member.
+// @Accept  json
+// @Produce  json
+// @Param staffID path int true "Staff ID"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "Staff Member Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /staff/{staffID} [delete]
+func (h *SalonHandler) DeleteStaff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	staffID, err := strconv.Atoi(vars["staffID"])
+	if err != nil {
+		http.Error(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.authorizeStaff(w, r, staffID); !ok {
+		return
+	}
+
+	if err := h.service.DeleteStaff(staffID); err != nil {
+		writeStaffError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Get a staff member by ID
+// @Description Retrieve a staff member and the services they perform
+// @Accept  json
+// @Produce  json
+// @Param staffID path int true "Staff ID"
+// @Success 200 {object} models.Staff
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Staff Member Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /staff/{staffID} [get]
+func (h *SalonHandler) GetStaffDetails(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	staffID, err := strconv.Atoi(vars["staffID"])
+	if err != nil {
+		http.Error(w, "Invalid staff ID", http.StatusBadRequest)
+		return
+	}
+
+	staff, err := h.service.GetStaffByID(staffID)
+	if err != nil {
+		writeStaffError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(staff)
+}
+
+// @Summary List the staff of a salon
+// @Description Retrieve all staff members of a specific salon
+// @Accept  json
+// @Produce  json
+// @Param salonID path int true "Salon ID"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Staff]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /salon/{salonID}/staff [get]
+func (h *SalonHandler) ListStaffBySalon(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	salonID, err := strconv.Atoi(vars["salonID"])
+	if err != nil {
+		http.Error(w, "Invalid salon ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.Parse(r, staffList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	staff, err := h.service.ListStaffBySalon(salonID, page)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(staff)
+}
+
+// @Summary List the staff performing a service
+// @Description Retrieve the active staff members who can perform a specific service, so customers can pick a stylist
+// @Accept  json
+// @Produce  json
+// @Param serviceID path int true "Service ID"
+// @Param limit query int false "Items per page (default 50, at most 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name or id, prefixed with - for descending order"
+// @Success 200 {object} pagination.Page[models.Staff]
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "Service Not Found"
+// @Failure 500 {object} map[string]string
+// @Router /service/{serviceID}/staff [get]
+func (h *SalonHandler) ListStaffByService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	serviceID, err := strconv.Atoi(vars["serviceID"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.Parse(r, staffList)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service, err := h.service.GetServiceByID(serviceID)
+	if err != nil {
+		switch err {
+		case ErrServiceNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !h.showCatalog(w, r, service.SalonID) {
+		return
+	}
+
+	staff, err := h.service.ListStaffByService(serviceID, page)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(staff)
+}
+
+// authorizeStaff loads a staff member and checks that the caller owns their salon.
+// It writes the error response itself and reports whether the request may proceed.
+func (h *SalonHandler) authorizeStaff(w http.ResponseWriter, r *http.Request, staffID int) (*models.Staff, bool) {
+	staff, err := h.service.GetStaffByID(staffID)
+	if err != nil {
+		writeStaffError(w, err)
+		return nil, false
+	}
+
+	if err := h.policy.AuthorizeSalonOwner(r.Context(), staff.SalonID); err != nil {
+		authz.WriteError(w, err)
+		return nil, false
+	}
+
+	return staff, true
+}
+
+// writeStaffError writes the HTTP response matching an error of the staff methods.
+func writeStaffError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrStaffNameRequired, ErrInvalidStaffService:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrStaffNotFound, ErrStaffUserNotFound, ErrSalonNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrStaffUserTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,270 @@
+package salon
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/pagination"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrStaffNotFound       = errors.New("staff member not found")
+	ErrStaffNameRequired   = errors.New("staff member name is required")
+	ErrStaffUserNotFound   = errors.New("user not found")
+	ErrStaffUserTaken      = errors.New("user is already a staff member of this salon")
+	ErrInvalidStaffService = errors.New("staff members can only perform services of their own salon")
+)
+
+// Constants for error messages.
+const (
+	ErrorStaffInsert = "Error inserting staff member"
+	ErrorStaffUpdate = "Error updating staff member"
+)
+
+// staffList describes how lists of staff members can be sorted and filtered.
+var staffList = pagination.Spec{
+	ID: pagination.Field{Column: "s.staff_id", JSON: "staff_id"},
+	Sorts: map[string]pagination.Field{
+		"id":   {Column: "s.staff_id", JSON: "staff_id"},
+		"name": {Column: "s.name", JSON: "name"},
+	},
+	DefaultSort: "name",
+	Filters: map[string]pagination.Filter{
+		"active": {Column: "s.active", Type: pagination.Bool},
+	},
+}
+
+// staffColumns selects a staff member along with the services they perform.
+const staffColumns = `
+	s.staff_id, s.salon_id, s.user_id, s.name, COALESCE(s.bio, ''), COALESCE(s.photo, ''), s.active,
+	COALESCE((SELECT array_agg(ss.service_id ORDER BY ss.service_id) FROM staff_services ss WHERE ss.staff_id = s.staff_id), '{}')
+`
+
+// AddStaff adds a staff member to a salon, along with the services they perform, and returns its ID.
+func (s *salonServiceImpl) AddStaff(staff models.Staff) (int, error) {
+	if staff.Name == "" {
+		return 0, ErrStaffNameRequired
+	}
+
+	const query = `
+		INSERT INTO staff(salon_id, user_id, name, bio, photo, active)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING staff_id
+	`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("%s: %v", ErrorStaffInsert, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var staffID int
+	err = tx.QueryRow(query, staff.SalonID, staff.UserID, staff.Name, staff.Bio, staff.Photo, staff.Active).Scan(&staffID)
+	if err != nil {
+		if staffErr := staffConstraintError(err); staffErr != nil {
+			return 0, staffErr
+		}
+		log.Printf("%s: %v", ErrorStaffInsert, err)
+		return 0, err
+	}
+
+	if err := setStaffServices(tx, staffID, staff.SalonID, staff.ServiceIDs); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorStaffInsert, err)
+		return 0, err
+	}
+
+	return staffID, nil
+}
+
+// UpdateStaff updates the details of a staff member and replaces the services they perform.
+func (s *salonServiceImpl) UpdateStaff(staff models.Staff) error {
+	if staff.Name == "" {
+		return ErrStaffNameRequired
+	}
+
+	const query = `
+		UPDATE staff SET user_id=$1, name=$2, bio=$3, photo=$4, active=$5
+		WHERE staff_id=$6 RETURNING salon_id
+	`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("%s: %v", ErrorStaffUpdate, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var salonID int
+	err = tx.QueryRow(query, staff.UserID, staff.Name, staff.Bio, staff.Photo, staff.Active, staff.StaffID).Scan(&salonID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrStaffNotFound
+		}
+		if staffErr := staffConstraintError(err); staffErr != nil {
+			return staffErr
+		}
+		log.Printf("%s: %v", ErrorStaffUpdate, err)
+		return err
+	}
+
+	if err := setStaffServices(tx, staff.StaffID, salonID, staff.ServiceIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%s: %v", ErrorStaffUpdate, err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStaff deletes a staff member. Their slots and bookings are kept without a staff member.
+func (s *salonServiceImpl) DeleteStaff(staffID int) error {
+	const query = `DELETE FROM staff WHERE staff_id=$1`
+
+	result, err := s.db.Exec(query, staffID)
+	if err != nil {
+		log.Printf("Error deleting staff member: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrStaffNotFound
+	}
+
+	return nil
+}
+
+// GetStaffByID retrieves a staff member by its ID.
+func (s *salonServiceImpl) GetStaffByID(staffID int) (*models.Staff, error) {
+	query := `SELECT ` + staffColumns + ` FROM staff s WHERE s.staff_id=$1`
+
+	staff, err := scanStaff(s.db.QueryRow(query, staffID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrStaffNotFound
+		}
+		log.Printf("Error retrieving staff member by ID: %v", err)
+		return nil, err
+	}
+
+	return staff, nil
+}
+
+// ListStaffBySalon retrieves a page of the staff members of a specific salon.
+func (s *salonServiceImpl) ListStaffBySalon(salonID int, page pagination.Query) (*pagination.Page[models.Staff], error) {
+	return s.listStaff(page, `SELECT `+staffColumns+` FROM staff s`, "s.salon_id=$1", salonID)
+}
+
+// ListStaffByService retrieves a page of the active staff members who can perform a specific service.
+func (s *salonServiceImpl) ListStaffByService(serviceID int, page pagination.Query) (*pagination.Page[models.Staff], error) {
+	selectFrom := `SELECT ` + staffColumns + ` FROM staff s JOIN staff_services sv ON sv.staff_id = s.staff_id`
+	return s.listStaff(page, selectFrom, "sv.service_id=$1 AND s.active", serviceID)
+}
+
+// listStaff executes a query selecting staffColumns and returns the page of staff members found.
+func (s *salonServiceImpl) listStaff(page pagination.Query, selectFrom, where string, params ...interface{}) (*pagination.Page[models.Staff], error) {
+	query, args := page.SQL(selectFrom, where, params...)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error listing staff members: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staff []models.Staff
+	for rows.Next() {
+		member, err := scanStaff(rows)
+		if err != nil {
+			log.Printf("Error scanning staff row: %v", err)
+			return nil, err
+		}
+		staff = append(staff, *member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(staff, page)
+}
+
+// scanStaff scans a row selected with staffColumns.
+func scanStaff(row interface{ Scan(...interface{}) error }) (*models.Staff, error) {
+	var staff models.Staff
+	var serviceIDs pq.Int64Array
+	err := row.Scan(&staff.StaffID, &staff.SalonID, &staff.UserID, &staff.Name, &staff.Bio, &staff.Photo, &staff.Active, &serviceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	staff.ServiceIDs = make([]int, len(serviceIDs))
+	for i, id := range serviceIDs {
+		staff.ServiceIDs[i] = int(id)
+	}
+	return &staff, nil
+}
+
+// setStaffServices replaces the services a staff member performs. Every service
+// has to be offered by the staff member's salon.
+func setStaffServices(tx *sql.Tx, staffID, salonID int, serviceIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM staff_services WHERE staff_id=$1`, staffID); err != nil {
+		log.Printf("Error clearing staff services: %v", err)
+		return err
+	}
+
+	unique := map[int]bool{}
+	ids := pq.Int64Array{}
+	for _, id := range serviceIDs {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	const query = `
+		INSERT INTO staff_services(staff_id, service_id)
+		SELECT $1, service_id FROM services WHERE service_id = ANY($2) AND salon_id=$3
+	`
+
+	result, err := tx.Exec(query, staffID, ids, salonID)
+	if err != nil {
+		log.Printf("Error setting staff services: %v", err)
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected != int64(len(ids)) {
+		return ErrInvalidStaffService
+	}
+
+	return nil
+}
+
+// staffConstraintError maps violations of the staff table constraints to errors
+// the caller can act on, or returns nil for any other error.
+func staffConstraintError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case "staff_user_id_fkey":
+		return ErrStaffUserNotFound
+	case "staff_salon_id_fkey":
+		return ErrSalonNotFound
+	case "staff_salon_id_user_id_key":
+		return ErrStaffUserTaken
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"bookmysalon/pkg/geo"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// SearchHandler represents the HTTP handler for searching salons.
+type SearchHandler struct {
+	service SearchService
+}
+
+// NewSearchHandler initializes and returns an instance of SearchHandler.
+func NewSearchHandler(s SearchService) *SearchHandler {
+	return &SearchHandler{service: s}
+}
+
+// @Summary Search salons and services
+// @Description Full-text search over salons and their services, with filters, facet counts and sorting
+// @Accept  json
+// @Produce  json
+// @Param q query string false "Text matched against salon and service names and descriptions"
+// @Param category query string false "Service category"
+// @Param min_price query number false "Lowest service price"
+// @Param max_price query number false "Highest service price"
+// @Param min_rating query number false "Lowest average rating of the salon"
+// @Param available_from query string false "Start of the period with an open slot, in the salon's local time"
+// @Param available_to query string false "End of the period with an open slot, in the salon's local time"
+// @Param lat query number false "Latitude to measure distances from"
+// @Param lng query number false "Longitude to measure distances from"
+// @Param radius_km query number false "Leave out salons further away than this"
+// @Param sort query string false "relevance, rating, price or distance"
+// @Param limit query int false "Salons per page (default 20, at most 100)"
+// @Param offset query int false "Salons to skip"
+// @Success 200 {object} models.SearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /search [get]
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := Query{
+		Text:          params.Get("q"),
+		Category:      params.Get("category"),
+		AvailableFrom: params.Get("available_from"),
+		AvailableTo:   params.Get("available_to"),
+		Sort:          params.Get("sort"),
+	}
+
+	var err error
+	if query.MinPrice, err = floatParam(params.Get("min_price")); err != nil {
+		http.Error(w, "Invalid min_price", http.StatusBadRequest)
+		return
+	}
+	if query.MaxPrice, err = floatParam(params.Get("max_price")); err != nil {
+		http.Error(w, "Invalid max_price", http.StatusBadRequest)
+		return
+	}
+	if query.MinRating, err = floatParam(params.Get("min_rating")); err != nil {
+		http.Error(w, "Invalid min_rating", http.StatusBadRequest)
+		return
+	}
+
+	lat, err := floatParam(params.Get("lat"))
+	if err != nil {
+		http.Error(w, "Invalid latitude", http.StatusBadRequest)
+		return
+	}
+	lng, err := floatParam(params.Get("lng"))
+	if err != nil {
+		http.Error(w, "Invalid longitude", http.StatusBadRequest)
+		return
+	}
+	if (lat == nil) != (lng == nil) {
+		http.Error(w, ErrInvalidCoordinates.Error(), http.StatusBadRequest)
+		return
+	}
+	if lat != nil {
+		query.Near = &geo.Point{Lat: *lat, Lng: *lng}
+	}
+
+	if value := params.Get("radius_km"); value != "" {
+		if query.RadiusKm, err = strconv.ParseFloat(value, 64); err != nil {
+			http.Error(w, "Invalid radius", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.service.Search(query)
+	if err != nil {
+		switch err {
+		case ErrInvalidSort, ErrInvalidPriceRange, ErrInvalidRating, ErrInvalidSlotRange,
+			ErrInvalidCoordinates, ErrInvalidRadius, ErrLocationRequired, ErrInvalidOffset:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// floatParam parses an optional number.
+func floatParam(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, strconv.ErrRange
+	}
+	return &f, nil
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/geo"
+)
+
+// Orders of the search results.
+const (
+	SortRelevance = "relevance"
+	SortRating    = "rating"
+	SortPrice     = "price"
+	SortDistance  = "distance"
+)
+
+// Query holds the filters and the order of a search. Zero values leave a
+// filter out.
+type Query struct {
+	// Text is matched against the names and descriptions of salons and services.
+	Text string
+
+	// Category restricts the services to one category, regardless of case.
+	Category string
+
+	// MinPrice and MaxPrice restrict the services to a price range.
+	MinPrice *float64
+	MaxPrice *float64
+
+	// MinRating restricts the salons to an average rating of at least this.
+	MinRating *float64
+
+	// AvailableFrom and AvailableTo restrict the services to those with an
+	// open slot between the two, given as the salon's local times.
+	AvailableFrom string
+	AvailableTo   string
+
+	// Near is the location distances are measured from. With RadiusKm, the
+	// salons further away are left out.
+	Near     *geo.Point
+	RadiusKm float64
+
+	// Sort is one of the Sort constants. It defaults to relevance when there
+	// is a text and to rating otherwise.
+	Sort string
+
+	Limit  int
+	Offset int
+}
+
+// SearchService represents the interface for searching salons and their services.
+type SearchService interface {
+	// Search the salons matching the query and count the facets of the result.
+	Search(query Query) (*models.SearchResult, error)
+}
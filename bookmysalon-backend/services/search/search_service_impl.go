@@ -0,0 +1,466 @@
+package search
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/geo"
+	"bookmysalon/pkg/schedule"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Limits of a search.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+	MaxRadiusKm  = 100.0
+)
+
+var (
+	ErrInvalidSort        = errors.New("sort must be relevance, rating, price or distance")
+	ErrInvalidPriceRange  = errors.New("prices must not be negative and min_price must not exceed max_price")
+	ErrInvalidRating      = errors.New("min_rating must be between 0 and 5")
+	ErrInvalidSlotRange   = errors.New("available_from and available_to must be given together as date and time, the end after the start")
+	ErrInvalidCoordinates = errors.New("lat and lng must be given together and lie within their ranges")
+	ErrInvalidRadius      = errors.New("radius_km needs lat and lng, and must be greater than 0 and at most 100")
+	ErrLocationRequired   = errors.New("sorting by distance needs lat and lng")
+	ErrInvalidOffset      = errors.New("offset must not be negative")
+)
+
+// Facets, named after the filter each one leaves out.
+const (
+	facetCategory = "category"
+	facetPrice    = "price"
+	facetRating   = "rating"
+)
+
+// priceRanges are the buckets of the price facet, by their lower bound.
+var priceRanges = []struct {
+	label string
+	min   float64
+}{
+	{"0-25", 0},
+	{"25-50", 25},
+	{"50-100", 50},
+	{"100+", 100},
+}
+
+// ratingThresholds are the buckets of the rating facet.
+var ratingThresholds = []int{4, 3, 2, 1}
+
+// salonColumns selects a salon of the alias s in the order scanSalonMatch expects.
+const salonColumns = `
+	s.salon_id, s.name, s.address, COALESCE(s.street, ''), COALESCE(s.city, ''), COALESCE(s.postal_code, ''),
+	COALESCE(s.country, ''), s.latitude, s.longitude, s.contact_details, s.photos, s.average_rating, s.timezone,
+	s.status, COALESCE(s.status_reason, '')
+`
+
+// orders maps the sort options to the ORDER BY clause of the results.
+var orders = map[string]string{
+	SortRelevance: "m.rank + COALESCE(best.rank, 0) DESC, s.average_rating DESC, s.salon_id",
+	SortRating:    "s.average_rating DESC, s.salon_id",
+	SortPrice:     "best.min_price ASC NULLS LAST, s.salon_id",
+	SortDistance:  "m.distance_km ASC NULLS LAST, s.salon_id",
+}
+
+// searchServiceImpl is the implementation of the SearchService interface.
+type searchServiceImpl struct {
+	db *sql.DB
+}
+
+// NewSearchService initializes and returns an instance of SearchService.
+func NewSearchService() (SearchService, error) {
+	db, err := database.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &searchServiceImpl{
+		db: db,
+	}, nil
+}
+
+// Search finds the salons matching the query. A salon matches when it passes
+// the salon filters (rating, distance) and offers at least one service
+// passing the service filters (category, price, open slot). The text may
+// match either the salon or the service.
+func (s *searchServiceImpl) Search(query Query) (*models.SearchResult, error) {
+	if err := query.normalize(); err != nil {
+		return nil, err
+	}
+
+	result := &models.SearchResult{Salons: []models.SalonMatch{}}
+
+	b := &builder{}
+	err := s.db.QueryRow(query.matches(b, "")+` SELECT COUNT(*) FROM matched_salons`, b.args...).Scan(&result.Total)
+	if err != nil {
+		log.Printf("Error counting search results: %v", err)
+		return nil, err
+	}
+
+	if result.Salons, err = s.salons(query); err != nil {
+		return nil, err
+	}
+	if err := s.services(query, result.Salons); err != nil {
+		return nil, err
+	}
+
+	if result.Facets.Categories, err = s.categoryFacet(query); err != nil {
+		return nil, err
+	}
+	if result.Facets.Prices, err = s.priceFacet(query); err != nil {
+		return nil, err
+	}
+	if result.Facets.Ratings, err = s.ratingFacet(query); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// salons retrieves the page of matching salons in the requested order.
+func (s *searchServiceImpl) salons(query Query) ([]models.SalonMatch, error) {
+	b := &builder{}
+	sqlQuery := query.matches(b, "") + `
+		SELECT ` + salonColumns + `, m.distance_km, best.min_price
+		FROM matched_salons m
+		JOIN salons s ON s.salon_id = m.salon_id
+		LEFT JOIN LATERAL (
+			SELECT MIN(ms.price) AS min_price, MAX(ms.rank) AS rank
+			FROM matched_services ms WHERE ms.salon_id = m.salon_id
+		) best ON TRUE
+		ORDER BY ` + orders[query.Sort] + `
+		LIMIT ` + b.param(query.Limit) + ` OFFSET ` + b.param(query.Offset)
+
+	rows, err := s.db.Query(sqlQuery, b.args...)
+	if err != nil {
+		log.Printf("Error searching salons: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	salons := []models.SalonMatch{}
+	for rows.Next() {
+		match := models.SalonMatch{Services: []models.Service{}}
+		if err := rows.Scan(scanSalonMatch(&match)...); err != nil {
+			log.Printf("Error scanning search result: %v", err)
+			return nil, err
+		}
+		salons = append(salons, match)
+	}
+
+	return salons, rows.Err()
+}
+
+// services fills in the matching services of the salons on a page.
+func (s *searchServiceImpl) services(query Query, salons []models.SalonMatch) error {
+	if len(salons) == 0 {
+		return nil
+	}
+
+	index := map[int]*models.SalonMatch{}
+	ids := pq.Int64Array{}
+	for i := range salons {
+		index[salons[i].SalonID] = &salons[i]
+		ids = append(ids, int64(salons[i].SalonID))
+	}
+
+	b := &builder{}
+	sqlQuery := query.matches(b, "") + `
+		SELECT sv.service_id, sv.salon_id, sv.name, COALESCE(sv.description, ''), sv.duration,
+			sv.price, COALESCE(sv.category, ''), sv.buffer_before, sv.buffer_after
+		FROM matched_services ms
+		JOIN services sv ON sv.service_id = ms.service_id
+		WHERE ms.salon_id = ANY(` + b.param(ids) + `)
+		ORDER BY ms.rank DESC, sv.price, sv.service_id`
+
+	rows, err := s.db.Query(sqlQuery, b.args...)
+	if err != nil {
+		log.Printf("Error searching services: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var service models.Service
+		if err := rows.Scan(&service.ServiceID, &service.SalonID, &service.Name, &service.Description, &service.Duration, &service.Price, &service.Category,
+			&service.BufferBefore, &service.BufferAfter); err != nil {
+			log.Printf("Error scanning service row: %v", err)
+			return err
+		}
+		if match, ok := index[service.SalonID]; ok {
+			match.Services = append(match.Services, service)
+		}
+	}
+
+	return rows.Err()
+}
+
+// categoryFacet counts the matching salons per service category. Salons name
+// their own categories, so the counts go by the lowercase name.
+func (s *searchServiceImpl) categoryFacet(query Query) ([]models.FacetCount, error) {
+	b := &builder{}
+	sqlQuery := query.matches(b, facetCategory) + `
+		SELECT LOWER(ms.category), COUNT(DISTINCT ms.salon_id)
+		FROM matched_services ms
+		JOIN matched_salons m ON m.salon_id = ms.salon_id
+		WHERE ms.category IS NOT NULL
+		GROUP BY LOWER(ms.category)
+		ORDER BY 2 DESC, 1`
+
+	rows, err := s.db.Query(sqlQuery, b.args...)
+	if err != nil {
+		log.Printf("Error counting categories: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.FacetCount{}
+	for rows.Next() {
+		var count models.FacetCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			log.Printf("Error scanning category count: %v", err)
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// priceFacet counts the matching salons per price range of their services.
+func (s *searchServiceImpl) priceFacet(query Query) ([]models.FacetCount, error) {
+	bucket := "CASE"
+	for i := len(priceRanges) - 1; i >= 0; i-- {
+		bucket += " WHEN ms.price >= " + strconv.FormatFloat(priceRanges[i].min, 'f', -1, 64) + " THEN '" + priceRanges[i].label + "'"
+	}
+	bucket += " END"
+
+	b := &builder{}
+	sqlQuery := query.matches(b, facetPrice) + `
+		SELECT ` + bucket + `, COUNT(DISTINCT ms.salon_id)
+		FROM matched_services ms
+		JOIN matched_salons m ON m.salon_id = ms.salon_id
+		WHERE ms.price >= 0
+		GROUP BY 1`
+
+	rows, err := s.db.Query(sqlQuery, b.args...)
+	if err != nil {
+		log.Printf("Error counting price ranges: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]int{}
+	for rows.Next() {
+		var label string
+		var count int
+		if err := rows.Scan(&label, &count); err != nil {
+			log.Printf("Error scanning price range count: %v", err)
+			return nil, err
+		}
+		found[label] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := []models.FacetCount{}
+	for _, r := range priceRanges {
+		counts = append(counts, models.FacetCount{Value: r.label, Count: found[r.label]})
+	}
+	return counts, nil
+}
+
+// ratingFacet counts the matching salons rated at least each threshold.
+func (s *searchServiceImpl) ratingFacet(query Query) ([]models.FacetCount, error) {
+	var filters []string
+	for _, threshold := range ratingThresholds {
+		filters = append(filters, "COUNT(*) FILTER (WHERE m.average_rating >= "+strconv.Itoa(threshold)+")")
+	}
+
+	b := &builder{}
+	sqlQuery := query.matches(b, facetRating) + ` SELECT ` + strings.Join(filters, ", ") + ` FROM matched_salons m`
+
+	found := make([]int, len(ratingThresholds))
+	dest := make([]interface{}, len(found))
+	for i := range found {
+		dest[i] = &found[i]
+	}
+	if err := s.db.QueryRow(sqlQuery, b.args...).Scan(dest...); err != nil {
+		log.Printf("Error counting ratings: %v", err)
+		return nil, err
+	}
+
+	counts := []models.FacetCount{}
+	for i, threshold := range ratingThresholds {
+		counts = append(counts, models.FacetCount{Value: strconv.Itoa(threshold) + "+", Count: found[i]})
+	}
+	return counts, nil
+}
+
+// normalize validates the query and fills in its defaults.
+func (q *Query) normalize() error {
+	q.Text = strings.TrimSpace(q.Text)
+	q.Category = strings.ToLower(strings.TrimSpace(q.Category))
+
+	if (q.MinPrice != nil && *q.MinPrice < 0) || (q.MaxPrice != nil && *q.MaxPrice < 0) ||
+		(q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice) {
+		return ErrInvalidPriceRange
+	}
+	if q.MinRating != nil && (*q.MinRating < 0 || *q.MinRating > 5) {
+		return ErrInvalidRating
+	}
+
+	if q.AvailableFrom != "" || q.AvailableTo != "" {
+		from, err := schedule.ParseTime(q.AvailableFrom, time.UTC)
+		if err != nil {
+			return ErrInvalidSlotRange
+		}
+		to, err := schedule.ParseTime(q.AvailableTo, time.UTC)
+		if err != nil || !to.After(from) {
+			return ErrInvalidSlotRange
+		}
+		// Slots are stored as local times of the salon, so compare wall clocks.
+		q.AvailableFrom = from.Format(localTimeLayout)
+		q.AvailableTo = to.Format(localTimeLayout)
+	}
+
+	if q.Near != nil && !q.Near.Valid() {
+		return ErrInvalidCoordinates
+	}
+	if q.RadiusKm != 0 && (q.Near == nil || !(q.RadiusKm > 0 && q.RadiusKm <= MaxRadiusKm)) {
+		return ErrInvalidRadius
+	}
+
+	if q.Sort == "" {
+		q.Sort = SortRating
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		}
+	}
+	if _, ok := orders[q.Sort]; !ok {
+		return ErrInvalidSort
+	}
+	if q.Sort == SortDistance && q.Near == nil {
+		return ErrLocationRequired
+	}
+
+	if q.Limit <= 0 || q.Limit > MaxLimit {
+		q.Limit = DefaultLimit
+	}
+	if q.Offset < 0 {
+		return ErrInvalidOffset
+	}
+	return nil
+}
+
+// localTimeLayout formats a time without its UTC offset.
+const localTimeLayout = "2006-01-02 15:04:05"
+
+// matches returns the common table expressions matched_services and
+// matched_salons of the query, leaving out the filter of the facet skip.
+func (q Query) matches(b *builder, skip string) string {
+	var serviceFilters []string
+	if q.Category != "" && skip != facetCategory {
+		serviceFilters = append(serviceFilters, "LOWER(sv.category) = "+b.param(q.Category))
+	}
+	if skip != facetPrice {
+		if q.MinPrice != nil {
+			serviceFilters = append(serviceFilters, "sv.price >= "+b.param(*q.MinPrice))
+		}
+		if q.MaxPrice != nil {
+			serviceFilters = append(serviceFilters, "sv.price <= "+b.param(*q.MaxPrice))
+		}
+	}
+	if q.AvailableFrom != "" {
+		serviceFilters = append(serviceFilters, `EXISTS (
+			SELECT 1 FROM availabilities a
+			WHERE a.service_id = sv.service_id AND a.status = 'available'
+				AND a.start_date_time >= `+b.param(q.AvailableFrom)+` AND a.end_date_time <= `+b.param(q.AvailableTo)+`
+		)`)
+	}
+	hasServiceFilters := len(serviceFilters) > 0
+
+	// Only live salons are found.
+	const approved = "s.status = '" + models.SalonStatusApproved + "'"
+	serviceFilters = append(serviceFilters, approved)
+
+	serviceRank, salonRank := "0", "0"
+	var tsquery string
+	if q.Text != "" {
+		tsquery = "websearch_to_tsquery('english', " + b.param(q.Text) + ")"
+		serviceFilters = append(serviceFilters, "(sv.search_vector @@ "+tsquery+" OR s.search_vector @@ "+tsquery+")")
+		serviceRank = "ts_rank(sv.search_vector, " + tsquery + ")"
+		salonRank = "ts_rank(s.search_vector, " + tsquery + ")"
+	}
+
+	salonFilters := []string{approved}
+	if q.MinRating != nil && skip != facetRating {
+		salonFilters = append(salonFilters, "s.average_rating >= "+b.param(*q.MinRating))
+	}
+	distance := "NULL::DOUBLE PRECISION"
+	if q.Near != nil {
+		distance = geo.DistanceSQL("s.latitude", "s.longitude", b.param(q.Near.Lat), b.param(q.Near.Lng))
+		if q.RadiusKm > 0 {
+			// The bounding box lets the index narrow down the candidates.
+			box := geo.BoundingBox(*q.Near, q.RadiusKm)
+			salonFilters = append(salonFilters,
+				"s.latitude BETWEEN "+b.param(box.MinLat)+" AND "+b.param(box.MaxLat),
+				"s.longitude BETWEEN "+b.param(box.MinLng)+" AND "+b.param(box.MaxLng),
+				distance+" <= "+b.param(q.RadiusKm))
+		}
+	}
+
+	const offersService = "EXISTS (SELECT 1 FROM matched_services ms WHERE ms.salon_id = s.salon_id)"
+	switch {
+	case hasServiceFilters:
+		salonFilters = append(salonFilters, offersService)
+	case tsquery != "":
+		salonFilters = append(salonFilters, "(s.search_vector @@ "+tsquery+" OR "+offersService+")")
+	}
+
+	return `
+		WITH matched_services AS (
+			SELECT sv.service_id, sv.salon_id, sv.price, sv.category, ` + serviceRank + ` AS rank
+			FROM services sv
+			JOIN salons s ON s.salon_id = sv.salon_id
+			` + where(serviceFilters) + `
+		), matched_salons AS (
+			SELECT s.salon_id, s.average_rating, ` + distance + ` AS distance_km, ` + salonRank + ` AS rank
+			FROM salons s
+			` + where(salonFilters) + `
+		)`
+}
+
+// builder collects the parameters of a query.
+type builder struct {
+	args []interface{}
+}
+
+// param adds a parameter and returns its placeholder.
+func (b *builder) param(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// scanSalonMatch returns the scan destinations of a search result row.
+func scanSalonMatch(m *models.SalonMatch) []interface{} {
+	return []interface{}{
+		&m.SalonID, &m.Name, &m.Address, &m.Street, &m.City, &m.PostalCode, &m.Country,
+		&m.Latitude, &m.Longitude, &m.ContactDetails, &m.Photos, &m.AverageRating, &m.Timezone,
+		&m.Status, &m.StatusReason, &m.DistanceKm, &m.MinPrice,
+	}
+}
@@ -0,0 +1,197 @@
+package user
+
+import (
+	"archive/zip"
+	"bookmysalon/models"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DataExport is everything stored about a user, as handed out on request.
+type DataExport struct {
+	ExportedAt   string               `json:"exported_at"`
+	Profile      *models.User         `json:"profile"`
+	Appointments []models.Appointment `json:"appointments"`
+	Reviews      []models.Review      `json:"reviews"`
+	Transactions []models.Transaction `json:"transactions"`
+	Invoices     []models.Invoice     `json:"invoices"`
+	Identities   []ExportedIdentity   `json:"identities"`
+	Logins       []ExportedLogin      `json:"logins"`
+}
+
+// ExportedIdentity is an external account linked to the user.
+type ExportedIdentity struct {
+	Provider  string `json:"provider"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+	LastLogin string `json:"last_login"`
+}
+
+// ExportedLogin is a recorded login attempt on the user's account.
+type ExportedLogin struct {
+	IPAddress   string `json:"ip_address"`
+	Outcome     string `json:"outcome"`
+	AttemptedAt string `json:"attempted_at"`
+}
+
+func (us *UserServiceImpl) ExportUserData(db *sql.DB, userID int) (*DataExport, error) {
+	profile, err := us.FetchUserProfile(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &DataExport{
+		ExportedAt:   time.Now().UTC().Format(time.RFC3339),
+		Profile:      profile,
+		Appointments: []models.Appointment{},
+		Reviews:      []models.Review{},
+		Transactions: []models.Transaction{},
+		Invoices:     []models.Invoice{},
+		Identities:   []ExportedIdentity{},
+		Logins:       []ExportedLogin{},
+	}
+
+	queries := []struct {
+		query string
+		scan  func(*sql.Rows) error
+	}{
+		{
+			`SELECT appointment_id, user_id, salon_id, service_id, staff_id, variant_id, availability_id, date_time, COALESCE(status, ''), COALESCE(notification_settings, ''),
+				COALESCE(total_price, 0), total_duration, reschedule_count, cancellation_fee, refund_amount,
+				date_time + COALESCE(total_duration, (SELECT s.duration FROM services s WHERE s.service_id = appointments.service_id), INTERVAL '0'),
+				ARRAY(SELECT aa.addon_id FROM appointment_addons aa WHERE aa.appointment_id = appointments.appointment_id ORDER BY aa.addon_id)
+			FROM appointments WHERE user_id=$1 ORDER BY date_time;`,
+			func(rows *sql.Rows) error {
+				var a models.Appointment
+				var addOnIDs pq.Int64Array
+				if err := rows.Scan(&a.AppointmentID, &a.UserID, &a.SalonID, &a.ServiceID, &a.StaffID, &a.VariantID, &a.AvailabilityID, &a.DateTime, &a.Status, &a.NotificationSettings,
+					&a.TotalPrice, &a.TotalDuration, &a.RescheduleCount, &a.CancellationFee, &a.RefundAmount, &a.EndDateTime, &addOnIDs); err != nil {
+					return err
+				}
+				a.AddOnIDs = make([]int, len(addOnIDs))
+				for i, id := range addOnIDs {
+					a.AddOnIDs[i] = int(id)
+				}
+				export.Appointments = append(export.Appointments, a)
+				return nil
+			},
+		},
+		{
+			`SELECT review_id, user_id, salon_id, rating, COALESCE(comment, ''), date_posted
+			FROM reviews WHERE user_id=$1 ORDER BY date_posted;`,
+			func(rows *sql.Rows) error {
+				var r models.Review
+				if err := rows.Scan(&r.ReviewID, &r.UserID, &r.SalonID, &r.Rating, &r.Comment, &r.DatePosted); err != nil {
+					return err
+				}
+				export.Reviews = append(export.Reviews, r)
+				return nil
+			},
+		},
+		{
+			`SELECT transaction_id, user_id, amount, date, COALESCE(status, ''), COALESCE(payment_method, '')
+			FROM transactions WHERE user_id=$1 ORDER BY date;`,
+			func(rows *sql.Rows) error {
+				var t models.Transaction
+				if err := rows.Scan(&t.TransactionID, &t.UserID, &t.Amount, &t.Date, &t.Status, &t.PaymentMethod); err != nil {
+					return err
+				}
+				export.Transactions = append(export.Transactions, t)
+				return nil
+			},
+		},
+		{
+			`SELECT i.invoice_id, i.transaction_id, COALESCE(i.details, ''), i.date_issued
+			FROM invoices i JOIN transactions t ON t.transaction_id = i.transaction_id
+			WHERE t.user_id=$1 ORDER BY i.date_issued;`,
+			func(rows *sql.Rows) error {
+				var i models.Invoice
+				if err := rows.Scan(&i.InvoiceID, &i.TransactionID, &i.Details, &i.DateIssued); err != nil {
+					return err
+				}
+				export.Invoices = append(export.Invoices, i)
+				return nil
+			},
+		},
+		{
+			`SELECT provider, COALESCE(email, ''), created_at, COALESCE(TO_CHAR(last_login, 'YYYY-MM-DD"T"HH24:MI:SS'), '')
+			FROM identities WHERE user_id=$1 ORDER BY created_at;`,
+			func(rows *sql.Rows) error {
+				var i ExportedIdentity
+				if err := rows.Scan(&i.Provider, &i.Email, &i.CreatedAt, &i.LastLogin); err != nil {
+					return err
+				}
+				export.Identities = append(export.Identities, i)
+				return nil
+			},
+		},
+		{
+			`SELECT COALESCE(ip_address, ''), outcome, attempted_at
+			FROM login_attempts WHERE user_id=$1 ORDER BY attempted_at;`,
+			func(rows *sql.Rows) error {
+				var l ExportedLogin
+				if err := rows.Scan(&l.IPAddress, &l.Outcome, &l.AttemptedAt); err != nil {
+					return err
+				}
+				export.Logins = append(export.Logins, l)
+				return nil
+			},
+		},
+	}
+
+	for _, q := range queries {
+		if err := scanAll(db, q.query, userID, q.scan); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// WriteZip writes the export as a ZIP archive with one JSON file per section.
+func (e *DataExport) WriteZip(w io.Writer) error {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", e.Profile},
+		{"appointments.json", e.Appointments},
+		{"reviews.json", e.Reviews},
+		{"transactions.json", e.Transactions},
+		{"invoices.json", e.Invoices},
+		{"identities.json", e.Identities},
+		{"logins.json", e.Logins},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func scanAll(db *sql.DB, query string, userID int, scan func(*sql.Rows) error) error {
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
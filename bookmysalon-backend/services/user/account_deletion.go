@@ -0,0 +1,230 @@
+package user
+
+import (
+	"bookmysalon/pkg/database"
+	"bookmysalon/pkg/mailer"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// AccountDeletionGracePeriod is how long a deleted account can still be restored.
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+	// accountPurgeBatchSize bounds the number of accounts anonymized per transaction.
+	accountPurgeBatchSize = 100
+)
+
+// DeleteUserAccount schedules the account for deletion. The user is logged out
+// everywhere and can restore the account during the grace period; after that
+// PurgeDeletedAccounts scrubs the personal data.
+func (us *UserServiceImpl) DeleteUserAccount(db *sql.DB, userID int) error {
+	// A salon must not be left without anyone who can manage it.
+	const soleOwnerQuery = `
+		SELECT EXISTS(
+			SELECT 1 FROM salon_members m
+			WHERE m.user_id=$1 AND m.role='owner' AND NOT EXISTS(
+				SELECT 1 FROM salon_members o
+				WHERE o.salon_id=m.salon_id AND o.role='owner' AND o.user_id<>$1))
+	`
+	var soleOwner bool
+	if err := db.QueryRow(soleOwnerQuery, userID).Scan(&soleOwner); err != nil {
+		return err
+	}
+	if soleOwner {
+		return errors.New(SoleSalonOwnerMessage)
+	}
+
+	var email string
+	query := "UPDATE users SET deleted_at=NOW() WHERE id=$1 AND deleted_at IS NULL RETURNING email;"
+	if err := db.QueryRow(query, userID).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New(UserNotFoundMessage)
+		}
+		return err
+	}
+
+	if err := us.Sessions.RevokeAll(userID); err != nil {
+		return err
+	}
+
+	// The deletion stands even if the notice cannot be sent.
+	err := us.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Your BookMySalon account will be deleted",
+		Body: fmt.Sprintf("Your account has been scheduled for deletion. It will be permanently anonymized in %d days.\n\n"+
+			"If this was a mistake, you can restore it until then by logging in at /account/restore.\n",
+			int(AccountDeletionGracePeriod.Hours()/24)),
+	})
+	if err != nil {
+		log.Printf("Error sending account deletion notice: %v", err)
+	}
+	return nil
+}
+
+// RestoreUserAccount cancels a pending deletion. The user proves ownership with
+// their password, which is subject to the same throttling as a login.
+func (us *UserServiceImpl) RestoreUserAccount(db *sql.DB, username, password, clientIP string) error {
+	if err := checkLoginThrottle(db, username, clientIP); err != nil {
+		return err
+	}
+
+	var userID int
+	var hashedPassword string
+	query := `SELECT id, password FROM users
+		WHERE username=$1 AND deleted_at IS NOT NULL AND anonymized_at IS NULL;`
+	err := db.QueryRow(query, username).Scan(&userID, &hashedPassword)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		recordLoginAttempt(db, username, 0, clientIP, LoginOutcomeInvalidCredentials)
+		return errors.New(InvalidCredentialsMessage)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		recordLoginAttempt(db, username, userID, clientIP, LoginOutcomeInvalidCredentials)
+		return errors.New(InvalidCredentialsMessage)
+	}
+
+	_, err = db.Exec("UPDATE users SET deleted_at=NULL WHERE id=$1 AND anonymized_at IS NULL;", userID)
+	return err
+}
+
+// PurgeDeletedAccounts anonymizes every account whose grace period has ended
+// and returns how many were processed. Bookings, reviews and transactions keep
+// pointing at the anonymized user, so salons keep their booking history and ratings.
+func (us *UserServiceImpl) PurgeDeletedAccounts(db *sql.DB) (int, error) {
+	purged := 0
+	for {
+		n, err := purgeDeletedAccountsBatch(db)
+		purged += n
+		if err != nil || n < accountPurgeBatchSize {
+			return purged, err
+		}
+	}
+}
+
+func purgeDeletedAccountsBatch(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several instances purge concurrently without blocking each other.
+	query := `SELECT id, username FROM users
+		WHERE deleted_at < NOW() - $1 * INTERVAL '1 second' AND anonymized_at IS NULL
+		ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED;`
+	rows, err := tx.Query(query, int64(AccountDeletionGracePeriod.Seconds()), accountPurgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type account struct {
+		id       int
+		username string
+	}
+	var accounts []account
+	for rows.Next() {
+		var a account
+		if err := rows.Scan(&a.id, &a.username); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		accounts = append(accounts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, a := range accounts {
+		if err := anonymizeUser(tx, a.id, a.username); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(accounts), nil
+}
+
+// anonymizeUser scrubs the personal data of a user. Free-text fields that may
+// contain personal data are emptied; ratings and bookings stay for the salons.
+func anonymizeUser(tx *sql.Tx, userID int, username string) error {
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM login_attempts WHERE user_id=$1 OR username=$2;", []interface{}{userID, username}},
+		{"DELETE FROM identities WHERE user_id=$1;", []interface{}{userID}},
+		{"DELETE FROM oidc_login_states WHERE link_user_id=$1;", []interface{}{userID}},
+		{"DELETE FROM user_tokens WHERE user_id=$1;", []interface{}{userID}},
+		{"DELETE FROM user_recovery_codes WHERE user_id=$1;", []interface{}{userID}},
+		{"DELETE FROM refresh_tokens WHERE user_id=$1;", []interface{}{userID}},
+		{"DELETE FROM salon_members WHERE user_id=$1;", []interface{}{userID}},
+		// The files of the photos are queued for removal by the database.
+		{"DELETE FROM photos WHERE user_id=$1;", []interface{}{userID}},
+		{"UPDATE staff SET user_id=NULL WHERE user_id=$1;", []interface{}{userID}},
+		{"UPDATE reviews SET comment='' WHERE user_id=$1;", []interface{}{userID}},
+		{"UPDATE appointments SET notification_settings='' WHERE user_id=$1;", []interface{}{userID}},
+		{`UPDATE users SET
+			username='deleted-user-' || id,
+			email='deleted-user-' || id || '@deleted.invalid',
+			password='!', -- not a bcrypt hash, so no password ever matches
+			email_verified=FALSE,
+			profile_image=NULL,
+			display_name=NULL,
+			phone_number=NULL,
+			marketing_consent=FALSE,
+			marketing_consent_updated_at=NULL,
+			totp_secret=NULL,
+			totp_enabled=FALSE,
+			totp_last_step=NULL,
+			last_login=NULL,
+			anonymized_at=NOW()
+		WHERE id=$1;`, []interface{}{userID}},
+	}
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartAccountPurger runs PurgeDeletedAccounts in the background at the given interval.
+func StartAccountPurger(service UserService, interval time.Duration) {
+	go func() {
+		for {
+			purgeDeletedAccounts(service)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeDeletedAccounts(service UserService) {
+	db, err := database.Connect()
+	if err != nil {
+		log.Printf("Error connecting to database for account purge: %v", err)
+		return
+	}
+	defer db.Close()
+
+	n, err := service.PurgeDeletedAccounts(db)
+	if err != nil {
+		log.Printf("Error purging deleted accounts: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Anonymized %d deleted accounts", n)
+	}
+}
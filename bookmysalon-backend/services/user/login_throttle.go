@@ -0,0 +1,130 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Outcomes recorded in login_attempts.
+const (
+	LoginOutcomeSuccess            = "success"
+	LoginOutcomeInvalidCredentials = "invalid_credentials"
+	LoginOutcomeInvalidMFACode     = "invalid_mfa_code"
+	LoginOutcomeThrottled          = "throttled"
+)
+
+// loginFailureOutcomes are the outcomes that count towards throttling.
+var loginFailureOutcomes = pq.Array([]string{LoginOutcomeInvalidCredentials, LoginOutcomeInvalidMFACode})
+
+// Thresholds for failed login attempts. Failures beyond the free attempts
+// double the wait before the next attempt; reaching the lockout threshold
+// blocks further attempts for LoginLockoutDuration.
+const (
+	LoginFailureWindow   = 15 * time.Minute
+	LoginLockoutDuration = 15 * time.Minute
+	LoginBaseDelay       = time.Second
+	LoginMaxDelay        = time.Minute
+
+	AccountFreeAttempts = 3
+	AccountLockoutAfter = 10
+	IPFreeAttempts      = 10
+	IPLockoutAfter      = 30
+)
+
+// LoginThrottledError is returned while an account or client IP has to wait before trying again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return LoginThrottledMessage
+}
+
+// checkLoginThrottle returns a *LoginThrottledError if the account or the
+// client IP has failed too many times recently.
+func checkLoginThrottle(db *sql.DB, username, clientIP string) error {
+	// Failures of an account are forgiven by its last successful login.
+	const accountQuery = `
+		SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM NOW() - MAX(attempted_at)), 0)
+		FROM login_attempts
+		WHERE username=$1 AND outcome = ANY($2) AND attempted_at > NOW() - $3 * INTERVAL '1 second'
+			AND attempted_at > COALESCE(
+				(SELECT MAX(attempted_at) FROM login_attempts WHERE username=$1 AND outcome=$4),
+				'epoch'::timestamp)
+	`
+	const ipQuery = `
+		SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM NOW() - MAX(attempted_at)), 0)
+		FROM login_attempts
+		WHERE ip_address=$1 AND outcome = ANY($2) AND attempted_at > NOW() - $3 * INTERVAL '1 second'
+	`
+
+	window := int64(LoginFailureWindow.Seconds())
+
+	var failures int
+	var sinceLast float64
+	if err := db.QueryRow(accountQuery, username, loginFailureOutcomes, window, LoginOutcomeSuccess).Scan(&failures, &sinceLast); err != nil {
+		return err
+	}
+	if wait := loginRetryAfter(failures, secondsToDuration(sinceLast), AccountFreeAttempts, AccountLockoutAfter); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+
+	if clientIP == "" {
+		return nil
+	}
+	if err := db.QueryRow(ipQuery, clientIP, loginFailureOutcomes, window).Scan(&failures, &sinceLast); err != nil {
+		return err
+	}
+	if wait := loginRetryAfter(failures, secondsToDuration(sinceLast), IPFreeAttempts, IPLockoutAfter); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// loginRetryAfter returns how long a client still has to wait, given the
+// number of recent failures and the time since the last one.
+func loginRetryAfter(failures int, sinceLast time.Duration, freeAttempts, lockoutAfter int) time.Duration {
+	if failures < freeAttempts {
+		return 0
+	}
+
+	delay := LoginLockoutDuration
+	if failures < lockoutAfter {
+		delay = LoginBaseDelay << uint(failures-freeAttempts)
+		if delay > LoginMaxDelay {
+			delay = LoginMaxDelay
+		}
+	}
+
+	if wait := delay - sinceLast; wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// recordLoginAttempt stores an audit row for a login attempt. userID is 0 when
+// the username is unknown.
+func recordLoginAttempt(db *sql.DB, username string, userID int, clientIP, outcome string) {
+	const query = `
+		INSERT INTO login_attempts(username, user_id, ip_address, outcome)
+		VALUES($1, NULLIF($2, 0), NULLIF($3, ''), $4)
+	`
+
+	if _, err := db.Exec(query, username, userID, clientIP, outcome); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// retryAfterHeader formats a wait as the value of a Retry-After header, rounded up to whole seconds.
+func retryAfterHeader(wait time.Duration) string {
+	return fmt.Sprintf("%d", int64((wait+time.Second-1)/time.Second))
+}
@@ -0,0 +1,305 @@
+package user
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/oidc"
+	"bookmysalon/pkg/session"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OIDCStateTTL is how long the user has to complete the login at the provider.
+const OIDCStateTTL = 10 * time.Minute
+
+// oidcUser is the user an external identity resolves to.
+type oidcUser struct {
+	models.User
+	mfaRequired bool
+	deleted     bool
+}
+
+// StartOIDCLogin prepares an authorization request and returns the provider URL
+// to send the user to. linkUserID is the logged-in user when an identity is
+// being linked to an existing account, and 0 for a login.
+func (us *UserServiceImpl) StartOIDCLogin(db *sql.DB, providerName string, linkUserID int) (string, error) {
+	provider, ok := us.OIDCProviders[providerName]
+	if !ok {
+		return "", errors.New(UnknownProviderMessage)
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, codeVerifier)
+	if err != nil {
+		log.Printf("Error preparing %s login: %v", providerName, err)
+		return "", errors.New(OIDCLoginFailedMessage)
+	}
+
+	// Expired states are cleaned up whenever a new login starts.
+	if _, err := db.Exec("DELETE FROM oidc_login_states WHERE expires_at < NOW();"); err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, link_user_id, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), NOW() + $6 * INTERVAL '1 second');`
+	if _, err := db.Exec(query, state, providerName, nonce, codeVerifier, linkUserID, int64(OIDCStateTTL.Seconds())); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// CompleteOIDCLogin finishes a login started by StartOIDCLogin. The external
+// identity is resolved to a user, who is created on first login. Users with
+// two-factor authentication still get an MFA challenge.
+func (us *UserServiceImpl) CompleteOIDCLogin(db *sql.DB, providerName, code, state, clientIP string) (*session.TokenPair, *MFAChallenge, error) {
+	claims, linkUserID, err := us.verifyOIDCCallback(db, providerName, code, state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if linkUserID != 0 {
+		return nil, nil, errors.New(InvalidOIDCStateMessage)
+	}
+
+	u, err := findOIDCUser(db, providerName, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.deleted {
+		return nil, nil, errors.New(AccountDeletedMessage)
+	}
+
+	if _, err := db.Exec("UPDATE identities SET last_login=NOW(), email=NULLIF($1, '') WHERE provider=$2 AND subject=$3;", claims.Email, providerName, claims.Subject); err != nil {
+		log.Printf("Error updating identity last login: %v", err)
+	}
+
+	if u.TwoFactorEnabled || u.mfaRequired {
+		challenge, err := newMFAChallenge(&u.User, !u.TwoFactorEnabled)
+		return nil, challenge, err
+	}
+
+	recordLoginAttempt(db, u.Username, u.ID, clientIP, LoginOutcomeSuccess)
+	if _, err := db.Exec("UPDATE users SET last_login=NOW() WHERE id=$1;", u.ID); err != nil {
+		log.Printf("Error updating last login: %v", err)
+	}
+
+	tokens, err := us.IssueTokens(&u.User)
+	return tokens, nil, err
+}
+
+// LinkOIDCIdentity finishes a link started by StartOIDCLogin for the given user.
+func (us *UserServiceImpl) LinkOIDCIdentity(db *sql.DB, providerName, code, state string, userID int) error {
+	claims, linkUserID, err := us.verifyOIDCCallback(db, providerName, code, state)
+	if err != nil {
+		return err
+	}
+	if linkUserID != userID {
+		return errors.New(InvalidOIDCStateMessage)
+	}
+
+	query := "INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, NULLIF($4, ''));"
+	if _, err := db.Exec(query, userID, providerName, claims.Subject, claims.Email); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New(IdentityAlreadyLinkedMessage)
+		}
+		return err
+	}
+	return nil
+}
+
+func (us *UserServiceImpl) UnlinkOIDCIdentity(db *sql.DB, userID int, providerName string) error {
+	result, err := db.Exec("DELETE FROM identities WHERE user_id=$1 AND provider=$2;", userID, providerName)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return errors.New(IdentityNotFoundMessage)
+	}
+	return nil
+}
+
+// verifyOIDCCallback consumes the state, exchanges the code and verifies the
+// ID token. It returns the claims and the user the state was created for, if any.
+func (us *UserServiceImpl) verifyOIDCCallback(db *sql.DB, providerName, code, state string) (*oidc.IDTokenClaims, int, error) {
+	provider, ok := us.OIDCProviders[providerName]
+	if !ok {
+		return nil, 0, errors.New(UnknownProviderMessage)
+	}
+
+	var nonce, codeVerifier string
+	var linkUserID sql.NullInt64
+	query := `DELETE FROM oidc_login_states
+		WHERE state=$1 AND provider=$2 AND expires_at > NOW()
+		RETURNING nonce, code_verifier, link_user_id;`
+	if err := db.QueryRow(query, state, providerName).Scan(&nonce, &codeVerifier, &linkUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, errors.New(InvalidOIDCStateMessage)
+		}
+		return nil, 0, err
+	}
+
+	ctx := context.Background()
+	rawIDToken, err := provider.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		log.Printf("Error exchanging %s authorization code: %v", providerName, err)
+		return nil, 0, errors.New(OIDCLoginFailedMessage)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		log.Printf("Error verifying %s ID token: %v", providerName, err)
+		return nil, 0, errors.New(OIDCLoginFailedMessage)
+	}
+	return claims, int(linkUserID.Int64), nil
+}
+
+// findOIDCUser returns the user linked to an external identity. Unknown
+// identities are linked to the account with the same verified email address,
+// or get a new account.
+func findOIDCUser(db *sql.DB, providerName string, claims *oidc.IDTokenClaims) (*oidcUser, error) {
+	const userColumns = `u.id, u.username, u.role, u.email_verified, u.totp_enabled,
+		EXISTS(SELECT 1 FROM mfa_required_roles r WHERE r.role = u.role), u.deleted_at IS NOT NULL`
+
+	var u oidcUser
+	scan := func(row *sql.Row) error {
+		return row.Scan(&u.ID, &u.Username, &u.Role, &u.EmailVerified, &u.TwoFactorEnabled, &u.mfaRequired, &u.deleted)
+	}
+
+	query := "SELECT " + userColumns + " FROM identities i JOIN users u ON u.id = i.user_id WHERE i.provider=$1 AND i.subject=$2;"
+	err := scan(db.QueryRow(query, providerName, claims.Subject))
+	if err == nil {
+		return &u, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	email, err := normalizeEmail(claims.Email)
+	if err != nil {
+		return nil, errors.New(OIDCEmailRequiredMessage)
+	}
+
+	query = "SELECT " + userColumns + " FROM users u WHERE u.email=$1;"
+	err = scan(db.QueryRow(query, email))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == nil {
+		// Linking on email alone is only safe if both sides proved they own it;
+		// otherwise anyone could take over an account by registering its address.
+		if !claims.IsEmailVerified() || !u.EmailVerified {
+			return nil, errors.New(EmailRegisteredMessage)
+		}
+		insertQuery := "INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4);"
+		if _, err := db.Exec(insertQuery, u.ID, providerName, claims.Subject, email); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				// The user already linked a different account of this provider.
+				return nil, errors.New(EmailRegisteredMessage)
+			}
+			return nil, err
+		}
+		return &u, nil
+	}
+
+	return createOIDCUser(db, providerName, claims, email)
+}
+
+// createOIDCUser registers a new user for an external identity. The account
+// gets a random password; the user can set one through the password reset.
+func createOIDCUser(db *sql.DB, providerName string, claims *oidc.IDTokenClaims, email string) (*oidcUser, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword(password, BcryptCostFactor)
+	if err != nil {
+		return nil, errors.New(HashingErrorMessage)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	username, err := availableUsername(tx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &oidcUser{}
+	u.Username = username
+	u.Email = email
+	u.EmailVerified = claims.IsEmailVerified()
+
+	query := "INSERT INTO users (username, password, email, email_verified) VALUES ($1, $2, $3, $4) RETURNING id, role;"
+	if err := tx.QueryRow(query, u.Username, hashedPassword, u.Email, u.EmailVerified).Scan(&u.ID, &u.Role); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, errors.New(UserExistsMessage)
+		}
+		return nil, err
+	}
+
+	identityQuery := "INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4);"
+	if _, err := tx.Exec(identityQuery, u.ID, providerName, claims.Subject, email); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// availableUsername derives an unused username from the local part of an email address.
+func availableUsername(tx *sql.Tx, email string) (string, error) {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return -1
+	}, strings.ToLower(email[:strings.Index(email, "@")]))
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 10; i++ {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username=$1);", candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%04d", base, suffix.Int64())
+	}
+	return "", errors.New(UserExistsMessage)
+}
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const MaxDisplayNameLength = 100
+
+var (
+	// phoneNumberPattern matches E.164 numbers: a plus sign and up to 15 digits.
+	phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+	// languageTagPattern matches the common BCP 47 tags: a language subtag
+	// followed by optional script, region or variant subtags, e.g. "pt-BR".
+	languageTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+)
+
+// normalizeDisplayName trims a display name and checks its length.
+func normalizeDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength {
+		return "", errors.New(InvalidDisplayNameMessage)
+	}
+	return name, nil
+}
+
+// normalizePhoneNumber strips the usual separators and checks the number is in
+// international format. An empty number is allowed and clears it.
+func normalizePhoneNumber(number string) (string, error) {
+	number = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(number)
+	if number == "" {
+		return "", nil
+	}
+	if !phoneNumberPattern.MatchString(number) {
+		return "", errors.New(InvalidPhoneNumberMessage)
+	}
+	return number, nil
+}
+
+func isValidLanguageTag(tag string) bool {
+	return len(tag) <= 35 && languageTagPattern.MatchString(tag)
+}
+
+// isValidTimezone reports whether tz is a known IANA time zone name.
+func isValidTimezone(tz string) bool {
+	if tz == "" || tz == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
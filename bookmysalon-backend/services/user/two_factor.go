@@ -0,0 +1,413 @@
+package user
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/session"
+	"bookmysalon/pkg/totp"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TOTPIssuer is the account issuer shown in authenticator apps.
+	TOTPIssuer = "BookMySalon"
+
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// MFAChallenge is returned by LoginUser instead of tokens when the password
+// was correct but the user still has to prove a second factor at /login/mfa.
+type MFAChallenge struct {
+	MFARequired bool `json:"mfa_required"`
+
+	// Set when the role of the user requires two-factor authentication but it
+	// has not been set up yet. The client enrolls at /login/mfa/enroll first.
+	EnrollmentRequired bool `json:"enrollment_required"`
+
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// MFALoginResult is the outcome of the second login step.
+type MFALoginResult struct {
+	*session.TokenPair
+
+	// Only set when the login also completed the enrollment.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// TOTPEnrollment carries the secret to add to an authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (us *UserServiceImpl) EnrollTOTP(db *sql.DB, userID int) (*TOTPEnrollment, error) {
+	var username string
+	var enabled bool
+	query := "SELECT username, totp_enabled FROM users WHERE id=$1;"
+	if err := db.QueryRow(query, userID).Scan(&username, &enabled); err != nil {
+		return nil, errors.New(UserNotFoundMessage)
+	}
+	if enabled {
+		return nil, errors.New(MFAAlreadyEnabledMessage)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// Starting over replaces any secret from an earlier, unfinished enrollment.
+	updateQuery := "UPDATE users SET totp_secret=$1, totp_last_step=NULL WHERE id=$2 AND totp_enabled=FALSE;"
+	if _, err := db.Exec(updateQuery, secret, userID); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(TOTPIssuer, username, secret),
+	}, nil
+}
+
+func (us *UserServiceImpl) EnableTOTP(db *sql.DB, userID int, code string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+	query := "SELECT totp_secret, totp_enabled FROM users WHERE id=$1 FOR UPDATE;"
+	if err := tx.QueryRow(query, userID).Scan(&secret, &enabled); err != nil {
+		return nil, errors.New(UserNotFoundMessage)
+	}
+	if enabled {
+		return nil, errors.New(MFAAlreadyEnabledMessage)
+	}
+	if !secret.Valid {
+		return nil, errors.New(MFANotEnrolledMessage)
+	}
+
+	ok, err := acceptTOTPCode(tx, userID, secret.String, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New(InvalidMFACodeMessage)
+	}
+
+	recoveryCodes, err := enableTOTP(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (us *UserServiceImpl) DisableTOTP(db *sql.DB, userID int, password string) error {
+	var hashedPassword string
+	var enabled, required bool
+	query := `SELECT password, totp_enabled, EXISTS(SELECT 1 FROM mfa_required_roles r WHERE r.role = users.role)
+		FROM users WHERE id=$1;`
+	if err := db.QueryRow(query, userID).Scan(&hashedPassword, &enabled, &required); err != nil {
+		return errors.New(UserNotFoundMessage)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return errors.New(InvalidPasswordMessage)
+	}
+	if !enabled {
+		return errors.New(MFANotEnabledMessage)
+	}
+	if required {
+		return errors.New(MFARequiredMessage)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_secret=NULL, totp_enabled=FALSE, totp_last_step=NULL WHERE id=$1;", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id=$1;", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (us *UserServiceImpl) RegenerateRecoveryCodes(db *sql.DB, userID int, code string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+	query := "SELECT totp_secret, totp_enabled FROM users WHERE id=$1 FOR UPDATE;"
+	if err := tx.QueryRow(query, userID).Scan(&secret, &enabled); err != nil {
+		return nil, errors.New(UserNotFoundMessage)
+	}
+	if !enabled {
+		return nil, errors.New(MFANotEnabledMessage)
+	}
+
+	ok, err := acceptTOTPCode(tx, userID, secret.String, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New(InvalidMFACodeMessage)
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (us *UserServiceImpl) EnrollTOTPForLogin(db *sql.DB, mfaToken string) (*TOTPEnrollment, error) {
+	claims, err := jwt.VerifyMFAToken(mfaToken)
+	if err != nil {
+		return nil, errors.New(InvalidMFATokenMessage)
+	}
+	return us.EnrollTOTP(db, claims.UserID)
+}
+
+func (us *UserServiceImpl) CompleteMFALogin(db *sql.DB, mfaToken, code, clientIP string) (*MFALoginResult, error) {
+	claims, err := jwt.VerifyMFAToken(mfaToken)
+	if err != nil {
+		return nil, errors.New(InvalidMFATokenMessage)
+	}
+
+	if err := checkLoginThrottle(db, claims.Username, clientIP); err != nil {
+		if _, ok := err.(*LoginThrottledError); ok {
+			recordLoginAttempt(db, claims.Username, claims.UserID, clientIP, LoginOutcomeThrottled)
+		}
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var dbUser models.User
+	var secret sql.NullString
+	var enabled bool
+	query := "SELECT id, username, role, totp_secret, totp_enabled FROM users WHERE id=$1 AND deleted_at IS NULL FOR UPDATE;"
+	if err := tx.QueryRow(query, claims.UserID).Scan(&dbUser.ID, &dbUser.Username, &dbUser.Role, &secret, &enabled); err != nil {
+		return nil, errors.New(InvalidMFATokenMessage)
+	}
+	if !secret.Valid {
+		return nil, errors.New(MFANotEnrolledMessage)
+	}
+
+	// Recovery codes only exist once two-factor authentication is enabled.
+	var ok bool
+	if isTOTPCode(code) {
+		ok, err = acceptTOTPCode(tx, dbUser.ID, secret.String, code)
+	} else if enabled {
+		ok, err = consumeRecoveryCode(tx, dbUser.ID, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		tx.Rollback()
+		recordLoginAttempt(db, dbUser.Username, dbUser.ID, clientIP, LoginOutcomeInvalidMFACode)
+		return nil, errors.New(InvalidMFACodeMessage)
+	}
+
+	result := &MFALoginResult{}
+	if !enabled {
+		// The first valid code completes an enrollment required by the role.
+		if result.RecoveryCodes, err = enableTOTP(tx, dbUser.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	recordLoginAttempt(db, dbUser.Username, dbUser.ID, clientIP, LoginOutcomeSuccess)
+	if _, err := db.Exec("UPDATE users SET last_login=NOW() WHERE id=$1;", dbUser.ID); err != nil {
+		log.Printf("Error updating last login: %v", err)
+	}
+
+	if result.TokenPair, err = us.IssueTokens(&dbUser); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (us *UserServiceImpl) ListMFARequiredRoles(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT role FROM mfa_required_roles ORDER BY role;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SetRoleMFARequired changes whether users of a role must use two-factor
+// authentication. It takes effect at their next login: users without it are
+// then asked to enroll before they get tokens.
+func (us *UserServiceImpl) SetRoleMFARequired(db *sql.DB, role string, required bool) error {
+	if !models.IsValidRole(role) {
+		return errors.New(InvalidRoleMessage)
+	}
+
+	query := "DELETE FROM mfa_required_roles WHERE role=$1;"
+	if required {
+		query = "INSERT INTO mfa_required_roles (role) VALUES ($1) ON CONFLICT (role) DO NOTHING;"
+	}
+	_, err := db.Exec(query, role)
+	return err
+}
+
+// newMFAChallenge returns the challenge for a user who passed the password step.
+func newMFAChallenge(u *models.User, enrollmentRequired bool) (*MFAChallenge, error) {
+	token, err := jwt.GenerateMFAToken(u.ID, u.Username)
+	if err != nil {
+		return nil, errors.New(TokenErrorMessage)
+	}
+	return &MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: enrollmentRequired,
+		MFAToken:           token,
+		ExpiresIn:          int64(jwt.MFATokenTTL.Seconds()),
+	}, nil
+}
+
+// acceptTOTPCode checks a code against the secret and records its time step,
+// so that the same code cannot be used twice.
+func acceptTOTPCode(q execer, userID int, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	query := "UPDATE users SET totp_last_step=$1 WHERE id=$2 AND (totp_last_step IS NULL OR totp_last_step < $1);"
+	result, err := q.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// enableTOTP turns on two-factor authentication for the user and returns their first recovery codes.
+func enableTOTP(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec("UPDATE users SET totp_enabled=TRUE WHERE id=$1;", userID); err != nil {
+		return nil, err
+	}
+	return replaceRecoveryCodes(tx, userID)
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns a new set.
+// Only hashes are stored, so the codes can be shown to the user just once.
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id=$1;", userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		query := "INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2);"
+		if _, err := tx.Exec(query, userID, hashUserToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// consumeRecoveryCode marks an unused recovery code of the user as used.
+func consumeRecoveryCode(tx *sql.Tx, userID int, code string) (bool, error) {
+	query := `UPDATE user_recovery_codes SET used_at=NOW()
+		WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL
+		RETURNING code_id;`
+
+	var codeID int
+	if err := tx.QueryRow(query, userID, hashUserToken(normalizeRecoveryCode(code))).Scan(&codeID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// newRecoveryCode returns a random code formatted as "xxxxx-xxxxx".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength*5/8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeRecoveryCode accepts codes typed with or without separators and in any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// isTOTPCode reports whether code looks like an authenticator code rather than a recovery code.
+func isTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
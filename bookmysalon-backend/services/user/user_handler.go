@@ -18,25 +18,68 @@ package user
 import (
 	"encoding/json"
 	"errors"
+	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"bookmysalon/models"
 	"bookmysalon/pkg/database"
 	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/session"
+
+	"github.com/gorilla/mux"
 )
 
 const (
-	ServerAddress           = ":8080"
-	BcryptCostFactor        = 8
-	DatabaseErrorMessage    = "Database error"
-	BadRequestMessage       = "Bad request"
-	TokenErrorMessage       = "failed to generate token"
-	HashingErrorMessage     = "failed to hash password"
-	UserNotFoundMessage     = "user not found"
-	InvalidPasswordMessage  = "invalid password"
-	InvalidTokenMessage     = "Invalid token"
-	MissingTokenMessage     = "Missing token"
-	ProcessingDataErrorMess = "Failed to process user data"
+	ServerAddress             = ":8080"
+	BcryptCostFactor          = 8
+	DatabaseErrorMessage      = "Database error"
+	BadRequestMessage         = "Bad request"
+	TokenErrorMessage         = "failed to generate token"
+	HashingErrorMessage       = "failed to hash password"
+	UserNotFoundMessage       = "user not found"
+	InvalidPasswordMessage    = "invalid password"
+	InvalidCredentialsMessage = "invalid username or password"
+	LoginThrottledMessage     = "too many failed login attempts, try again later"
+	InvalidTokenMessage       = "Invalid token"
+	MissingTokenMessage       = "Missing token"
+	ProcessingDataErrorMess   = "Failed to process user data"
+	InvalidRoleMessage        = "invalid role"
+	InvalidUserIDMessage      = "Invalid user ID"
+	InvalidUsernameMessage    = "username is required"
+	InvalidEmailMessage       = "invalid email address"
+	WeakPasswordMessage       = "password must be at least 8 characters"
+	UserExistsMessage         = "username or email already registered"
+	InvalidUserTokenMessage   = "invalid or expired token"
+
+	EmailAlreadyVerifiedMessage = "email already verified"
+	InvalidMFATokenMessage      = "invalid or expired MFA token"
+	InvalidMFACodeMessage       = "invalid authentication code"
+	MFAAlreadyEnabledMessage    = "two-factor authentication already enabled"
+	MFANotEnabledMessage        = "two-factor authentication not enabled"
+	MFANotEnrolledMessage       = "two-factor enrollment not started"
+	MFARequiredMessage          = "two-factor authentication is required for your role"
+
+	UnknownProviderMessage       = "unknown identity provider"
+	InvalidOIDCStateMessage      = "invalid or expired login state"
+	OIDCLoginFailedMessage       = "login with the identity provider failed"
+	OIDCEmailRequiredMessage     = "the identity provider did not share an email address"
+	EmailRegisteredMessage       = "email already registered, log in and link the account instead"
+	IdentityAlreadyLinkedMessage = "this account is already linked to a user"
+	IdentityNotFoundMessage      = "identity not linked"
+
+	EmailTakenMessage         = "email already in use"
+	InvalidDisplayNameMessage = "display name must be at most 100 characters"
+	InvalidPhoneNumberMessage = "phone number must be in international format, e.g. +14155550123"
+	InvalidLanguageMessage    = "invalid language tag"
+	InvalidTimezoneMessage    = "unknown time zone"
+	ProfileImageUploadMessage = "profile images are uploaded to /profile/image; profile_image can only be cleared"
+
+	AccountDeletedMessage = "account is scheduled for deletion, restore it at /account/restore"
+	SoleSalonOwnerMessage = "transfer or delete the salons you own before deleting your account"
 )
 
 type UserHandler struct {
@@ -47,13 +90,26 @@ func NewUserHandler(service UserService) *UserHandler {
 	return &UserHandler{UserService: service}
 }
 
-// Register a new user
+// credentialsRequest is the body of the register and login requests.
+// models.User never decodes a password, so it is read separately here.
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (req credentialsRequest) user() models.User {
+	return models.User{Username: req.Username, Email: req.Email, Password: req.Password}
+}
+
+// Register a new user. A verification link is sent to the given email address.
 // swagger:route POST /register users registerUser
 //
 // Responses:
 //
 //	200: tokenResponse
 //	400: errorResponse
+//	409: errorResponse
 //	500: errorResponse
 func (handler *UserHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	db, err := database.Connect()
@@ -63,33 +119,45 @@ func (handler *UserHandler) RegisterHandler(w http.ResponseWriter, r *http.Reque
 	}
 	defer db.Close()
 
-	var u models.User
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, BadRequestMessage, http.StatusBadRequest)
 		return
 	}
 
+	u := req.user()
 	if err := handler.UserService.RegisterUser(db, &u); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch err.Error() {
+		case InvalidUsernameMessage, InvalidEmailMessage, WeakPasswordMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case UserExistsMessage:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	token, err := jwt.GenerateToken(u.Username)
+	tokens, err := handler.UserService.IssueTokens(&u)
 	if err != nil {
 		http.Error(w, TokenErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	w.Write([]byte(token))
+	writeTokens(w, tokens)
 }
 
-// User login
+// User login. Repeated failures slow down and temporarily lock the account and the client IP.
+// Users with two-factor authentication get an MFA challenge instead of tokens.
 // swagger:route POST /login users loginUser
 //
 // Responses:
 //
 //	200: tokenResponse
+//	202: mfaChallengeResponse
 //	401: errorResponse
+//	403: errorResponse
+//	429: errorResponse
 //	500: errorResponse
 func (handler *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch database connection
@@ -100,31 +168,224 @@ func (handler *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request)
 	}
 	defer db.Close()
 
-	var u models.User
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, BadRequestMessage, http.StatusBadRequest)
 		return
 	}
 
-	token, err := handler.UserService.LoginUser(db, &u)
+	u := req.user()
+	tokens, challenge, err := handler.UserService.LoginUser(db, &u, clientIP(r))
 	if err != nil {
+		if throttled, ok := err.(*LoginThrottledError); ok {
+			w.Header().Set("Retry-After", retryAfterHeader(throttled.RetryAfter))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 		switch err.Error() {
-		case UserNotFoundMessage, InvalidPasswordMessage:
+		case InvalidCredentialsMessage:
 			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case AccountDeletedMessage:
+			http.Error(w, err.Error(), http.StatusForbidden)
 		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if challenge != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	writeTokens(w, tokens)
+}
+
+// Second login step: exchange an MFA token and an authenticator or recovery code for tokens
+// swagger:route POST /login/mfa users loginMFA
+//
+// Responses:
+//
+//	200: mfaLoginResponse
+//	400: errorResponse
+//	401: errorResponse
+//	429: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	result, err := handler.UserService.CompleteMFALogin(db, req.MFAToken, req.Code, clientIP(r))
+	if err != nil {
+		if throttled, ok := err.(*LoginThrottledError); ok {
+			w.Header().Set("Retry-After", retryAfterHeader(throttled.RetryAfter))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		switch err.Error() {
+		case InvalidMFATokenMessage, InvalidMFACodeMessage:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case MFANotEnrolledMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Start the two-factor enrollment required by the role of the user during login
+// swagger:route POST /login/mfa/enroll users loginMFAEnroll
+//
+// Responses:
+//
+//	200: totpEnrollmentResponse
+//	400: errorResponse
+//	401: errorResponse
+//	409: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) LoginMFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	enrollment, err := handler.UserService.EnrollTOTPForLogin(db, req.MFAToken)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// Exchange a refresh token for a new token pair
+// swagger:route POST /token/refresh users refreshToken
+//
+// Responses:
+//
+//	200: tokenResponse
+//	400: errorResponse
+//	401: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := handler.UserService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		switch err {
+		case session.ErrInvalidRefreshToken, session.ErrRefreshTokenReused:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, TokenErrorMessage, http.StatusInternalServerError)
 		}
 		return
 	}
 
-	w.Write([]byte(token))
+	writeTokens(w, tokens)
+}
+
+// Log out the current session
+// swagger:route POST /logout users logout
+//
+// Responses:
+//
+//	200: messageResponse
+//	401: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.UserService.Logout(claims); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("Logged out successfully"))
+}
+
+// Log out every session of the current user
+// swagger:route POST /logout/all users logoutAllDevices
+//
+// Responses:
+//
+//	200: messageResponse
+//	401: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.UserService.LogoutAllDevices(claims.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("Logged out from all devices"))
+}
+
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeTokens writes a token pair as the JSON response body.
+func writeTokens(w http.ResponseWriter, tokens *session.TokenPair) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
 }
 
-// Assuming there's a helper function to fetch claims from context:
-func getUserClaimsFromContext(r *http.Request) (jwt.Claims, error) {
-	claims, ok := r.Context().Value("userClaims").(jwt.Claims)
+// getUserClaimsFromContext returns the claims that middleware.Authenticate stored on the request.
+func getUserClaimsFromContext(r *http.Request) (*jwt.Claims, error) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
 	if !ok {
-		return claims, errors.New("no user claims found in context")
+		return nil, errors.New("no user claims found in context")
 	}
 	return claims, nil
 }
@@ -152,9 +413,14 @@ func (handler *UserHandler) ProfileHandler(w http.ResponseWriter, r *http.Reques
 	}
 	defer db.Close()
 
-	userProfile, err := handler.UserService.FetchUserProfile(db, claims.Username)
+	userProfile, err := handler.UserService.FetchUserProfile(db, claims.UserID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		switch err.Error() {
+		case UserNotFoundMessage:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -166,13 +432,16 @@ func (handler *UserHandler) ProfileHandler(w http.ResponseWriter, r *http.Reques
 	w.Write(response)
 }
 
-// swagger:route PUT /profile users updateUserProfile
+// Update the profile of the current user. Only the fields sent are changed, for both PUT and PATCH.
+// swagger:route PATCH /profile users updateUserProfile
 //
 // Responses:
 //
 //	200: userResponse
 //	401: errorResponse
 //	400: errorResponse
+//	404: errorResponse
+//	409: errorResponse
 //	500: errorResponse
 func (handler *UserHandler) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
 	claims, err := getUserClaimsFromContext(r)
@@ -188,26 +457,38 @@ func (handler *UserHandler) UpdateProfileHandler(w http.ResponseWriter, r *http.
 	}
 	defer db.Close()
 
-	var u models.User
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+	var update models.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
 		http.Error(w, BadRequestMessage, http.StatusBadRequest)
 		return
 	}
 
-	u.Username = claims.Username // Ensure the username from token is used
-	if err := handler.UserService.UpdateUserProfile(db, &u); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	userProfile, err := handler.UserService.UpdateUserProfile(db, claims.UserID, &update)
+	if err != nil {
+		switch err.Error() {
+		case InvalidEmailMessage, InvalidDisplayNameMessage, InvalidPhoneNumberMessage, InvalidLanguageMessage, InvalidTimezoneMessage,
+			ProfileImageUploadMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case EmailTakenMessage:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case UserNotFoundMessage:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
 		return
 	}
 
-	w.Write([]byte("Profile updated successfully"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userProfile)
 }
 
+// Change the password of the current user. All their sessions are ended, and a new token pair is returned for this one.
 // swagger:route PUT /change-password users changePassword
 //
 // Responses:
 //
-//	200: messageResponse
+//	200: tokenResponse
 //	401: errorResponse
 //	400: errorResponse
 //	500: errorResponse
@@ -232,19 +513,36 @@ func (handler *UserHandler) ChangePasswordHandler(w http.ResponseWriter, r *http
 	}
 
 	if err := handler.UserService.ChangeUserPassword(db, claims.Username, pcr.OldPassword, pcr.NewPassword); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch err.Error() {
+		case WeakPasswordMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case InvalidPasswordMessage:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The change logged out every device, this one included.
+	tokens, err := handler.UserService.IssueTokens(&models.User{ID: claims.UserID, Username: claims.Username, Role: claims.Role})
+	if err != nil {
+		http.Error(w, TokenErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	w.Write([]byte("Password changed successfully"))
+	writeTokens(w, tokens)
 }
 
+// Delete the account of the current user. It can be restored for 30 days, after which the personal data is anonymized.
 // swagger:route DELETE /profile users deleteUser
 //
 // Responses:
 //
 //	200: messageResponse
 //	401: errorResponse
+//	404: errorResponse
+//	409: errorResponse
 //	500: errorResponse
 func (handler *UserHandler) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
 	claims, err := getUserClaimsFromContext(r)
@@ -260,10 +558,746 @@ func (handler *UserHandler) DeleteAccountHandler(w http.ResponseWriter, r *http.
 	}
 	defer db.Close()
 
-	if err := handler.UserService.DeleteUserAccount(db, claims.Username); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := handler.UserService.DeleteUserAccount(db, claims.UserID); err != nil {
+		switch err.Error() {
+		case UserNotFoundMessage:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case SoleSalonOwnerMessage:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Account scheduled for deletion"))
+}
+
+// Restore an account scheduled for deletion during its grace period
+// swagger:route POST /account/restore users restoreAccount
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	401: errorResponse
+//	429: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) RestoreAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.RestoreUserAccount(db, req.Username, req.Password, clientIP(r)); err != nil {
+		if throttled, ok := err.(*LoginThrottledError); ok {
+			w.Header().Set("Retry-After", retryAfterHeader(throttled.RetryAfter))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		switch err.Error() {
+		case InvalidCredentialsMessage:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Account restored successfully"))
+}
+
+// Download everything stored about the current user, as JSON or, with ?format=zip, as a ZIP archive
+// swagger:route GET /profile/export users exportUserData
+//
+// Responses:
+//
+//	200: dataExportResponse
+//	400: errorResponse
+//	401: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) ExportDataHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	export, err := handler.UserService.ExportUserData(db, claims.UserID)
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	filename := "bookmysalon-export-" + strconv.Itoa(claims.UserID)
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		if err := export.WriteZip(w); err != nil {
+			log.Printf("Error writing data export: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// Change the platform role of a user. Their sessions are ended, as issued tokens carry the old role.
+// swagger:route PUT /users/{userID}/role users updateUserRole
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	404: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		http.Error(w, InvalidUserIDMessage, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
 		return
 	}
+	defer db.Close()
 
-	w.Write([]byte("Account deleted successfully"))
+	if err := handler.UserService.UpdateUserRole(db, userID, req.Role); err != nil {
+		switch err.Error() {
+		case InvalidRoleMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case UserNotFoundMessage:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Role updated successfully"))
+}
+
+// Confirm the email address of a user with the token sent by email
+// swagger:route POST /verify-email users verifyEmail
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.VerifyEmail(db, req.Token); err != nil {
+		switch err.Error() {
+		case InvalidUserTokenMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Email verified successfully"))
+}
+
+// Send a new verification email to the current user
+// swagger:route POST /verify-email/resend users resendVerificationEmail
+//
+// Responses:
+//
+//	200: messageResponse
+//	401: errorResponse
+//	409: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.SendVerificationEmail(db, claims.UserID); err != nil {
+		switch err.Error() {
+		case EmailAlreadyVerifiedMessage:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Verification email sent"))
+}
+
+// Request a password reset email
+// swagger:route POST /password/forgot users forgotPassword
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.RequestPasswordReset(db, req.Email); err != nil {
+		switch err.Error() {
+		case InvalidEmailMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Same answer whether or not the address is registered.
+	w.Write([]byte("If the address is registered, a reset link has been sent"))
+}
+
+// Choose a new password with the token sent by email
+// swagger:route POST /password/reset users resetPassword
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.ResetPassword(db, req.Token, req.NewPassword); err != nil {
+		switch err.Error() {
+		case InvalidUserTokenMessage, WeakPasswordMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Password reset successfully"))
+}
+
+// Start setting up an authenticator app for the current user
+// swagger:route POST /mfa/totp/enroll users enrollTOTP
+//
+// Responses:
+//
+//	200: totpEnrollmentResponse
+//	401: errorResponse
+//	409: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	enrollment, err := handler.UserService.EnrollTOTP(db, claims.UserID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// Turn on two-factor authentication with a code from the enrolled authenticator app
+// swagger:route POST /mfa/totp/enable users enableTOTP
+//
+// Responses:
+//
+//	200: recoveryCodesResponse
+//	400: errorResponse
+//	401: errorResponse
+//	409: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) EnableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	recoveryCodes, err := handler.UserService.EnableTOTP(db, claims.UserID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeRecoveryCodes(w, recoveryCodes)
+}
+
+// Turn off two-factor authentication for the current user
+// swagger:route POST /mfa/totp/disable users disableTOTP
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	401: errorResponse
+//	403: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.DisableTOTP(db, claims.UserID, req.Password); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Write([]byte("Two-factor authentication disabled"))
+}
+
+// Replace the recovery codes of the current user
+// swagger:route POST /mfa/recovery-codes users regenerateRecoveryCodes
+//
+// Responses:
+//
+//	200: recoveryCodesResponse
+//	400: errorResponse
+//	401: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	recoveryCodes, err := handler.UserService.RegenerateRecoveryCodes(db, claims.UserID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeRecoveryCodes(w, recoveryCodes)
+}
+
+// List the roles that must use two-factor authentication
+// swagger:route GET /mfa/required-roles users listMFARequiredRoles
+//
+// Responses:
+//
+//	200: mfaRequiredRolesResponse
+//	500: errorResponse
+func (handler *UserHandler) ListMFARequiredRolesHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	roles, err := handler.UserService.ListMFARequiredRoles(db)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"roles": roles})
+}
+
+// Require or stop requiring two-factor authentication for a role
+// swagger:route PUT /mfa/required-roles/{role} users setRoleMFARequired
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) SetRoleMFARequiredHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Required bool `json:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.SetRoleMFARequired(db, mux.Vars(r)["role"], req.Required); err != nil {
+		switch err.Error() {
+		case InvalidRoleMessage:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Write([]byte("Two-factor requirement updated successfully"))
+}
+
+// writeMFAError maps the errors of the two-factor methods to status codes.
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case InvalidMFATokenMessage, InvalidPasswordMessage:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case InvalidMFACodeMessage, MFANotEnrolledMessage, MFANotEnabledMessage:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case MFAAlreadyEnabledMessage:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case MFARequiredMessage:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case UserNotFoundMessage:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeRecoveryCodes writes freshly generated recovery codes as the JSON response body.
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// Start a login with an external identity provider such as Google or Apple
+// swagger:route GET /auth/{provider}/login users oidcLogin
+//
+// Responses:
+//
+//	200: authorizationURLResponse
+//	404: errorResponse
+//	502: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	handler.startOIDC(w, r, 0)
+}
+
+// Complete a login with an external identity provider. The code and state are
+// the parameters the provider redirected the user back with.
+// swagger:route POST /auth/{provider}/callback users oidcCallback
+//
+// Responses:
+//
+//	200: tokenResponse
+//	202: mfaChallengeResponse
+//	400: errorResponse
+//	403: errorResponse
+//	404: errorResponse
+//	409: errorResponse
+//	502: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	code, state, ok := oidcCallbackParams(r)
+	if !ok {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	tokens, challenge, err := handler.UserService.CompleteOIDCLogin(db, mux.Vars(r)["provider"], code, state, clientIP(r))
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	if challenge != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	writeTokens(w, tokens)
+}
+
+// Start linking an external identity to the current user
+// swagger:route GET /auth/{provider}/link users oidcLink
+//
+// Responses:
+//
+//	200: authorizationURLResponse
+//	401: errorResponse
+//	404: errorResponse
+//	502: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) OIDCLinkHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	handler.startOIDC(w, r, claims.UserID)
+}
+
+// Complete linking an external identity to the current user
+// swagger:route POST /auth/{provider}/link/callback users oidcLinkCallback
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: errorResponse
+//	401: errorResponse
+//	409: errorResponse
+//	502: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) OIDCLinkCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	code, state, ok := oidcCallbackParams(r)
+	if !ok {
+		http.Error(w, BadRequestMessage, http.StatusBadRequest)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.LinkOIDCIdentity(db, mux.Vars(r)["provider"], code, state, claims.UserID); err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	w.Write([]byte("Account linked successfully"))
+}
+
+// Remove the link between the current user and an external identity
+// swagger:route DELETE /auth/{provider}/link users oidcUnlink
+//
+// Responses:
+//
+//	200: messageResponse
+//	401: errorResponse
+//	404: errorResponse
+//	500: errorResponse
+func (handler *UserHandler) OIDCUnlinkHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getUserClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, InvalidTokenMessage, http.StatusUnauthorized)
+		return
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	if err := handler.UserService.UnlinkOIDCIdentity(db, claims.UserID, mux.Vars(r)["provider"]); err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	w.Write([]byte("Account unlinked successfully"))
+}
+
+// startOIDC answers with the provider URL the client has to send the user to.
+func (handler *UserHandler) startOIDC(w http.ResponseWriter, r *http.Request, linkUserID int) {
+	db, err := database.Connect()
+	if err != nil {
+		http.Error(w, DatabaseErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	authURL, err := handler.UserService.StartOIDCLogin(db, mux.Vars(r)["provider"], linkUserID)
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+}
+
+// oidcCallbackParams reads code and state from a JSON body, or from the query
+// or form, which is how providers using response_mode=form_post send them.
+func oidcCallbackParams(r *http.Request) (code, state string, ok bool) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Code  string `json:"code"`
+			State string `json:"state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", "", false
+		}
+		code, state = req.Code, req.State
+	} else {
+		code, state = r.FormValue("code"), r.FormValue("state")
+	}
+	return code, state, code != "" && state != ""
+}
+
+// writeOIDCError maps the errors of the external login methods to status codes.
+func writeOIDCError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case UnknownProviderMessage, IdentityNotFoundMessage:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case InvalidOIDCStateMessage, OIDCEmailRequiredMessage:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case EmailRegisteredMessage, IdentityAlreadyLinkedMessage, UserExistsMessage:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case AccountDeletedMessage:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case OIDCLoginFailedMessage:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
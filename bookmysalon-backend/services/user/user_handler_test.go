@@ -0,0 +1,124 @@
+package user
+
+import (
+	"bookmysalon/models"
+	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/middleware"
+	"bookmysalon/pkg/session"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSessions keeps the "log out all devices" cutoffs in memory, in whole
+// seconds like the session store.
+type fakeSessions struct {
+	mu      sync.Mutex
+	cutoffs map[int]int64
+}
+
+func (s *fakeSessions) RevokeAll(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutoffs[userID] = time.Now().Unix()
+}
+
+func (s *fakeSessions) IsRevoked(claims *jwt.Claims) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff, ok := s.cutoffs[claims.UserID]
+	return ok && claims.IssuedAt < cutoff, nil
+}
+
+// passwordChanger is a UserService that only changes passwords and issues tokens.
+type passwordChanger struct {
+	UserService
+	sessions *fakeSessions
+}
+
+func (p *passwordChanger) ChangeUserPassword(db *sql.DB, username, oldPassword, newPassword string) error {
+	p.sessions.RevokeAll(1)
+	return nil
+}
+
+func (p *passwordChanger) IssueTokens(u *models.User) (*session.TokenPair, error) {
+	token, err := jwt.GenerateToken(u.ID, u.Username, u.Role, "new-session")
+	if err != nil {
+		return nil, err
+	}
+	return &session.TokenPair{AccessToken: token, TokenType: "Bearer"}, nil
+}
+
+func loadTestKeys(t *testing.T) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "test.pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(jwt.KeysDirEnv, dir)
+	if err := jwt.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangePasswordReturnsUsableTokens(t *testing.T) {
+	loadTestKeys(t)
+
+	sessions := &fakeSessions{cutoffs: map[int]int64{}}
+	middleware.SetRevocationChecker(sessions)
+	t.Cleanup(func() { middleware.SetRevocationChecker(nil) })
+
+	handler := NewUserHandler(&passwordChanger{sessions: sessions})
+	oldToken, err := jwt.GenerateToken(1, "alice", models.RoleCustomer, "old-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.NewReader(`{"old_password": "old secret", "new_password": "new secret"}`)
+	req := httptest.NewRequest(http.MethodPut, "/change-password", body)
+	req.Header.Set("Authorization", "Bearer "+oldToken)
+	rec := httptest.NewRecorder()
+	middleware.Authenticate(handler.ChangePasswordHandler)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var tokens session.TokenPair
+	if err := json.NewDecoder(rec.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new token is used within the second the old sessions were ended in.
+	protected := middleware.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req = httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rec = httptest.NewRecorder()
+	protected(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("returned token: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -2,14 +2,40 @@ package user
 
 import (
 	"bookmysalon/models"
+	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/session"
 	"database/sql"
 )
 
 type UserService interface {
 	RegisterUser(db *sql.DB, u *models.User) error
-	LoginUser(db *sql.DB, u *models.User) (string, error)
-	FetchUserProfile(db *sql.DB, username string) (*models.User, error)
-	UpdateUserProfile(db *sql.DB, u *models.User) error
+	SendVerificationEmail(db *sql.DB, userID int) error
+	VerifyEmail(db *sql.DB, token string) error
+	RequestPasswordReset(db *sql.DB, email string) error
+	ResetPassword(db *sql.DB, token, newPassword string) error
+	LoginUser(db *sql.DB, u *models.User, clientIP string) (*session.TokenPair, *MFAChallenge, error)
+	CompleteMFALogin(db *sql.DB, mfaToken, code, clientIP string) (*MFALoginResult, error)
+	EnrollTOTPForLogin(db *sql.DB, mfaToken string) (*TOTPEnrollment, error)
+	StartOIDCLogin(db *sql.DB, provider string, linkUserID int) (string, error)
+	CompleteOIDCLogin(db *sql.DB, provider, code, state, clientIP string) (*session.TokenPair, *MFAChallenge, error)
+	LinkOIDCIdentity(db *sql.DB, provider, code, state string, userID int) error
+	UnlinkOIDCIdentity(db *sql.DB, userID int, provider string) error
+	IssueTokens(u *models.User) (*session.TokenPair, error)
+	RefreshTokens(refreshToken string) (*session.TokenPair, error)
+	Logout(claims *jwt.Claims) error
+	LogoutAllDevices(userID int) error
+	FetchUserProfile(db *sql.DB, userID int) (*models.User, error)
+	UpdateUserProfile(db *sql.DB, userID int, update *models.ProfileUpdate) (*models.User, error)
 	ChangeUserPassword(db *sql.DB, username, oldPassword, newPassword string) error
-	DeleteUserAccount(db *sql.DB, username string) error
+	DeleteUserAccount(db *sql.DB, userID int) error
+	RestoreUserAccount(db *sql.DB, username, password, clientIP string) error
+	PurgeDeletedAccounts(db *sql.DB) (int, error)
+	ExportUserData(db *sql.DB, userID int) (*DataExport, error)
+	UpdateUserRole(db *sql.DB, userID int, role string) error
+	EnrollTOTP(db *sql.DB, userID int) (*TOTPEnrollment, error)
+	EnableTOTP(db *sql.DB, userID int, code string) ([]string, error)
+	DisableTOTP(db *sql.DB, userID int, password string) error
+	RegenerateRecoveryCodes(db *sql.DB, userID int, code string) ([]string, error)
+	ListMFARequiredRoles(db *sql.DB) ([]string, error)
+	SetRoleMFARequired(db *sql.DB, role string, required bool) error
 }
@@ -3,59 +3,446 @@ package user
 import (
 	"bookmysalon/models"
 	"bookmysalon/pkg/jwt"
+	"bookmysalon/pkg/mailer"
+	"bookmysalon/pkg/oidc"
+	"bookmysalon/pkg/session"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type UserServiceImpl struct{}
+const (
+	MinPasswordLength    = 8
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+type UserServiceImpl struct {
+	Sessions session.Store
+	Mailer   mailer.Mailer
+
+	// AppBaseURL is used to build the links sent by email, e.g. "https://app.bookmysalon.com".
+	AppBaseURL string
+
+	// OIDCProviders are the external identity providers users can log in with, keyed by name.
+	OIDCProviders map[string]*oidc.Provider
+}
 
 func (us *UserServiceImpl) RegisterUser(db *sql.DB, u *models.User) error {
+	u.Username = strings.TrimSpace(u.Username)
+	if u.Username == "" {
+		return errors.New(InvalidUsernameMessage)
+	}
+
+	email, err := normalizeEmail(u.Email)
+	if err != nil {
+		return err
+	}
+	u.Email = email
+
+	if len(u.Password) < MinPasswordLength {
+		return errors.New(WeakPasswordMessage)
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), BcryptCostFactor)
 	if err != nil {
 		return errors.New(HashingErrorMessage)
 	}
 
-	query := "INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id;"
-	if err := db.QueryRow(query, u.Username, hashedPassword).Scan(&u.ID); err != nil {
+	query := "INSERT INTO users (username, password, email) VALUES ($1, $2, $3) RETURNING id, role;"
+	if err := db.QueryRow(query, u.Username, hashedPassword, u.Email).Scan(&u.ID, &u.Role); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New(UserExistsMessage)
+		}
 		return errors.New("failed to register user")
 	}
+
+	// Registration succeeds even if the mail cannot be sent; the user can ask for a new one.
+	if err := us.sendVerificationEmail(db, u.ID, u.Email); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
 	return nil
 }
 
-func (us *UserServiceImpl) LoginUser(db *sql.DB, u *models.User) (string, error) {
+func (us *UserServiceImpl) SendVerificationEmail(db *sql.DB, userID int) error {
+	var email string
+	var verified bool
+	query := "SELECT email, email_verified FROM users WHERE id=$1;"
+	if err := db.QueryRow(query, userID).Scan(&email, &verified); err != nil {
+		return errors.New(UserNotFoundMessage)
+	}
+
+	if verified {
+		return errors.New(EmailAlreadyVerifiedMessage)
+	}
+	return us.sendVerificationEmail(db, userID, email)
+}
+
+func (us *UserServiceImpl) VerifyEmail(db *sql.DB, token string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET email_verified=TRUE WHERE id=$1;", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (us *UserServiceImpl) RequestPasswordReset(db *sql.DB, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	query := "SELECT id FROM users WHERE email=$1 AND deleted_at IS NULL;"
+	if err := db.QueryRow(query, email).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			// Do not reveal whether an account exists for this address.
+			return nil
+		}
+		return err
+	}
+
+	token, err := createUserToken(db, userID, TokenPurposePasswordReset, PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return us.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your BookMySalon password",
+		Body:    us.tokenMailBody("Use the following code to choose a new password. It expires in 1 hour.", "/password/reset", token),
+	})
+}
+
+func (us *UserServiceImpl) ResetPassword(db *sql.DB, token, newPassword string) error {
+	if len(newPassword) < MinPasswordLength {
+		return errors.New(WeakPasswordMessage)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), BcryptCostFactor)
+	if err != nil {
+		return errors.New(HashingErrorMessage)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	// Receiving the reset mail also proves ownership of the address.
+	if _, err := tx.Exec("UPDATE users SET password=$1, email_verified=TRUE WHERE id=$2;", hashedPassword, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must not stay logged in.
+	return us.Sessions.RevokeAll(userID)
+}
+
+func (us *UserServiceImpl) sendVerificationEmail(db *sql.DB, userID int, email string) error {
+	token, err := createUserToken(db, userID, TokenPurposeEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return us.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your BookMySalon email address",
+		Body:    us.tokenMailBody("Use the following code to confirm your email address. It expires in 24 hours.", "/verify-email", token),
+	})
+}
+
+// tokenMailBody renders the body of a mail carrying a single-use token.
+func (us *UserServiceImpl) tokenMailBody(intro, path, token string) string {
+	body := fmt.Sprintf("%s\n\n%s\n", intro, token)
+	if us.AppBaseURL != "" {
+		body += fmt.Sprintf("\nOr open %s%s?token=%s\n", strings.TrimRight(us.AppBaseURL, "/"), path, token)
+	}
+	return body
+}
+
+// normalizeEmail validates an email address and returns it lower-cased.
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", errors.New(InvalidEmailMessage)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// createUserToken stores a new single-use token for the user, invalidating
+// earlier unused tokens of the same purpose, and returns its plain value.
+func createUserToken(db *sql.DB, userID int, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	invalidateQuery := "UPDATE user_tokens SET used_at=NOW() WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL;"
+	if _, err := tx.Exec(invalidateQuery, userID, purpose); err != nil {
+		return "", err
+	}
+
+	insertQuery := "INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second');"
+	if _, err := tx.Exec(insertQuery, userID, purpose, hashUserToken(token), int64(ttl.Seconds())); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken marks a valid token as used and returns the user it belongs to.
+func consumeUserToken(tx *sql.Tx, token, purpose string) (int, error) {
+	query := `UPDATE user_tokens SET used_at=NOW()
+		WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id;`
+
+	var userID int
+	if err := tx.QueryRow(query, hashUserToken(token), purpose).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New(InvalidUserTokenMessage)
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// hashUserToken returns the hex SHA-256 of a token; only hashes are stored.
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// dummyPasswordHash is compared against when the username is unknown, so that
+// both failure cases take about as long and cannot be told apart.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), BcryptCostFactor)
+
+// LoginUser checks the password of a user. Users with two-factor authentication,
+// or whose role requires it, get an MFA challenge instead of tokens.
+func (us *UserServiceImpl) LoginUser(db *sql.DB, u *models.User, clientIP string) (*session.TokenPair, *MFAChallenge, error) {
+	if err := checkLoginThrottle(db, u.Username, clientIP); err != nil {
+		if _, ok := err.(*LoginThrottledError); ok {
+			recordLoginAttempt(db, u.Username, 0, clientIP, LoginOutcomeThrottled)
+		}
+		return nil, nil, err
+	}
+
 	var dbUser models.User
-	query := "SELECT id, password FROM users WHERE username=$1;"
-	if err := db.QueryRow(query, u.Username).Scan(&dbUser.ID, &dbUser.Password); err != nil {
-		return "", errors.New(UserNotFoundMessage)
+	var mfaRequired bool
+	var deleted bool
+	query := `SELECT id, username, password, role, totp_enabled,
+			EXISTS(SELECT 1 FROM mfa_required_roles r WHERE r.role = users.role), deleted_at IS NOT NULL
+		FROM users WHERE username=$1;`
+	err := db.QueryRow(query, u.Username).Scan(&dbUser.ID, &dbUser.Username, &dbUser.Password, &dbUser.Role, &dbUser.TwoFactorEnabled, &mfaRequired, &deleted)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(u.Password))
+		recordLoginAttempt(db, u.Username, 0, clientIP, LoginOutcomeInvalidCredentials)
+		return nil, nil, errors.New(InvalidCredentialsMessage)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(u.Password)); err != nil {
-		return "", errors.New(InvalidPasswordMessage)
+		recordLoginAttempt(db, u.Username, dbUser.ID, clientIP, LoginOutcomeInvalidCredentials)
+		return nil, nil, errors.New(InvalidCredentialsMessage)
+	}
+
+	// Only reveal the pending deletion to someone who knows the password.
+	if deleted {
+		return nil, nil, errors.New(AccountDeletedMessage)
+	}
+
+	// The attempt is only recorded as a success once the second factor is verified.
+	if dbUser.TwoFactorEnabled || mfaRequired {
+		challenge, err := newMFAChallenge(&dbUser, !dbUser.TwoFactorEnabled)
+		return nil, challenge, err
 	}
 
-	token, err := jwt.GenerateToken(u.Username)
+	recordLoginAttempt(db, u.Username, dbUser.ID, clientIP, LoginOutcomeSuccess)
+	if _, err := db.Exec("UPDATE users SET last_login=NOW() WHERE id=$1;", dbUser.ID); err != nil {
+		log.Printf("Error updating last login: %v", err)
+	}
+
+	tokens, err := us.IssueTokens(&dbUser)
+	return tokens, nil, err
+}
+
+func (us *UserServiceImpl) IssueTokens(u *models.User) (*session.TokenPair, error) {
+	pair, err := us.Sessions.Issue(u.ID, u.Username, u.Role)
 	if err != nil {
-		return "", errors.New(TokenErrorMessage)
+		return nil, errors.New(TokenErrorMessage)
 	}
-	return token, nil
+	return pair, nil
+}
+
+func (us *UserServiceImpl) RefreshTokens(refreshToken string) (*session.TokenPair, error) {
+	return us.Sessions.Refresh(refreshToken)
 }
 
-func (us *UserServiceImpl) FetchUserProfile(db *sql.DB, username string) (*models.User, error) {
+func (us *UserServiceImpl) Logout(claims *jwt.Claims) error {
+	return us.Sessions.Revoke(claims)
+}
+
+func (us *UserServiceImpl) LogoutAllDevices(userID int) error {
+	return us.Sessions.RevokeAll(userID)
+}
+
+func (us *UserServiceImpl) FetchUserProfile(db *sql.DB, userID int) (*models.User, error) {
+	query := `SELECT id, username, email, email_verified, COALESCE(display_name, ''), COALESCE(phone_number, ''),
+			COALESCE(profile_image, ''), preferred_language, timezone, marketing_consent, marketing_consent_updated_at,
+			role, totp_enabled, date_joined, last_login
+		FROM users WHERE id=$1;`
+
 	var u models.User
-	query := "SELECT id, username FROM users WHERE username=$1;"
-	if err := db.QueryRow(query, username).Scan(&u.ID, &u.Username); err != nil {
-		return nil, errors.New(UserNotFoundMessage)
+	var consentUpdatedAt, dateJoined, lastLogin sql.NullString
+	err := db.QueryRow(query, userID).Scan(&u.ID, &u.Username, &u.Email, &u.EmailVerified, &u.DisplayName, &u.PhoneNumber,
+		&u.ProfileImage, &u.PreferredLanguage, &u.Timezone, &u.MarketingConsent, &consentUpdatedAt,
+		&u.Role, &u.TwoFactorEnabled, &dateJoined, &lastLogin)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New(UserNotFoundMessage)
+		}
+		return nil, err
 	}
-	u.Password = "" // Ensure password is not exposed
+
+	u.MarketingConsentUpdatedAt = consentUpdatedAt.String
+	u.DateJoined = dateJoined.String
+	u.LastLogin = lastLogin.String
 	return &u, nil
 }
 
-func (us *UserServiceImpl) UpdateUserProfile(db *sql.DB, u *models.User) error {
-	query := "UPDATE users SET email=$1, profile_image=$2 WHERE username=$3;"
-	_, err := db.Exec(query, u.Email, u.ProfileImage, u.Username)
-	return err
+// UpdateUserProfile applies a partial update and returns the resulting profile.
+// A new email address has to be verified again.
+func (us *UserServiceImpl) UpdateUserProfile(db *sql.DB, userID int, update *models.ProfileUpdate) (*models.User, error) {
+	var sets []string
+	var args []interface{}
+	set := func(assignment string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf(assignment, len(args)))
+	}
+
+	var newEmail string
+	if update.Email != nil {
+		email, err := normalizeEmail(*update.Email)
+		if err != nil {
+			return nil, err
+		}
+		newEmail = email
+		set("email_verified=(email_verified AND email=$%[1]d), email=$%[1]d", email)
+	}
+	if update.DisplayName != nil {
+		displayName, err := normalizeDisplayName(*update.DisplayName)
+		if err != nil {
+			return nil, err
+		}
+		set("display_name=NULLIF($%d, '')", displayName)
+	}
+	if update.PhoneNumber != nil {
+		phoneNumber, err := normalizePhoneNumber(*update.PhoneNumber)
+		if err != nil {
+			return nil, err
+		}
+		set("phone_number=NULLIF($%d, '')", phoneNumber)
+	}
+	clearProfileImage := false
+	if update.ProfileImage != nil {
+		// Profile images are uploaded; an update can only remove one.
+		if strings.TrimSpace(*update.ProfileImage) != "" {
+			return nil, errors.New(ProfileImageUploadMessage)
+		}
+		clearProfileImage = true
+		sets = append(sets, "profile_image=NULL")
+	}
+	if update.PreferredLanguage != nil {
+		if !isValidLanguageTag(*update.PreferredLanguage) {
+			return nil, errors.New(InvalidLanguageMessage)
+		}
+		set("preferred_language=$%d", *update.PreferredLanguage)
+	}
+	if update.Timezone != nil {
+		if !isValidTimezone(*update.Timezone) {
+			return nil, errors.New(InvalidTimezoneMessage)
+		}
+		set("timezone=$%d", *update.Timezone)
+	}
+	if update.MarketingConsent != nil {
+		// The time of consent is only recorded when it actually changes.
+		set("marketing_consent_updated_at=CASE WHEN marketing_consent IS DISTINCT FROM $%[1]d THEN NOW() ELSE marketing_consent_updated_at END, marketing_consent=$%[1]d", *update.MarketingConsent)
+	}
+
+	if clearProfileImage {
+		// The files of the photo are queued for removal by the database.
+		if _, err := db.Exec(`DELETE FROM photos WHERE user_id=$1;`, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sets) > 0 {
+		args = append(args, userID)
+		query := fmt.Sprintf("UPDATE users SET %s WHERE id=$%d RETURNING email_verified;", strings.Join(sets, ", "), len(args))
+
+		var emailVerified bool
+		if err := db.QueryRow(query, args...).Scan(&emailVerified); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return nil, errors.New(EmailTakenMessage)
+			}
+			if err == sql.ErrNoRows {
+				return nil, errors.New(UserNotFoundMessage)
+			}
+			return nil, err
+		}
+
+		if newEmail != "" && !emailVerified {
+			if err := us.sendVerificationEmail(db, userID, newEmail); err != nil {
+				log.Printf("Error sending verification email: %v", err)
+			}
+		}
+	}
+
+	return us.FetchUserProfile(db, userID)
 }
 
 type PasswordChangeRequest struct {
@@ -64,9 +451,14 @@ type PasswordChangeRequest struct {
 }
 
 func (us *UserServiceImpl) ChangeUserPassword(db *sql.DB, username, oldPassword, newPassword string) error {
+	if len(newPassword) < MinPasswordLength {
+		return errors.New(WeakPasswordMessage)
+	}
+
+	var userID int
 	var currentHashedPassword string
-	query := "SELECT password FROM users WHERE username=$1;"
-	if err := db.QueryRow(query, username).Scan(&currentHashedPassword); err != nil {
+	query := "SELECT id, password FROM users WHERE username=$1;"
+	if err := db.QueryRow(query, username).Scan(&userID, &currentHashedPassword); err != nil {
 		return errors.New(UserNotFoundMessage)
 	}
 
@@ -79,13 +471,29 @@ func (us *UserServiceImpl) ChangeUserPassword(db *sql.DB, username, oldPassword,
 		return errors.New(HashingErrorMessage)
 	}
 
-	updateQuery := "UPDATE users SET password=$1 WHERE username=$2;"
-	_, err = db.Exec(updateQuery, newHashedPassword, username)
-	return err
+	updateQuery := "UPDATE users SET password=$1 WHERE id=$2;"
+	if _, err := db.Exec(updateQuery, newHashedPassword, userID); err != nil {
+		return err
+	}
+
+	// Sessions opened with the old password must not outlive it.
+	return us.Sessions.RevokeAll(userID)
 }
 
-func (us *UserServiceImpl) DeleteUserAccount(db *sql.DB, username string) error {
-	query := "DELETE FROM users WHERE username=$1;"
-	_, err := db.Exec(query, username)
-	return err
+func (us *UserServiceImpl) UpdateUserRole(db *sql.DB, userID int, role string) error {
+	if !models.IsValidRole(role) {
+		return errors.New(InvalidRoleMessage)
+	}
+
+	query := "UPDATE users SET role=$1 WHERE id=$2;"
+	result, err := db.Exec(query, role, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return errors.New(UserNotFoundMessage)
+	}
+
+	// Tokens carry the role they were issued with, so the old ones have to go.
+	return us.Sessions.RevokeAll(userID)
 }